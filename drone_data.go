@@ -0,0 +1,253 @@
+package media_image
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RTK positioning states of the drone-dji:RtkFlag property.
+const (
+	DroneRTKNone   = 0  // No position
+	DroneRTKSingle = 16 // Single point, without correction
+	DroneRTKFloat  = 34 // Float solution, decimeter accuracy
+	DroneRTKFixed  = 50 // Fixed solution, centimeter accuracy
+)
+
+// DroneData is the flight metadata recorded in the XMP packet by drones.
+// Angles are in degrees, altitudes in meters and speeds in meters per second.
+type DroneData struct {
+	// Namespace is the XMP namespace the values were read from, e.g. "drone-dji".
+	Namespace string
+
+	// RelativeAltitude is the height above the take-off point.
+	RelativeAltitude    float64
+	HasRelativeAltitude bool
+
+	// AbsoluteAltitude is the height above sea level, as measured by the drone.
+	AbsoluteAltitude    float64
+	HasAbsoluteAltitude bool
+
+	// Gimbal orientation. Yaw is clockwise from north, pitch is negative
+	// below the horizon, -90 pointing straight down, and roll is positive
+	// when the right side is down.
+	GimbalYaw   float64
+	GimbalPitch float64
+	GimbalRoll  float64
+	HasGimbal   bool
+
+	// Aircraft orientation, using the conventions of the gimbal orientation.
+	FlightYaw   float64
+	FlightPitch float64
+	FlightRoll  float64
+	HasFlight   bool
+
+	// Aircraft velocity, X towards north, Y towards east and Z downwards.
+	FlightSpeedX   float64
+	FlightSpeedY   float64
+	FlightSpeedZ   float64
+	HasFlightSpeed bool
+
+	// RTKFlag is the RTK positioning state, see DroneRTKNone, and RTKStd* the
+	// standard deviations of the position, in meters.
+	RTKFlag         int
+	RTKStdLatitude  float64
+	RTKStdLongitude float64
+	RTKStdHeight    float64
+	HasRTK          bool
+
+	// CalibratedFocalLength is the focal length of the camera, in pixels.
+	CalibratedFocalLength float64
+}
+
+// droneProperties lists the XMP properties of every drone value, without
+// their namespace, by order of preference. Parrot names the gimbal angles
+// after the camera.
+var droneProperties = struct {
+	relativeAltitude, absoluteAltitude                     []string
+	gimbalYaw, gimbalPitch, gimbalRoll                     []string
+	flightYaw, flightPitch, flightRoll                     []string
+	flightSpeedX, flightSpeedY, flightSpeedZ               []string
+	rtkFlag, rtkStdLatitude, rtkStdLongitude, rtkStdHeight []string
+	calibratedFocalLength                                  []string
+}{
+	relativeAltitude:      []string{"RelativeAltitude"},
+	absoluteAltitude:      []string{"AbsoluteAltitude"},
+	gimbalYaw:             []string{"GimbalYawDegree", "CameraYawDegree"},
+	gimbalPitch:           []string{"GimbalPitchDegree", "CameraPitchDegree"},
+	gimbalRoll:            []string{"GimbalRollDegree", "CameraRollDegree"},
+	flightYaw:             []string{"FlightYawDegree"},
+	flightPitch:           []string{"FlightPitchDegree"},
+	flightRoll:            []string{"FlightRollDegree"},
+	flightSpeedX:          []string{"FlightXSpeed"},
+	flightSpeedY:          []string{"FlightYSpeed"},
+	flightSpeedZ:          []string{"FlightZSpeed"},
+	rtkFlag:               []string{"RtkFlag"},
+	rtkStdLatitude:        []string{"RtkStdLat"},
+	rtkStdLongitude:       []string{"RtkStdLon"},
+	rtkStdHeight:          []string{"RtkStdHgt"},
+	calibratedFocalLength: []string{"CalibratedFocalLength"},
+}
+
+// droneNamespaces lists the XMP namespace prefixes of drone makers.
+var droneNamespaces = []string{"drone-dji", "drone-parrot", "drone"}
+
+// droneXmpReader reads the values of a drone namespace of an XMP packet.
+type droneXmpReader struct {
+	packet    []byte
+	namespace string
+}
+
+// float returns the value of the first property found.
+func (r droneXmpReader) float(names []string) (float64, bool) {
+	for _, name := range names {
+		value, ok := xmpPropertyValue(r.packet, r.namespace+":"+name)
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimPrefix(value, "+"), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			continue
+		}
+		return f, true
+	}
+	return 0, false
+}
+
+// parseDroneData reads the drone values of an XMP packet. It returns nil when
+// the packet holds no drone namespace.
+func parseDroneData(packet []byte) *DroneData {
+	var r droneXmpReader
+	for _, namespace := range droneNamespaces {
+		if strings.Contains(string(packet), "xmlns:"+namespace+"=") {
+			r = droneXmpReader{packet: packet, namespace: namespace}
+			break
+		}
+	}
+	if r.namespace == "" {
+		return nil
+	}
+
+	p := droneProperties
+	drone := &DroneData{Namespace: r.namespace}
+	drone.RelativeAltitude, drone.HasRelativeAltitude = r.float(p.relativeAltitude)
+	drone.AbsoluteAltitude, drone.HasAbsoluteAltitude = r.float(p.absoluteAltitude)
+
+	yaw, hasYaw := r.float(p.gimbalYaw)
+	pitch, hasPitch := r.float(p.gimbalPitch)
+	roll, _ := r.float(p.gimbalRoll)
+	if hasYaw && hasPitch {
+		drone.GimbalYaw, drone.GimbalPitch, drone.GimbalRoll, drone.HasGimbal = yaw, pitch, roll, true
+	}
+
+	yaw, hasYaw = r.float(p.flightYaw)
+	pitch, hasPitch = r.float(p.flightPitch)
+	roll, hasRoll := r.float(p.flightRoll)
+	if hasYaw || hasPitch || hasRoll {
+		drone.FlightYaw, drone.FlightPitch, drone.FlightRoll, drone.HasFlight = yaw, pitch, roll, true
+	}
+
+	x, hasX := r.float(p.flightSpeedX)
+	y, hasY := r.float(p.flightSpeedY)
+	z, hasZ := r.float(p.flightSpeedZ)
+	if hasX || hasY || hasZ {
+		drone.FlightSpeedX, drone.FlightSpeedY, drone.FlightSpeedZ, drone.HasFlightSpeed = x, y, z, true
+	}
+
+	if flag, ok := r.float(p.rtkFlag); ok {
+		drone.RTKFlag, drone.HasRTK = int(flag), true
+		drone.RTKStdLatitude, _ = r.float(p.rtkStdLatitude)
+		drone.RTKStdLongitude, _ = r.float(p.rtkStdLongitude)
+		drone.RTKStdHeight, _ = r.float(p.rtkStdHeight)
+	}
+
+	drone.CalibratedFocalLength, _ = r.float(p.calibratedFocalLength)
+	return drone
+}
+
+// HorizontalSpeed returns the ground speed of the aircraft.
+func (d *DroneData) HorizontalSpeed() float64 {
+	return math.Hypot(d.FlightSpeedX, d.FlightSpeedY)
+}
+
+// RTKFixed checks if the position was recorded with a fixed RTK solution.
+func (d *DroneData) RTKFixed() bool {
+	return d.HasRTK && d.RTKFlag == DroneRTKFixed
+}
+
+// fieldOfView returns the horizontal and vertical angles of view of the
+// camera, in radians, from the calibrated focal length in pixels of the drone,
+// or else from the 35 mm equivalent focal length.
+func (d ImageData) fieldOfView() (horizontal, vertical float64, err error) {
+	width, height := float64(d.ImageWidth), float64(d.ImageHeight)
+	if width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("image dimensions unknown")
+	}
+
+	if d.Drone != nil && d.Drone.CalibratedFocalLength > 0 {
+		f := d.Drone.CalibratedFocalLength
+		return 2 * math.Atan(width/(2*f)), 2 * math.Atan(height/(2*f)), nil
+	}
+
+	focalLength35mm, ok := d.focalLength35mm()
+	if !ok {
+		return 0, 0, fmt.Errorf("focal length unknown")
+	}
+	horizontal, vertical, _ = angleOfView(focalLength35mm, d.ImageWidth, d.ImageHeight)
+	return horizontal, vertical, nil
+}
+
+// DroneFootprint returns the area of the ground seen by a drone image, as a
+// closed ring of [longitude, latitude] positions starting at the top left
+// corner of the image. The ground is taken as flat at the level of the
+// take-off point. It fails when the horizon is in view, the footprint being
+// unbounded.
+func (d ImageData) DroneFootprint() ([][2]float64, error) {
+	if d.Drone == nil || !d.Drone.HasRelativeAltitude || !d.Drone.HasGimbal {
+		return nil, fmt.Errorf("no drone altitude or gimbal orientation")
+	}
+	if d.GPSLatitude == 0 && d.GPSLongitude == 0 {
+		return nil, fmt.Errorf("no GPS position")
+	}
+	if d.Drone.RelativeAltitude <= 0 {
+		return nil, fmt.Errorf("drone not above its take-off point: %.2f m", d.Drone.RelativeAltitude)
+	}
+	horizontal, vertical, err := d.fieldOfView()
+	if err != nil {
+		return nil, err
+	}
+
+	// Camera axes in east, north, up coordinates
+	yaw, pitch, roll := radians(d.Drone.GimbalYaw), radians(d.Drone.GimbalPitch), radians(d.Drone.GimbalRoll)
+	forward := [3]float64{math.Sin(yaw) * math.Cos(pitch), math.Cos(yaw) * math.Cos(pitch), math.Sin(pitch)}
+	right := [3]float64{math.Cos(yaw), -math.Sin(yaw), 0}
+	up := [3]float64{
+		right[1]*forward[2] - right[2]*forward[1],
+		right[2]*forward[0] - right[0]*forward[2],
+		right[0]*forward[1] - right[1]*forward[0],
+	}
+	for k := range 3 {
+		right[k], up[k] = right[k]*math.Cos(roll)-up[k]*math.Sin(roll), up[k]*math.Cos(roll)+right[k]*math.Sin(roll)
+	}
+
+	// Cast the rays of the corners of the image onto the ground
+	tanH, tanV := math.Tan(horizontal/2), math.Tan(vertical/2)
+	corners := [][2]float64{{-1, 1}, {1, 1}, {1, -1}, {-1, -1}}
+	ring := make([][2]float64, 0, len(corners)+1)
+	for _, corner := range corners {
+		var ray [3]float64
+		for k := range 3 {
+			ray[k] = forward[k] + corner[0]*tanH*right[k] + corner[1]*tanV*up[k]
+		}
+		if ray[2] >= 0 {
+			return nil, fmt.Errorf("horizon in view, footprint unbounded")
+		}
+
+		scale := d.Drone.RelativeAltitude / -ray[2]
+		east, north := ray[0]*scale, ray[1]*scale
+		latitude, longitude := destinationPoint(d.GPSLatitude, d.GPSLongitude, degrees(math.Atan2(east, north)), math.Hypot(east, north))
+		ring = append(ring, [2]float64{longitude, latitude})
+	}
+	return append(ring, ring[0]), nil
+}
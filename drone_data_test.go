@@ -0,0 +1,146 @@
+package media_image
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// djiXmpPacket is the XMP packet of a DJI image, shortened.
+const djiXmpPacket = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="DJI Meta Data"
+    xmlns:drone-dji="http://www.dji.com/drone-dji/1.0/"
+   drone-dji:AbsoluteAltitude="+712.45"
+   drone-dji:RelativeAltitude="+100.00"
+   drone-dji:GimbalRollDegree="+0.00"
+   drone-dji:GimbalYawDegree="+0.00"
+   drone-dji:GimbalPitchDegree="-90.00"
+   drone-dji:FlightRollDegree="+1.20"
+   drone-dji:FlightYawDegree="-2.50"
+   drone-dji:FlightPitchDegree="-3.10"
+   drone-dji:FlightXSpeed="+3.00"
+   drone-dji:FlightYSpeed="-4.00"
+   drone-dji:FlightZSpeed="+0.10"
+   drone-dji:RtkFlag="50"
+   drone-dji:RtkStdLon="0.01146"
+   drone-dji:RtkStdLat="0.01236"
+   drone-dji:RtkStdHgt="0.02184"
+   drone-dji:CalibratedFocalLength="3666.666504">
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>`
+
+func Test_DroneData(t *testing.T) {
+	t.Log("Testing drone data")
+
+	t.Run("parse", func(t *testing.T) {
+		drone := parseDroneData([]byte(djiXmpPacket))
+		if !assert.NotNil(t, drone) {
+			return
+		}
+		assert.Equal(t, "drone-dji", drone.Namespace)
+		assert.Equal(t, 100.0, drone.RelativeAltitude)
+		assert.Equal(t, 712.45, drone.AbsoluteAltitude)
+		assert.True(t, drone.HasGimbal)
+		assert.Equal(t, -90.0, drone.GimbalPitch)
+		assert.Equal(t, -2.5, drone.FlightYaw)
+		assert.Equal(t, 5.0, drone.HorizontalSpeed())
+		assert.True(t, drone.RTKFixed())
+		assert.Equal(t, 0.01236, drone.RTKStdLatitude)
+		assert.Equal(t, 3666.666504, drone.CalibratedFocalLength)
+
+		assert.Nil(t, parseDroneData([]byte(`<x:xmpmeta><rdf:Description xmp:Rating="3"/></x:xmpmeta>`)))
+	})
+
+	data := ImageData{
+		GPSLatitude:  45,
+		GPSLongitude: 7,
+		ImageWidth:   5472,
+		ImageHeight:  3648,
+		Drone:        parseDroneData([]byte(djiXmpPacket)),
+	}
+
+	t.Run("nadir footprint", func(t *testing.T) {
+		footprint, err := data.DroneFootprint()
+		if !assert.NoError(t, err) {
+			return
+		}
+		if !assert.Len(t, footprint, 5) {
+			return
+		}
+		assert.Equal(t, footprint[0], footprint[4])
+
+		// 74.6 m either side east-west and 49.7 m north-south, top to the north
+		topLeft, bottomRight := footprint[0], footprint[2]
+		assert.InDelta(t, 2*74.62, haversineDistance(45, topLeft[0], 45, bottomRight[0]), 0.1)
+		assert.InDelta(t, 2*49.75, haversineDistance(topLeft[1], 7, bottomRight[1], 7), 0.1)
+		assert.Less(t, topLeft[0], 7.0)
+		assert.Greater(t, topLeft[1], 45.0)
+	})
+
+	t.Run("oblique footprint", func(t *testing.T) {
+		oblique := data
+		drone := *data.Drone
+		drone.GimbalPitch, drone.GimbalYaw = -45, 90
+		oblique.Drone = &drone
+
+		footprint, err := oblique.DroneFootprint()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// Facing east, the far edge is wider than the near edge
+		far := haversineDistance(footprint[0][1], footprint[0][0], footprint[1][1], footprint[1][0])
+		near := haversineDistance(footprint[2][1], footprint[2][0], footprint[3][1], footprint[3][0])
+		assert.Greater(t, far, near)
+		assert.Greater(t, footprint[0][0], 7.0)
+
+		drone.GimbalPitch = -10
+		_, err = oblique.DroneFootprint()
+		assert.Error(t, err)
+	})
+
+	t.Run("35mm equivalent", func(t *testing.T) {
+		equivalent := data
+		drone := *data.Drone
+		drone.CalibratedFocalLength = 0
+		equivalent.Drone = &drone
+		equivalent.LensFocalLength35mm = "24"
+
+		horizontal, _, err := equivalent.fieldOfView()
+		assert.NoError(t, err)
+		assert.InDelta(t, 73.74, degrees(horizontal), 0.01)
+
+		equivalent.LensFocalLength35mm = ""
+		_, err = equivalent.DroneFootprint()
+		assert.Error(t, err)
+	})
+
+	t.Run("image", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		jpeg, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Insert an XMP segment after the start of image marker
+		payload := append(append([]byte{}, xmpJpegPrefix...), djiXmpPacket...)
+		segment := []byte{0xff, 0xe1, 0, 0}
+		binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+		updated := append(append(append([]byte{}, jpeg[:2]...), append(segment, payload...)...), jpeg[2:]...)
+		if err := os.WriteFile(path, updated, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		i := loadImage(t, path)
+		drone := i.ImageData.Drone
+		if assert.NotNil(t, drone) {
+			assert.Equal(t, 100.0, drone.RelativeAltitude)
+			assert.Equal(t, FieldProvenance{Source: SourceXmp, Tag: drone.Namespace}, i.Provenance["Drone"])
+		}
+		assert.Nil(t, loadImage(t, "samples/jpg/gps/gps-1.jpg").ImageData.Drone)
+	})
+}
@@ -0,0 +1,126 @@
+package media_image
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// Errors of the parsers, to be matched with errors.Is.
+var (
+	// ErrUnsupportedFileType is returned for files of a type the package
+	// cannot read the metadata of.
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+
+	// ErrNoExif is returned when a file has no EXIF data. It is the error of
+	// the EXIF library, so that both match.
+	ErrNoExif = exif.ErrNoExif
+
+	// ErrCorrupt is returned when the file or its metadata is malformed.
+	ErrCorrupt = errors.New("corrupt data")
+
+	// ErrTruncated is returned when the file or its metadata ends early.
+	ErrTruncated = errors.New("truncated data")
+
+	// ErrLimitExceeded is returned when the file or its metadata exceeds one
+	// of the Limits of the parser.
+	ErrLimitExceeded = errors.New("limit exceeded")
+)
+
+// truncationMessages are the messages of the errors of the metadata
+// libraries reporting missing data, which do not wrap io.EOF.
+var truncationMessages = []string{"eof", "partial", "not enough data", "truncated", "short read"}
+
+// classifyError wraps an error of the metadata libraries with the error of
+// the package matching its cause. File system errors are returned as is.
+func classifyError(err error) error {
+	var pathErr *fs.PathError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNoExif), errors.Is(err, ErrUnsupportedFileType), errors.Is(err, ErrCorrupt), errors.Is(err, ErrTruncated), errors.Is(err, ErrLimitExceeded):
+		return err
+	case errors.As(err, &pathErr):
+		return err
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, exifcommon.ErrNotEnoughData):
+		return fmt.Errorf("%w: %w", ErrTruncated, err)
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, truncation := range truncationMessages {
+		if strings.Contains(message, truncation) {
+			return fmt.Errorf("%w: %w", ErrTruncated, err)
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrCorrupt, err)
+}
+
+// PanicError is returned when a parser panics on malformed data, which the
+// metadata libraries do instead of returning errors. It matches ErrCorrupt,
+// and the error panicked with when there is one.
+type PanicError struct {
+	Parser string      // Parser that panicked, e.g. "jpeg" or "exif"
+	Value  interface{} // Value passed to panic
+	Stack  []byte      // Stack trace of the panicking goroutine
+}
+
+// Error returns the description of the panic, without its stack trace.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s parser panicked: %v", e.Parser, e.Value)
+}
+
+// Unwrap returns ErrCorrupt and the error panicked with, if any.
+func (e *PanicError) Unwrap() []error {
+	if err, ok := e.Value.(error); ok {
+		return []error{ErrCorrupt, err}
+	}
+	return []error{ErrCorrupt}
+}
+
+// recoverPanic converts a panic of a parser into a PanicError set to err. It
+// must be deferred directly by the function returning err.
+func recoverPanic(parser string, err *error) {
+	if value := recover(); value != nil {
+		*err = &PanicError{Parser: parser, Value: value, Stack: debug.Stack()}
+	}
+}
+
+// Warning is a non-fatal problem met while reading the metadata of an image.
+type Warning struct {
+	Field string // ImageData field concerned, empty when not specific to a field
+	Err   error
+}
+
+// String returns the description of the warning.
+func (w Warning) String() string {
+	if w.Field == "" {
+		return w.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", w.Field, w.Err)
+}
+
+// warnings collects the warnings of a parse and logs them to an optional
+// logger.
+type warnings struct {
+	logger *slog.Logger
+	list   []Warning
+}
+
+// warn records a warning on a field, empty when not specific to a field.
+func (w *warnings) warn(field string, err error) {
+	w.list = append(w.list, Warning{Field: field, Err: err})
+	if w.logger != nil {
+		if field == "" {
+			w.logger.Warn(err.Error())
+		} else {
+			w.logger.Warn(err.Error(), slog.String("field", field))
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package media_image
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFile writes data to a file of a temporary directory.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_Errors(t *testing.T) {
+	t.Log("Testing errors and warnings")
+
+	t.Run("unsupported", func(t *testing.T) {
+		i, err := NewImageInfo(writeFile(t, "notes.txt", []byte("not an image")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = i.Exif()
+		assert.ErrorIs(t, err, ErrUnsupportedFileType)
+
+		// The image could not be decoded either
+		if assert.Len(t, i.Warnings, 1) {
+			assert.Empty(t, i.Warnings[0].Field)
+		}
+	})
+
+	t.Run("no exif", func(t *testing.T) {
+		i, err := NewImageInfo("samples/gif/sunflower-plants.gif")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = i.Exif()
+		assert.NoError(t, err)
+		if assert.Len(t, i.Warnings, 1) {
+			assert.ErrorIs(t, i.Warnings[0].Err, ErrNoExif)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		data, err := os.ReadFile("samples/jpg/gps/gps-1.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		i, err := NewImageInfo(writeFile(t, "truncated.jpg", data[:3000]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = i.Exif()
+		assert.ErrorIs(t, err, ErrTruncated)
+		assert.NotErrorIs(t, err, ErrCorrupt)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		i, err := NewImageInfo(writeFile(t, "garbage.jpg", []byte("garbage")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = i.Exif()
+		assert.ErrorIs(t, err, ErrCorrupt)
+
+		_, err = NewExifDataParser().Parse([]byte("garbage"))
+		assert.Error(t, err)
+	})
+
+	t.Run("classify", func(t *testing.T) {
+		assert.NoError(t, classifyError(nil))
+		assert.ErrorIs(t, classifyError(io.ErrUnexpectedEOF), ErrTruncated)
+		assert.ErrorIs(t, classifyError(io.ErrUnexpectedEOF), io.ErrUnexpectedEOF)
+		assert.ErrorIs(t, classifyError(errors.New("bad marker")), ErrCorrupt)
+
+		// File system errors are not about the content of the file
+		_, err := os.Open("missing.jpg")
+		assert.ErrorIs(t, classifyError(err), fs.ErrNotExist)
+		assert.NotErrorIs(t, classifyError(err), ErrCorrupt)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		parse := func(value interface{}) (err error) {
+			defer recoverPanic("jpeg", &err)
+			panic(value)
+		}
+
+		err := parse(io.ErrUnexpectedEOF)
+		var panicErr *PanicError
+		if assert.ErrorAs(t, err, &panicErr) {
+			assert.Equal(t, "jpeg", panicErr.Parser)
+			assert.NotEmpty(t, panicErr.Stack)
+		}
+		assert.ErrorIs(t, err, ErrCorrupt)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.EqualError(t, err, "jpeg parser panicked: unexpected EOF")
+
+		err = parse("index out of range")
+		assert.ErrorIs(t, err, ErrCorrupt)
+	})
+
+	t.Run("warnings", func(t *testing.T) {
+		var logs bytes.Buffer
+		i, err := NewImageInfo("samples/jpg/gps/gps-1.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		i.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+		if _, err := i.Exif(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The zoom ratio is recorded as 0/100, not a float
+		var fields []string
+		for _, w := range i.Warnings {
+			fields = append(fields, w.Field)
+		}
+		assert.Contains(t, fields, "DigitalZoomRatio")
+		assert.Contains(t, logs.String(), "field=DigitalZoomRatio")
+
+		// Warnings are not accumulated across extractions
+		count := len(i.Warnings)
+		if _, err := i.Exif(); err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, i.Warnings, count)
+
+		// Images without GPS IFD are not warned about
+		i = loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		for _, w := range i.Warnings {
+			assert.NotEqual(t, "GPS", w.Field, w.String())
+		}
+	})
+}
@@ -0,0 +1,296 @@
+package media_image
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Defaults used when the fields of EventClustering are zero.
+const (
+	DefaultEventMaxGap      = 6 * time.Hour
+	DefaultEventMaxDistance = 50_000.0
+	DefaultEventMinImages   = 1
+)
+
+// EventClustering describes how images are grouped into events.
+type EventClustering struct {
+	// MaxGap is the maximum time between two images of the same event.
+	MaxGap time.Duration
+
+	// MaxDistance is the maximum distance, in meters, between two located
+	// images of the same event. Images without location are grouped by time
+	// only.
+	MaxDistance float64
+
+	// MinImages is the number of images within MaxGap and MaxDistance of an
+	// image, itself included, making it the core of an event. Images not
+	// reachable from a core image are left out of every event.
+	MinImages int
+}
+
+// Event is a group of images taken close in time and space.
+type Event struct {
+	// Images are ordered by capture time.
+	Images []*ImageInfo
+
+	// Start and End are the capture times of the first and last images, in
+	// the time zone of the event when known.
+	Start time.Time
+	End   time.Time
+
+	// TimeZone is the most frequent GPSTimeZone of the images.
+	TimeZone string
+
+	// Located is set when at least one image of the event has a position.
+	Located bool
+
+	// CentroidLatitude and CentroidLongitude are the mean position of the
+	// located images.
+	CentroidLatitude  float64
+	CentroidLongitude float64
+
+	// Bounding box of the located images. West is greater than East when the
+	// box crosses the antimeridian.
+	South float64
+	West  float64
+	North float64
+	East  float64
+
+	// Place is the place of the centroid, see ReverseGeocoder.Lookup.
+	Place Place
+}
+
+// Duration returns the time span of the event.
+func (e Event) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// EventClusterer groups images into events, in a DBSCAN fashion over their
+// capture times and positions.
+type EventClusterer struct {
+	clustering EventClustering
+}
+
+// eventImage is an image with its resolved capture time.
+type eventImage struct {
+	image   *ImageInfo
+	time    time.Time
+	located bool
+	cluster int
+}
+
+// NewEventClusterer creates a new EventClusterer.
+func NewEventClusterer(clustering EventClustering) (*EventClusterer, error) {
+	if clustering.MaxGap < 0 {
+		return nil, fmt.Errorf("invalid maximum gap: %s", clustering.MaxGap)
+	}
+	if clustering.MaxDistance < 0 || math.IsNaN(clustering.MaxDistance) {
+		return nil, fmt.Errorf("invalid maximum distance: %f", clustering.MaxDistance)
+	}
+	if clustering.MinImages < 0 {
+		return nil, fmt.Errorf("invalid minimum number of images: %d", clustering.MinImages)
+	}
+
+	if clustering.MaxGap == 0 {
+		clustering.MaxGap = DefaultEventMaxGap
+	}
+	if clustering.MaxDistance == 0 {
+		clustering.MaxDistance = DefaultEventMaxDistance
+	}
+	if clustering.MinImages == 0 {
+		clustering.MinImages = DefaultEventMinImages
+	}
+	return &EventClusterer{clustering: clustering}, nil
+}
+
+// Cluster groups the images into events, ordered by start time. Images
+// without capture time, or too isolated to belong to an event, are returned
+// apart. The capture time is the capture instant of the image, or its wall
+// clock when the time offset is unknown.
+func (c *EventClusterer) Cluster(images []*ImageInfo) (events []Event, outliers []*ImageInfo) {
+	points := make([]*eventImage, 0, len(images))
+	for _, image := range images {
+		t, ok := image.ImageData.CaptureInstant()
+		if !ok {
+			t = image.ImageData.DateTimeOriginal
+		}
+		if t.IsZero() {
+			outliers = append(outliers, image)
+			continue
+		}
+		data := image.ImageData
+		points = append(points, &eventImage{
+			image:   image,
+			time:    t,
+			located: data.GPSLatitude != 0 || data.GPSLongitude != 0,
+			cluster: -1,
+		})
+	}
+	sort.SliceStable(points, func(a, b int) bool { return points[a].time.Before(points[b].time) })
+
+	// Expand every unvisited core image into a new cluster
+	var clusters [][]*eventImage
+	for k := range points {
+		if points[k].cluster >= 0 {
+			continue
+		}
+		neighbors := c.neighbors(points, k)
+		if len(neighbors) < c.clustering.MinImages {
+			continue
+		}
+
+		id := len(clusters)
+		points[k].cluster = id
+		cluster := []*eventImage{points[k]}
+		for len(neighbors) > 0 {
+			n := neighbors[0]
+			neighbors = neighbors[1:]
+			// Images without location may link images far apart, which are
+			// only added when close to a located image of the cluster
+			if points[n].cluster >= 0 || !c.nearCluster(cluster, points[n]) {
+				continue
+			}
+			points[n].cluster = id
+			cluster = append(cluster, points[n])
+			if next := c.neighbors(points, n); len(next) >= c.clustering.MinImages {
+				neighbors = append(neighbors, next...)
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	for _, point := range points {
+		if point.cluster < 0 {
+			outliers = append(outliers, point.image)
+		}
+	}
+	for _, cluster := range clusters {
+		events = append(events, newEvent(cluster))
+	}
+	sort.SliceStable(events, func(a, b int) bool { return events[a].Start.Before(events[b].Start) })
+
+	return events, outliers
+}
+
+// neighbors returns the indexes of the images within reach of an image,
+// itself included, the images being ordered by time.
+func (c *EventClusterer) neighbors(points []*eventImage, k int) []int {
+	within := func(n int) bool {
+		return absDuration(points[n].time.Sub(points[k].time)) <= c.clustering.MaxGap
+	}
+	near := func(n int) bool {
+		if !points[k].located || !points[n].located {
+			return true
+		}
+		a, b := points[k].image.ImageData, points[n].image.ImageData
+		return haversineDistance(a.GPSLatitude, a.GPSLongitude, b.GPSLatitude, b.GPSLongitude) <= c.clustering.MaxDistance
+	}
+
+	var neighbors []int
+	for n := k; n >= 0 && within(n); n-- {
+		if near(n) {
+			neighbors = append(neighbors, n)
+		}
+	}
+	for n := k + 1; n < len(points) && within(n); n++ {
+		if near(n) {
+			neighbors = append(neighbors, n)
+		}
+	}
+	return neighbors
+}
+
+// nearCluster checks if an image is within MaxDistance of a located image of
+// a cluster. Images without location and clusters without located images are
+// near any image.
+func (c *EventClusterer) nearCluster(cluster []*eventImage, point *eventImage) bool {
+	if !point.located {
+		return true
+	}
+	located := false
+	for _, member := range cluster {
+		if !member.located {
+			continue
+		}
+		located = true
+		a, b := member.image.ImageData, point.image.ImageData
+		if haversineDistance(a.GPSLatitude, a.GPSLongitude, b.GPSLatitude, b.GPSLongitude) <= c.clustering.MaxDistance {
+			return true
+		}
+	}
+	return !located
+}
+
+// newEvent summarizes a cluster of images.
+func newEvent(cluster []*eventImage) Event {
+	sort.SliceStable(cluster, func(a, b int) bool { return cluster[a].time.Before(cluster[b].time) })
+
+	event := Event{Start: cluster[0].time, End: cluster[len(cluster)-1].time}
+	zones := make(map[string]int)
+	var x, y, z float64
+	var longitudes []float64
+	for _, point := range cluster {
+		event.Images = append(event.Images, point.image)
+
+		data := point.image.ImageData
+		if data.GPSTimeZone != "" {
+			zones[data.GPSTimeZone]++
+			if zones[data.GPSTimeZone] > zones[event.TimeZone] {
+				event.TimeZone = data.GPSTimeZone
+			}
+		}
+		if !point.located {
+			continue
+		}
+
+		// Averaging unit vectors handles positions across the antimeridian
+		lat, lon := radians(data.GPSLatitude), radians(data.GPSLongitude)
+		x += math.Cos(lat) * math.Cos(lon)
+		y += math.Cos(lat) * math.Sin(lon)
+		z += math.Sin(lat)
+		longitudes = append(longitudes, data.GPSLongitude)
+
+		if !event.Located {
+			event.Located = true
+			event.South, event.North = data.GPSLatitude, data.GPSLatitude
+		}
+		event.South = math.Min(event.South, data.GPSLatitude)
+		event.North = math.Max(event.North, data.GPSLatitude)
+	}
+
+	if loc, err := time.LoadLocation(event.TimeZone); err == nil && event.TimeZone != "" {
+		event.Start, event.End = event.Start.In(loc), event.End.In(loc)
+	}
+
+	if event.Located {
+		event.CentroidLatitude = degrees(math.Atan2(z, math.Hypot(x, y)))
+		event.CentroidLongitude = degrees(math.Atan2(y, x))
+		event.West, event.East = longitudeRange(longitudes)
+		if geocoder, err := NewReverseGeocoder(); err == nil {
+			event.Place, _ = geocoder.Lookup(event.CentroidLatitude, event.CentroidLongitude)
+		}
+	}
+
+	return event
+}
+
+// longitudeRange returns the narrowest range holding the longitudes, as its
+// west and east bounds, west being greater than east across the antimeridian.
+func longitudeRange(longitudes []float64) (west, east float64) {
+	sort.Float64s(longitudes)
+
+	// The range is the complement of the widest gap between longitudes, the
+	// gap across the antimeridian included
+	n := len(longitudes)
+	west, east = longitudes[0], longitudes[n-1]
+	widest := longitudes[0] + 360 - longitudes[n-1]
+	for k := 1; k < n; k++ {
+		if gap := longitudes[k] - longitudes[k-1]; gap > widest {
+			widest = gap
+			west, east = longitudes[k], longitudes[k-1]
+		}
+	}
+	return west, east
+}
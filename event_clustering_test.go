@@ -0,0 +1,103 @@
+package media_image
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EventClusterer(t *testing.T) {
+	t.Log("Testing event clustering")
+
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := func(latitude, longitude float64, timeZone string, taken time.Time) *ImageInfo {
+		return &ImageInfo{ImageData: ImageData{
+			GPSLatitude:      latitude,
+			GPSLongitude:     longitude,
+			GPSTimeZone:      timeZone,
+			DateTimeOriginal: taken,
+		}}
+	}
+
+	// A trip to Rome over two days, a photo at home in between the days, and
+	// an undated scan
+	day := func(d, hour, minute int) time.Time { return time.Date(2024, 5, d, hour, minute, 0, 0, rome) }
+	images := []*ImageInfo{
+		image(41.9022, 12.4539, "Europe/Rome", day(3, 10, 0)),
+		image(41.8902, 12.4922, "Europe/Rome", day(3, 13, 30)),
+		image(0, 0, "", day(3, 16, 0)),
+		image(41.8986, 12.4769, "Europe/Rome", day(3, 19, 0)),
+		image(48.8584, 2.2945, "Europe/Paris", day(3, 21, 0)),
+		image(41.9009, 12.4833, "Europe/Rome", day(4, 0, 30)),
+		image(0, 0, "", time.Time{}),
+	}
+
+	t.Run("cluster", func(t *testing.T) {
+		clusterer, err := NewEventClusterer(EventClustering{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, outliers := clusterer.Cluster(images)
+		if !assert.Len(t, events, 2) {
+			return
+		}
+		assert.Equal(t, []*ImageInfo{images[6]}, outliers)
+
+		trip := events[0]
+		assert.Equal(t, []*ImageInfo{images[0], images[1], images[2], images[3], images[5]}, trip.Images)
+		assert.Equal(t, "Europe/Rome", trip.TimeZone)
+		assert.Equal(t, rome, trip.Start.Location())
+		assert.True(t, trip.Start.Equal(day(3, 10, 0)))
+		assert.Equal(t, 14*time.Hour+30*time.Minute, trip.Duration())
+		assert.True(t, trip.Located)
+		assert.InDelta(t, 41.898, trip.CentroidLatitude, 0.001)
+		assert.InDelta(t, 12.477, trip.CentroidLongitude, 0.001)
+		assert.Equal(t, [4]float64{41.8902, 12.4539, 41.9022, 12.4922}, [4]float64{trip.South, trip.West, trip.North, trip.East})
+		assert.NotEmpty(t, trip.Place.City)
+
+		home := events[1]
+		assert.Equal(t, []*ImageInfo{images[4]}, home.Images)
+		assert.Equal(t, "Europe/Paris", home.TimeZone)
+		assert.Zero(t, home.Duration())
+	})
+
+	t.Run("min images", func(t *testing.T) {
+		clusterer, err := NewEventClusterer(EventClustering{MaxGap: 4 * time.Hour, MinImages: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, outliers := clusterer.Cluster(images)
+		if !assert.Len(t, events, 1) {
+			return
+		}
+		assert.Len(t, events[0].Images, 4)
+		assert.ElementsMatch(t, []*ImageInfo{images[4], images[5], images[6]}, outliers)
+	})
+
+	t.Run("antimeridian", func(t *testing.T) {
+		taken := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+		clusterer, _ := NewEventClusterer(EventClustering{})
+		events, _ := clusterer.Cluster([]*ImageInfo{
+			image(-16.8, 179.9, "", taken),
+			image(-16.9, -179.9, "", taken.Add(time.Hour)),
+		})
+		if !assert.Len(t, events, 1) {
+			return
+		}
+		assert.InDelta(t, 180, math.Abs(events[0].CentroidLongitude), 0.001)
+		assert.Equal(t, 179.9, events[0].West)
+		assert.Equal(t, -179.9, events[0].East)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewEventClusterer(EventClustering{MaxGap: -time.Hour})
+		assert.Error(t, err)
+		_, err = NewEventClusterer(EventClustering{MaxDistance: -1})
+		assert.Error(t, err)
+	})
+}
@@ -1,9 +1,9 @@
 package media_image
 
 import (
+	"errors"
 	"fmt"
-	"log"
-	"math"
+	"log/slog"
 	"reflect"
 	"strconv"
 	"strings"
@@ -25,30 +25,75 @@ var timeFormats = []string{
 
 // Common error messages
 const (
-	errNoGPSInfo    = "no GPS info found: %v"
+	errNoGPSInfo    = "no GPS info found: %w"
 	errParseGPSInfo = "failed to parse GPS info: %v"
 	errParseTime    = "unable to parse time: %s"
 	errParseTag     = "failed to parse tags for field %s: %v"
-	errLoadTimezone = "failed to load timezone location: %v"
+	errLoadTimezone = "failed to load timezone location: %w"
 )
 
 // ExifDataParser is responsible for extracting and parsing EXIF metadata from images.
-// It maintains a cache of parsed tags to improve performance when processing multiple images.
+// It maintains a cache of parsed tags to improve performance when processing multiple images,
+// and collects the non-fatal problems met by the last parse as warnings.
 type ExifDataParser struct {
 	tagCache map[string][]*tags.Tag
+	warnings warnings
+	limits   Limits
+	options  Options
+
+	// allowedTags is the set of the tags read, from the options
+	allowedTags map[string]bool
 }
 
 // NewExifDataParser creates and initializes a new instance of ExifDataParser.
-// It initializes the tag cache used to store parsed EXIF tags for better performance.
+// It initializes the tag cache used to store parsed EXIF tags for better performance,
+// and enforces DefaultLimits.
 //
 // Returns:
 //   - *ExifDataParser: A pointer to the newly created parser instance
 func NewExifDataParser() *ExifDataParser {
 	return &ExifDataParser{
 		tagCache: make(map[string][]*tags.Tag),
+		limits:   DefaultLimits,
 	}
 }
 
+// SetLimits sets the limits the EXIF data is checked against, of which
+// MaxExifSize, MaxIfdCount and MaxIfdDepth apply to the parser.
+//
+// Parameters:
+//   - limits: Limits of the EXIF data
+func (p *ExifDataParser) SetLimits(limits Limits) {
+	p.limits = limits
+}
+
+// SetOptions sets the options of the parsing, of which SkipTimeZoneLookup,
+// SkipTimeZoneAdjustment and Tags apply to the parser.
+//
+// Parameters:
+//   - options: Options of the parsing
+func (p *ExifDataParser) SetOptions(options Options) {
+	p.options = options
+	p.allowedTags = options.allowedTags()
+}
+
+// SetLogger sets the logger the warnings are logged to as they are met,
+// nil to only collect them.
+//
+// Parameters:
+//   - logger: Logger of the warnings
+func (p *ExifDataParser) SetLogger(logger *slog.Logger) {
+	p.warnings.logger = logger
+}
+
+// Warnings returns the non-fatal problems met by the last parse.
+//
+// Returns:
+//   - []Warning: Warnings of the last parse, in the order they were met
+func (p *ExifDataParser) Warnings() []Warning {
+	return p.warnings.list
+}
+
 // Global variables for EXIF parsing and timezone lookup
 var (
 	// exifIfdMapping stores the Image File Directory mapping information
@@ -57,30 +102,42 @@ var (
 	// exifTagIndex maintains an index of all EXIF tags for quick lookup
 	exifTagIndex = exif.NewTagIndex()
 
-	// tzFinder is used to determine timezone information from GPS coordinates
-	tzFinder tzf.F
+	// tzFinder is used to determine timezone information from GPS coordinates,
+	// nil when it failed to initialize with tzFinderErr
+	tzFinder    tzf.F
+	tzFinderErr error
 )
 
 // init initializes the global variables required for EXIF parsing.
 // It loads standard IFD mappings and initializes the timezone finder.
-// If initialization of critical components fails, the program will terminate.
+// If initialization of critical components fails, it panics.
 func init() {
 	// Initialize IFD mapping
 	exifIfdMapping = exifcommon.NewIfdMapping()
 	if err := exifcommon.LoadStandardIfds(exifIfdMapping); err != nil {
-		log.Fatalf("Failed to load standard IFDs: %s", err)
+		panic(fmt.Sprintf("failed to load standard IFDs: %v", err))
 	}
 
-	// Initialize timezone finder
-	var err error
-	tzFinder, err = tzf.NewDefaultFinder()
-	if err != nil {
-		log.Printf("Warning: Failed to initialize timezone finder: %v", err)
+	// Initialize tag index, completing the GPS tags missing from the library
+	if err := exif.LoadStandardTags(exifTagIndex); err != nil {
+		panic(fmt.Sprintf("failed to load standard tags: %v", err))
+	}
+	gpsHPositioningError := &exif.IndexedTag{
+		Id:             gpsTagHPositioningError,
+		Name:           "GPSHPositioningError",
+		IfdPath:        exifcommon.IfdGpsInfoStandardIfdIdentity.UnindexedString(),
+		SupportedTypes: []exifcommon.TagTypePrimitive{exifcommon.TypeRational},
+	}
+	if err := exifTagIndex.Add(gpsHPositioningError); err != nil {
+		panic(fmt.Sprintf("failed to register GPSHPositioningError: %v", err))
 	}
+
+	// Initialize timezone finder, reported by the parser when needed
+	tzFinder, tzFinderErr = tzf.NewDefaultFinder()
 }
 
 // getExifTags retrieves the EXIF tags for a given struct field.
-// It uses a cache to avoid repeated parsing of the same tags.
+// It uses a cache, keyed by struct tag, to avoid repeated parsing of the same tags.
 //
 // Parameters:
 //   - field: The struct field to get EXIF tags for
@@ -90,7 +147,7 @@ func init() {
 //   - error: Any error encountered while parsing tags
 func (p *ExifDataParser) getExifTags(field reflect.StructField) ([]*tags.Tag, error) {
 	// Check cache first
-	if cachedTags, ok := p.tagCache[field.Name]; ok {
+	if cachedTags, ok := p.tagCache[string(field.Tag)]; ok {
 		return cachedTags, nil
 	}
 
@@ -109,7 +166,7 @@ func (p *ExifDataParser) getExifTags(field reflect.StructField) ([]*tags.Tag, er
 	}
 
 	// Cache the results
-	p.tagCache[field.Name] = exifTags
+	p.tagCache[string(field.Tag)] = exifTags
 	return exifTags, nil
 }
 
@@ -122,17 +179,18 @@ func (p *ExifDataParser) getExifTags(field reflect.StructField) ([]*tags.Tag, er
 //
 // Returns:
 //   - string: The found value
+//   - string: Name of the tag the value was found in
 //   - bool: Whether a value was found
-func (p *ExifDataParser) getValueFromMetadata(metadata map[string]string, fieldTags []*tags.Tag) (string, bool) {
+func (p *ExifDataParser) getValueFromMetadata(metadata map[string]string, fieldTags []*tags.Tag) (string, string, bool) {
 	for _, tag := range fieldTags {
 		names := strings.Split(tag.Value, ",")
 		for _, name := range names {
 			if value, ok := metadata[name]; ok && value != "" {
-				return value, true
+				return value, name, true
 			}
 		}
 	}
-	return "", false
+	return "", "", false
 }
 
 // Parse extracts and processes EXIF metadata from raw image data.
@@ -146,20 +204,78 @@ func (p *ExifDataParser) getValueFromMetadata(metadata map[string]string, fieldT
 //   - ImageData: Structured representation of the extracted metadata
 //   - error: Any error encountered during parsing
 func (p *ExifDataParser) Parse(exifData []byte) (ImageData, error) {
+	imageData, _, err := p.ParseWithProvenance(exifData)
+	return imageData, err
+}
+
+// ParseWithProvenance is like Parse but also returns the provenance of
+// every populated field: the tag and IFD it was read from, or whether it
+// was derived from other fields.
+//
+// Parameters:
+//   - exifData: Raw EXIF data bytes from the image
+//
+// Returns:
+//   - ImageData: Structured representation of the extracted metadata
+//   - map[string]FieldProvenance: Provenance of the populated fields, by field name
+//   - error: Any error encountered during parsing, matching ErrLimitExceeded
+//     when the data exceeds the limits of the parser, a PanicError when the
+//     EXIF library panics on malformed data
+func (p *ExifDataParser) ParseWithProvenance(exifData []byte) (imageData ImageData, provenance map[string]FieldProvenance, err error) {
+	defer func() {
+		if err != nil {
+			imageData, provenance = ImageData{}, nil
+		}
+	}()
+	defer recoverPanic("exif", &err)
+
+	p.warnings.list = nil
+
+	// Check the size and the IFDs before the library reads them all
+	if err := p.checkLimits(exifData); err != nil {
+		return ImageData{}, nil, err
+	}
+
 	// Extract all EXIF entries and build metadata map
-	metadata, err := p.buildMetadataMap(exifData)
+	metadata, ifdPaths, err := p.buildMetadataMap(exifData)
 	if err != nil {
-		return ImageData{}, fmt.Errorf("failed to extract EXIF data: %v", err)
+		return ImageData{}, nil, fmt.Errorf("failed to extract EXIF data: %w", classifyError(err))
 	}
 
 	// Build IFD index for structured access to EXIF data
 	var ifdIndex exif.IfdIndex
 	_, ifdIndex, err = exif.Collect(exifIfdMapping, exifTagIndex, exifData)
 	if err != nil {
-		return ImageData{}, fmt.Errorf("failed to build IFD index: %v", err)
+		return ImageData{}, nil, fmt.Errorf("failed to build IFD index: %w", classifyError(err))
+	}
+
+	return p.parseWithReflection(metadata, ifdPaths, ifdIndex)
+}
+
+// checkLimits checks the size of the EXIF data and its IFDs against the
+// limits of the parser. Malformed data is left to the parsing to report.
+//
+// Parameters:
+//   - exifData: Raw EXIF data bytes
+//
+// Returns:
+//   - error: An error matching ErrLimitExceeded when a limit is exceeded
+func (p *ExifDataParser) checkLimits(exifData []byte) error {
+	if err := checkLimit("EXIF size", int64(len(exifData)), int64(p.limits.MaxExifSize)); err != nil {
+		return err
+	}
+	if p.limits.MaxIfdCount <= 0 && p.limits.MaxIfdDepth <= 0 {
+		return nil
 	}
 
-	return p.parseWithReflection(metadata, ifdIndex)
+	header, err := exif.ParseExifHeader(exifData)
+	if err != nil {
+		return nil
+	}
+	limiter := newIfdLimiter(p.limits)
+	enumerate := exif.NewIfdEnumerate(exifIfdMapping, exifTagIndex, exif.NewExifReadSeekerWithBytes(exifData), header.ByteOrder)
+	_, _ = enumerate.Scan(exifcommon.IfdStandardIfdIdentity, header.FirstIfdOffset, limiter.visit, nil)
+	return limiter.err
 }
 
 // buildMetadataMap creates a map of EXIF tag names to their values from raw EXIF data.
@@ -170,13 +286,15 @@ func (p *ExifDataParser) Parse(exifData []byte) (ImageData, error) {
 //
 // Returns:
 //   - map[string]string: Processed metadata map
+//   - map[string]string: IFD path each value of the metadata map was read from
 //   - error: Any error encountered during extraction
-func (p *ExifDataParser) buildMetadataMap(exifData []byte) (map[string]string, error) {
+func (p *ExifDataParser) buildMetadataMap(exifData []byte) (map[string]string, map[string]string, error) {
 	metadata := make(map[string]string)
+	ifdPaths := make(map[string]string)
 
 	entries, _, err := exif.GetFlatExifDataUniversalSearch(exifData, nil, true)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	//// affiche dans la console les données exif
@@ -198,13 +316,19 @@ func (p *ExifDataParser) buildMetadataMap(exifData []byte) (map[string]string, e
 			continue
 		}
 
+		// Skip the tags left out by the options
+		if p.allowedTags != nil && !p.allowedTags[entry.TagName] {
+			continue
+		}
+
 		// Handle null-terminated strings
 		if len(s) > 0 && s[0] != "" {
 			metadata[entry.TagName] = s[0]
+			ifdPaths[entry.TagName] = entry.IfdPath
 		}
 	}
 
-	return metadata, nil
+	return metadata, ifdPaths, nil
 }
 
 // parseWithReflection processes the metadata map and IFD index using reflection
@@ -212,13 +336,16 @@ func (p *ExifDataParser) buildMetadataMap(exifData []byte) (map[string]string, e
 //
 // Parameters:
 //   - metadata: Map of EXIF tag names to their values
+//   - ifdPaths: Map of EXIF tag names to the IFD path of their values
 //   - ifdIndex: Index of Image File Directory information
 //
 // Returns:
 //   - ImageData: Populated structure containing the image metadata
+//   - map[string]FieldProvenance: Provenance of the populated fields, by field name
 //   - error: Any error encountered during processing
-func (p *ExifDataParser) parseWithReflection(metadata map[string]string, ifdIndex exif.IfdIndex) (ImageData, error) {
+func (p *ExifDataParser) parseWithReflection(metadata map[string]string, ifdPaths map[string]string, ifdIndex exif.IfdIndex) (ImageData, map[string]FieldProvenance, error) {
 	imageData := ImageData{}
+	provenance := make(map[string]FieldProvenance)
 	v := reflect.ValueOf(&imageData).Elem()
 	t := v.Type()
 
@@ -238,7 +365,7 @@ func (p *ExifDataParser) parseWithReflection(metadata map[string]string, ifdInde
 		// Get and validate EXIF tags for the field
 		fieldTags, err := p.getExifTags(field)
 		if err != nil {
-			log.Printf("Warning: %v", err)
+			p.warnings.warn(field.Name, err)
 			continue
 		}
 
@@ -247,24 +374,36 @@ func (p *ExifDataParser) parseWithReflection(metadata map[string]string, ifdInde
 		}
 
 		// Extract and set field value
-		if value, ok := p.getValueFromMetadata(metadata, fieldTags); ok {
+		if value, tagName, ok := p.getValueFromMetadata(metadata, fieldTags); ok {
 			if err := p.setFieldValue(fieldValue, value); err != nil {
-				log.Printf("Warning: failed to set field %s: %v", field.Name, err)
+				p.warnings.warn(field.Name, err)
+				continue
 			}
+			provenance[field.Name] = FieldProvenance{Source: SourceExif, Tag: tagName, IfdPath: ifdPaths[tagName]}
 		}
 	}
 
+	// Keep the values as read, to flag those adjusted below
+	read := imageData
+
 	// Process GPS information separately due to its complex nature
-	if err := p.extractGPSInfo(&imageData, metadata, ifdIndex); err != nil {
-		log.Printf("Warning: GPS extraction failed: %v", err)
+	// Most images have no GPS IFD, which is not worth a warning
+	if err := p.extractGPSInfo(&imageData, metadata, ifdIndex); err != nil && !errors.Is(err, exif.ErrTagNotFound) {
+		p.warnings.warn("GPS", fmt.Errorf("GPS extraction failed: %w", err))
 	}
 
-	return imageData, nil
+	// Process exposure values from their raw values
+	p.extractExposureInfo(&imageData, ifdIndex)
+	setPhotographicValues(&imageData)
+
+	completeProvenance(provenance, read, imageData)
+	return imageData, provenance, nil
 }
 
 // isSpecialField determines if a field requires special handling
 // and should not be processed using the standard reflection approach.
-// All GPS-related fields are considered special and handled separately.
+// GPS-related fields, typed exposure fields and enumerated fields are
+// considered special and handled separately.
 //
 // Parameters:
 //   - fieldName: Name of the field to check
@@ -272,8 +411,8 @@ func (p *ExifDataParser) parseWithReflection(metadata map[string]string, ifdInde
 // Returns:
 //   - bool: True if the field requires special handling
 func (p *ExifDataParser) isSpecialField(fieldName string) bool {
-	// All GPS fields are considered special
-	return strings.HasPrefix(fieldName, "GPS")
+	// All GPS fields, the typed exposure fields and the enumerated fields are considered special
+	return strings.HasPrefix(fieldName, "GPS") || exposureFields[fieldName] || enumFields[fieldName]
 }
 
 // setFieldValue sets a field's value based on its type and the provided string value.
@@ -357,25 +496,30 @@ func (p *ExifDataParser) extractGPSInfo(imageData *ImageData, metadata map[strin
 		return fmt.Errorf(errNoGPSInfo, err)
 	}
 
-	// Extract GPS info using the dedicated GPS parser
-	gpsInfo, err := ifd.GpsInfo()
-	if err != nil {
-		return fmt.Errorf(errParseGPSInfo, err)
+	// Read the GPS IFD into the typed GPS model, honouring the reference tags
+	gps := parseGPSData(ifdReader{ifd: ifd, allowedTags: p.allowedTags})
+	imageData.GPS = gps
+
+	// Flag suspicious fixes, the positions ruled out being kept out of the
+	// image data
+	validateGPSData(gps, imageData)
+	if len(gps.Issues) > 0 {
+		p.warnings.warn("GPS", fmt.Errorf("suspicious GPS data: %v", gps.Issues))
 	}
 
 	// Process coordinates and timezone
-	if err := p.processGPSCoordinates(imageData, gpsInfo); err != nil {
+	if err := p.processGPSCoordinates(imageData, gps); err != nil {
 		return err
 	}
 
-	// Set altitude if available
-	if gpsInfo.Altitude != 0 {
-		imageData.GPSAltitude = float64(gpsInfo.Altitude)
+	// Set altitude if available, negative below sea level
+	if gps.HasAltitude && !gps.HasIssue(GPSIssueImpossibleAltitude) {
+		imageData.GPSAltitude = gps.Altitude
 	}
 
 	// Set GPS timestamp and process local time
-	if !gpsInfo.Timestamp.IsZero() {
-		imageData.GPSTimestamp = gpsInfo.Timestamp
+	if !gps.Timestamp.IsZero() {
+		imageData.GPSTimestamp = gps.Timestamp
 		p.processLocalTime(imageData)
 	}
 
@@ -385,11 +529,28 @@ func (p *ExifDataParser) extractGPSInfo(imageData *ImageData, metadata map[strin
 	return nil
 }
 
-// processAdditionalGPSMetadata handles the extraction of additional GPS-related metadata
-// that is not covered by the standard GPS parser.
+// extractExposureInfo extracts the typed exposure values and the enumerated
+// values from the raw values of the EXIF IFD, converting the APEX values.
 //
 // Parameters:
 //   - imageData: Pointer to the ImageData struct to populate
+//   - ifdIndex: Index of Image File Directory information
+func (p *ExifDataParser) extractExposureInfo(imageData *ImageData, ifdIndex exif.IfdIndex) {
+	ifd, err := ifdIndex.RootIfd.ChildWithIfdPath(exifcommon.IfdExifStandardIfdIdentity)
+	if err != nil {
+		return
+	}
+	r := ifdReader{ifd: ifd, allowedTags: p.allowedTags}
+	parseExposure(r, imageData)
+	parseFocalPlane(r, imageData)
+	parseEnums(r, imageData)
+}
+
+// processAdditionalGPSMetadata handles the extraction of additional GPS-related metadata
+// that is not covered by the position and timestamp.
+//
+// Parameters:
+//   - imageData: Pointer to the ImageData struct to populate, with its GPS model set
 //   - metadata: Map of EXIF tag names to their values
 func (p *ExifDataParser) processAdditionalGPSMetadata(imageData *ImageData, metadata map[string]string) {
 	// Processing method
@@ -407,35 +568,20 @@ func (p *ExifDataParser) processAdditionalGPSMetadata(imageData *ImageData, meta
 		imageData.GPSSatellites = satellites
 	}
 
-	// Positioning error
-	if hError, ok := metadata["GPSHPositioningError"]; ok {
-		imageData.GPSHPositioningError, _ = strconv.ParseFloat(hError, 64)
-	}
+	// Rational values are read from the typed model, converted using their reference tags
+	gps := imageData.GPS
+	imageData.GPSHPositioningError = gps.HPositioningError
 
 	// Movement information
-	if speed, ok := metadata["GPSSpeed"]; ok {
-		imageData.GPSSpeed, _ = strconv.ParseFloat(speed, 64)
-	}
-	if track, ok := metadata["GPSTrack"]; ok {
-		imageData.GPSTrack, _ = strconv.ParseFloat(track, 64)
-	}
-	if imgDir, ok := metadata["GPSImgDirection"]; ok {
-		imageData.GPSImgDirection, _ = strconv.ParseFloat(imgDir, 64)
-	}
+	imageData.GPSSpeed = gps.Speed
+	imageData.GPSTrack = gps.Track.Degrees
+	imageData.GPSImgDirection = gps.ImgDirection.Degrees
 
 	// Destination information
-	if destLat, ok := metadata["GPSDestLatitude"]; ok {
-		imageData.GPSDestLatitude, _ = strconv.ParseFloat(destLat, 64)
-	}
-	if destLong, ok := metadata["GPSDestLongitude"]; ok {
-		imageData.GPSDestLongitude, _ = strconv.ParseFloat(destLong, 64)
-	}
-	if bearing, ok := metadata["GPSDestBearing"]; ok {
-		imageData.GPSDestBearing, _ = strconv.ParseFloat(bearing, 64)
-	}
-	if distance, ok := metadata["GPSDestDistance"]; ok {
-		imageData.GPSDestDistance, _ = strconv.ParseFloat(distance, 64)
-	}
+	imageData.GPSDestLatitude = gps.DestLatitude
+	imageData.GPSDestLongitude = gps.DestLongitude
+	imageData.GPSDestBearing = gps.DestBearing.Degrees
+	imageData.GPSDestDistance = gps.DestDistance
 }
 
 // processGPSCoordinates handles the extraction and validation of GPS coordinates
@@ -443,29 +589,45 @@ func (p *ExifDataParser) processAdditionalGPSMetadata(imageData *ImageData, meta
 //
 // Parameters:
 //   - imageData: Pointer to the ImageData struct to populate
-//   - gpsInfo: GPS information from EXIF data
+//   - gps: GPS information from EXIF data
 //
 // Returns:
 //   - error: Any error encountered during processing
-func (p *ExifDataParser) processGPSCoordinates(imageData *ImageData, gpsInfo *exif.GpsInfo) error {
-	// Validate and set coordinates
-	if math.IsNaN(gpsInfo.Latitude.Decimal()) || math.IsNaN(gpsInfo.Longitude.Decimal()) {
-		return fmt.Errorf("invalid GPS coordinates")
+func (p *ExifDataParser) processGPSCoordinates(imageData *ImageData, gps *GPSData) error {
+	// Images may record a GPS IFD without a position, e.g. altitude only,
+	// and positions ruled out by the validation do not locate the image
+	if !gps.HasPosition || !gps.validPosition() {
+		return nil
 	}
 
-	imageData.GPSLatitude = gpsInfo.Latitude.Decimal()
-	imageData.GPSLongitude = gpsInfo.Longitude.Decimal()
+	imageData.GPSLatitude = gps.Latitude
+	imageData.GPSLongitude = gps.Longitude
+	imageData.GPSGeohash = imageData.Geohash(GeohashPrecision)
 
-	// Get timezone from coordinates if possible
-	if tzFinder != nil {
+	// Get the place from coordinates
+	if err := setGPSPlace(imageData); err != nil {
+		p.warnings.warn("GPSCity", err)
+	}
+
+	// Get timezone from coordinates if possible, unless the coordinates may
+	// be swapped
+	if p.options.SkipTimeZoneLookup || gps.HasIssue(GPSIssueSwapped) {
+		return nil
+	}
+	if tzFinder == nil {
+		p.warnings.warn("GPSTimeZone", fmt.Errorf("failed to initialize timezone finder: %w", tzFinderErr))
+	} else {
 		timezoneName := tzFinder.GetTimezoneName(
 			imageData.GPSLongitude,
 			imageData.GPSLatitude,
 		)
 		if timezoneName != "" {
 			imageData.GPSTimeZone = timezoneName
-			// Adjust all time fields with the found timezone
-			p.adjustTimeWithTimezone(imageData)
+			// Adjust all time fields with the found timezone, unless the fix
+			// may be stale or the camera clock wrong
+			if gps.Valid() && !p.options.SkipTimeZoneAdjustment {
+				p.adjustTimeWithTimezone(imageData)
+			}
 		}
 	}
 
@@ -484,7 +646,7 @@ func (p *ExifDataParser) processLocalTime(imageData *ImageData) {
 
 	loc, err := time.LoadLocation(imageData.GPSTimeZone)
 	if err != nil {
-		log.Printf("Warning: %s", fmt.Sprintf(errLoadTimezone, err))
+		p.warnings.warn("GPSTimeZone", fmt.Errorf(errLoadTimezone, err))
 		return
 	}
 
@@ -505,7 +667,7 @@ func (p *ExifDataParser) adjustTimeWithTimezone(imageData *ImageData) {
 	// Load the location for the timezone
 	loc, err := time.LoadLocation(imageData.GPSTimeZone)
 	if err != nil {
-		log.Printf("Warning: %s", fmt.Sprintf(errLoadTimezone, err))
+		p.warnings.warn("GPSTimeZone", fmt.Errorf(errLoadTimezone, err))
 		return
 	}
 
@@ -513,15 +675,8 @@ func (p *ExifDataParser) adjustTimeWithTimezone(imageData *ImageData) {
 	now := time.Now().UTC().In(loc)
 	_, offset := now.Zone()
 
-	// Format the offset as "+HHMM" or "-HHMM"
-	sign := "+"
-	if offset < 0 {
-		sign = "-"
-		offset = -offset
-	}
-	hours := offset / 3600
-	minutes := (offset % 3600) / 60
-	imageData.TimeOffset = fmt.Sprintf("%s%02d%02d", sign, hours, minutes)
+	// Format the offset as the EXIF OffsetTime tags, e.g. "+02:00"
+	imageData.TimeOffset = formatTimeOffset(offset)
 	imageData.HasTimeOffset = true
 
 	// Adjust DateTimeOriginal if it exists
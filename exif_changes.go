@@ -0,0 +1,519 @@
+package media_image
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/smartmediafiles/media/media/types"
+)
+
+// ExifChange is the modification of an ImageData field to write into the EXIF data.
+type ExifChange struct {
+	// Field is the name of the ImageData field.
+	Field string
+
+	// Value is the new value of the field, written as is: zero values, such
+	// as a latitude on the equator or a flash that did not fire, are values.
+	Value interface{}
+
+	// Delete removes the tags of the field, Value being ignored.
+	Delete bool
+}
+
+// exifFieldWriter writes the value of an ImageData field into the EXIF data,
+// or removes the tags holding it.
+type exifFieldWriter struct {
+	ifdPath  string
+	tagNames []string // Tags holding the field, removed along with it
+	write    func(editor *exifEditor, value reflect.Value) error
+}
+
+// remove removes the tags of the field.
+func (w exifFieldWriter) remove(editor *exifEditor) error {
+	for _, tagName := range w.tagNames {
+		if err := editor.delete(w.ifdPath, tagName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readOnlyFields lists the ImageData fields describing the pixel data, which
+// cannot be changed without re-encoding the image, and the display forms of
+// the typed exposure fields, written through ExposureTime, FNumber and
+// FocalLength.
+var readOnlyFields = map[string]bool{
+	"ImageWidth":      true,
+	"ImageHeight":     true,
+	"Compression":     true,
+	"CameraExposure":  true,
+	"LensAperture":    true,
+	"LensFocalLength": true,
+}
+
+// gpsVersionId is the GPS IFD version written when the IFD is created.
+var gpsVersionId = []byte{2, 3, 0, 0}
+
+// gpsCoordinatePairs maps the coordinates to the other coordinate of their
+// position, a zero coordinate being valid as long as the other is not.
+var gpsCoordinatePairs = map[string]string{
+	"GPSLatitude":      "GPSLongitude",
+	"GPSLongitude":     "GPSLatitude",
+	"GPSDestLatitude":  "GPSDestLongitude",
+	"GPSDestLongitude": "GPSDestLatitude",
+}
+
+// gpsPresence tells whether the GPS fields whose zero value is valid, such as
+// the north for a bearing, are present in the typed GPS data.
+var gpsPresence = map[string]func(gps *GPSData) bool{
+	"GPSAltitude":          func(gps *GPSData) bool { return gps.HasAltitude },
+	"GPSSpeed":             func(gps *GPSData) bool { return gps.HasSpeed },
+	"GPSTrack":             func(gps *GPSData) bool { return gps.Track.Valid },
+	"GPSImgDirection":      func(gps *GPSData) bool { return gps.ImgDirection.Valid },
+	"GPSDestBearing":       func(gps *GPSData) bool { return gps.DestBearing.Valid },
+	"GPSDestDistance":      func(gps *GPSData) bool { return gps.HasDestDistance },
+	"GPSHPositioningError": func(gps *GPSData) bool { return gps.HasHPositioningError },
+}
+
+// DiffImageData returns the changes turning original into updated, one per
+// modified field backed by an EXIF tag. Fields missing from updated are
+// deleted, a field being missing when it holds its zero value, except for:
+//   - the coordinates, missing only when both coordinates of the position are zero
+//   - the GPS bearings, speed, distances and altitude, missing when the typed
+//     GPS data of updated does not flag them
+//   - the numeric fields with a Has flag, such as the enumerated values,
+//     missing when the flag is unset
+func DiffImageData(original, updated ImageData) []ExifChange {
+	var changes []ExifChange
+
+	o := reflect.ValueOf(original)
+	u := reflect.ValueOf(updated)
+	t := o.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := field.Tag.Lookup("exif"); !ok {
+			continue
+		}
+
+		op, up := fieldPresent(original, field.Name), fieldPresent(updated, field.Name)
+		if !up {
+			if op {
+				changes = append(changes, ExifChange{Field: field.Name, Delete: true})
+			}
+			continue
+		}
+
+		if op && sameFieldValue(o.Field(i).Interface(), u.Field(i).Interface()) {
+			continue
+		}
+
+		changes = append(changes, ExifChange{Field: field.Name, Value: u.Field(i).Interface()})
+	}
+
+	return changes
+}
+
+// sameFieldValue checks if two values of an ImageData field are the same,
+// dates being the same instant at the same wall clock time.
+func sameFieldValue(a, b interface{}) bool {
+	if at, ok := a.(time.Time); ok {
+		bt := b.(time.Time)
+		return at.Equal(bt) && wallClock(at).Equal(wallClock(bt))
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// fieldPresent checks if an ImageData field holds a value, as described by
+// DiffImageData.
+func fieldPresent(data ImageData, name string) bool {
+	v := reflect.ValueOf(data)
+	value := v.FieldByName(name)
+	if !value.IsZero() {
+		return true
+	}
+
+	if pair, ok := gpsCoordinatePairs[name]; ok {
+		return !v.FieldByName(pair).IsZero()
+	}
+	if present, ok := gpsPresence[name]; ok {
+		return data.GPS != nil && present(data.GPS)
+	}
+	if has := v.FieldByName("Has" + name); has.IsValid() && has.Kind() == reflect.Bool && value.Kind() != reflect.String {
+		return has.Bool()
+	}
+	return false
+}
+
+// Write writes the changes into the EXIF data of the file, rebuilding the EXIF
+// block and keeping everything else in the file as it is.
+func (w *ExifWriter) Write(path string, fileType types.FileType, changes []ExifChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// Resolve every change before touching the file
+	writers := make([]exifFieldWriter, len(changes))
+	values := make([]reflect.Value, len(changes))
+	for i, change := range changes {
+		writer, fieldType, err := w.fieldWriter(change.Field)
+		if err != nil {
+			return err
+		}
+
+		writers[i] = writer
+		if change.Delete {
+			continue
+		}
+
+		value := reflect.ValueOf(change.Value)
+		if !value.IsValid() {
+			return fmt.Errorf("no value for field %s", change.Field)
+		}
+		if value.Type() != fieldType {
+			return fmt.Errorf("invalid value type %s for field %s", value.Type(), change.Field)
+		}
+		values[i] = value
+	}
+
+	edit := metadataEdit{
+		exif: func(editor *exifEditor) error {
+			for i, writer := range writers {
+				var err error
+				if changes[i].Delete {
+					err = writer.remove(editor)
+				} else {
+					err = writer.write(editor, values[i])
+				}
+				if err != nil {
+					return fmt.Errorf("failed to write field %s: %w", changes[i].Field, err)
+				}
+			}
+			return nil
+		},
+	}
+	return w.update(path, fileType, edit)
+}
+
+// fieldWriter returns the writer of an ImageData field and the type of the field.
+func (w *ExifWriter) fieldWriter(name string) (exifFieldWriter, reflect.Type, error) {
+	field, ok := reflect.TypeOf(ImageData{}).FieldByName(name)
+	if !ok {
+		return exifFieldWriter{}, nil, fmt.Errorf("unknown field: %s", name)
+	}
+	if readOnlyFields[name] {
+		return exifFieldWriter{}, nil, fmt.Errorf("field %s cannot be written", name)
+	}
+
+	// Fields spreading over several tags
+	switch name {
+	case "GPSLatitude":
+		return gpsCoordinateWriter("GPSLatitude", "N", "S"), field.Type, nil
+	case "GPSLongitude":
+		return gpsCoordinateWriter("GPSLongitude", "E", "W"), field.Type, nil
+	case "GPSDestLatitude":
+		return gpsCoordinateWriter("GPSDestLatitude", "N", "S"), field.Type, nil
+	case "GPSDestLongitude":
+		return gpsCoordinateWriter("GPSDestLongitude", "E", "W"), field.Type, nil
+	case "GPSAltitude":
+		return exifFieldWriter{ifdPathGps, []string{"GPSAltitudeRef", "GPSAltitude"}, writeGpsAltitude}, field.Type, nil
+	case "GPSTimestamp":
+		return exifFieldWriter{ifdPathGps, []string{"GPSDateStamp", "GPSTimeStamp"}, writeGpsTimestamp}, field.Type, nil
+	case "GPSProcessingMethod":
+		return exifFieldWriter{ifdPathGps, []string{"GPSProcessingMethod"}, writeGpsProcessingMethod}, field.Type, nil
+	case "GPSSpeed":
+		return gpsUnitWriter("GPSSpeed", "GPSSpeedRef", 3.6), field.Type, nil
+	case "GPSTrack":
+		return gpsValueWriter("GPSTrack", "GPSTrackRef", "T"), field.Type, nil
+	case "GPSImgDirection":
+		return gpsValueWriter("GPSImgDirection", "GPSImgDirectionRef", "T"), field.Type, nil
+	case "GPSDestBearing":
+		return gpsValueWriter("GPSDestBearing", "GPSDestBearingRef", "T"), field.Type, nil
+	case "GPSDestDistance":
+		return gpsUnitWriter("GPSDestDistance", "GPSDestDistanceRef", 1.0/1000), field.Type, nil
+	case "TimeOffset":
+		return exifFieldWriter{ifdPathExif, timeOffsetTags, writeTimeOffset}, field.Type, nil
+	}
+
+	// Other fields are written to the first tag listed in their exif struct tag
+	fieldTags, err := NewExifDataParser().getExifTags(field)
+	if err != nil {
+		return exifFieldWriter{}, nil, err
+	}
+	if len(fieldTags) == 0 {
+		return exifFieldWriter{}, nil, fmt.Errorf("field %s has no EXIF tag", name)
+	}
+	tagName := strings.Split(fieldTags[0].Value, ",")[0]
+
+	// The EXIF IFD comes first as TIFF/EP also defines many EXIF tags in IFD0,
+	// where EXIF readers do not look for them
+	for _, ifdPath := range []string{ifdPathExif, ifdPathRoot, ifdPathGps} {
+		it, err := exifTagIndex.GetWithName(ifdIdentities[ifdPath], tagName)
+		if err != nil {
+			continue
+		}
+
+		ifdPath := ifdPath
+		write := func(editor *exifEditor, value reflect.Value) error {
+			tagValue, err := exifTagValue(it, value)
+			if err != nil {
+				return err
+			}
+			if ifdPath == ifdPathGps {
+				if err := ensureGpsVersion(editor); err != nil {
+					return err
+				}
+			}
+			return editor.set(ifdPath, tagName, tagValue)
+		}
+		return exifFieldWriter{ifdPath, []string{tagName}, write}, field.Type, nil
+	}
+
+	return exifFieldWriter{}, nil, fmt.Errorf("no writable EXIF tag %s for field %s", tagName, name)
+}
+
+// exifTagValue converts the value of a field to a value of a type supported by the tag.
+func exifTagValue(it *exif.IndexedTag, value reflect.Value) (interface{}, error) {
+	supportsRational := it.DoesSupportType(exifcommon.TypeRational) || it.DoesSupportType(exifcommon.TypeSignedRational)
+	supportsInteger := it.DoesSupportType(exifcommon.TypeShort) || it.DoesSupportType(exifcommon.TypeLong)
+
+	// Signed tags, e.g. ExposureBiasValue, take signed rationals whatever the sign
+	rational := rationalTagValue
+	if !it.DoesSupportType(exifcommon.TypeRational) && it.DoesSupportType(exifcommon.TypeSignedRational) {
+		rational = func(r Rational) interface{} {
+			return []exifcommon.SignedRational{{Numerator: int32(r.Numerator), Denominator: int32(r.Denominator)}}
+		}
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return nil, fmt.Errorf("zero date")
+		}
+		return v.Format(exifTimeLayout), nil
+
+	case Rational:
+		return rational(v), nil
+
+	case string:
+		switch {
+		case it.DoesSupportType(exifcommon.TypeAscii):
+			return v, nil
+		case supportsInteger:
+			n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			return []uint32{uint32(n)}, nil
+		case supportsRational:
+			r, err := NewRational(v)
+			if err != nil {
+				return nil, err
+			}
+			return rational(r), nil
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := value.Int()
+		if supportsRational {
+			return rational(Rational{Numerator: int(n), Denominator: 1}), nil
+		}
+		if n < 0 || n > math.MaxUint32 {
+			return nil, fmt.Errorf("value %d out of range", n)
+		}
+		return []uint32{uint32(n)}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := value.Uint()
+		if n > math.MaxUint32 {
+			return nil, fmt.Errorf("value %d out of range", n)
+		}
+		return []uint32{uint32(n)}, nil
+
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		if supportsRational {
+			return rational(rationalFromFloat(f)), nil
+		}
+		if f < 0 || f > math.MaxUint32 {
+			return nil, fmt.Errorf("value %v out of range", f)
+		}
+		return []uint32{uint32(math.Round(f))}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported value type %s", value.Type())
+}
+
+// rationalTagValue converts a Rational to the go-exif representation.
+func rationalTagValue(r Rational) interface{} {
+	if r.Numerator < 0 || r.Denominator < 0 {
+		return []exifcommon.SignedRational{{Numerator: int32(r.Numerator), Denominator: int32(r.Denominator)}}
+	}
+	return []exifcommon.Rational{{Numerator: uint32(r.Numerator), Denominator: uint32(r.Denominator)}}
+}
+
+// ensureGpsVersion adds the GPSVersionID tag, mandatory in a GPS IFD, when missing.
+func ensureGpsVersion(editor *exifEditor) error {
+	if editor.has(ifdPathGps, "GPSVersionID") {
+		return nil
+	}
+	return editor.set(ifdPathGps, "GPSVersionID", gpsVersionId)
+}
+
+// gpsCoordinateWriter writes a signed decimal coordinate as degrees, minutes
+// and seconds along with its reference tag.
+func gpsCoordinateWriter(tagName string, positiveRef string, negativeRef string) exifFieldWriter {
+	write := func(editor *exifEditor, value reflect.Value) error {
+		return setGpsCoordinate(editor, tagName, positiveRef, negativeRef, value.Float())
+	}
+	return exifFieldWriter{ifdPathGps, []string{tagName + "Ref", tagName}, write}
+}
+
+// setGpsCoordinate writes a signed decimal coordinate and its reference tag.
+func setGpsCoordinate(editor *exifEditor, tagName string, positiveRef string, negativeRef string, coordinate float64) error {
+	ref := positiveRef
+	if coordinate < 0 {
+		ref = negativeRef
+	}
+	if err := ensureGpsVersion(editor); err != nil {
+		return err
+	}
+	if err := editor.set(ifdPathGps, tagName+"Ref", ref); err != nil {
+		return err
+	}
+	return editor.set(ifdPathGps, tagName, gpsDegreesToRationals(coordinate))
+}
+
+// gpsValueWriter writes an unsigned GPS value, adding its reference tag with
+// a default unit when missing.
+func gpsValueWriter(tagName string, refTagName string, defaultRef string) exifFieldWriter {
+	write := func(editor *exifEditor, value reflect.Value) error {
+		f := value.Float()
+		if f < 0 {
+			return fmt.Errorf("negative value for %s", tagName)
+		}
+
+		if err := ensureGpsVersion(editor); err != nil {
+			return err
+		}
+		if !editor.has(ifdPathGps, refTagName) {
+			if err := editor.set(ifdPathGps, refTagName, defaultRef); err != nil {
+				return err
+			}
+		}
+		return editor.set(ifdPathGps, tagName, rationalTagValue(rationalFromFloat(f)))
+	}
+	return exifFieldWriter{ifdPathGps, []string{refTagName, tagName}, write}
+}
+
+// gpsUnitWriter writes a speed in meters per second or a distance in meters,
+// converted to kilometers per hour or kilometers by the factor, along with
+// its reference tag.
+func gpsUnitWriter(tagName string, refTagName string, factor float64) exifFieldWriter {
+	write := func(editor *exifEditor, value reflect.Value) error {
+		f := value.Float()
+		if f < 0 {
+			return fmt.Errorf("negative value for %s", tagName)
+		}
+
+		if err := ensureGpsVersion(editor); err != nil {
+			return err
+		}
+		if err := editor.set(ifdPathGps, refTagName, GPSUnitKilometers); err != nil {
+			return err
+		}
+		return editor.set(ifdPathGps, tagName, rationalTagValue(rationalFromFloat(f*factor)))
+	}
+	return exifFieldWriter{ifdPathGps, []string{refTagName, tagName}, write}
+}
+
+// writeGpsAltitude writes the altitude and whether it is below sea level.
+func writeGpsAltitude(editor *exifEditor, value reflect.Value) error {
+	altitude := value.Float()
+	ref := byte(0)
+	if altitude < 0 {
+		ref = 1
+	}
+	if err := ensureGpsVersion(editor); err != nil {
+		return err
+	}
+	if err := editor.set(ifdPathGps, "GPSAltitudeRef", []byte{ref}); err != nil {
+		return err
+	}
+	return editor.set(ifdPathGps, "GPSAltitude", rationalTagValue(rationalFromFloat(math.Abs(altitude))))
+}
+
+// writeGpsTimestamp writes the UTC date and time of the GPS fix.
+func writeGpsTimestamp(editor *exifEditor, value reflect.Value) error {
+	t := value.Interface().(time.Time)
+	if t.IsZero() {
+		return fmt.Errorf("zero date")
+	}
+
+	t = t.UTC()
+	if err := ensureGpsVersion(editor); err != nil {
+		return err
+	}
+	if err := editor.set(ifdPathGps, "GPSDateStamp", t.Format("2006:01:02")); err != nil {
+		return err
+	}
+	seconds := float64(t.Second()) + float64(t.Nanosecond())/1e9
+	return editor.set(ifdPathGps, "GPSTimeStamp", []exifcommon.Rational{
+		{Numerator: uint32(t.Hour()), Denominator: 1},
+		{Numerator: uint32(t.Minute()), Denominator: 1},
+		{Numerator: uint32(math.Round(seconds * 1000)), Denominator: 1000},
+	})
+}
+
+// writeGpsProcessingMethod writes the processing method, an UNDEFINED tag
+// starting with its character code.
+func writeGpsProcessingMethod(editor *exifEditor, value reflect.Value) error {
+	method := value.String()
+	it, err := exifTagIndex.GetWithName(ifdIdentities[ifdPathGps], "GPSProcessingMethod")
+	if err != nil {
+		return err
+	}
+	if err := ensureGpsVersion(editor); err != nil {
+		return err
+	}
+	raw := append([]byte("ASCII\x00\x00\x00"), method...)
+	return editor.setRaw(ifdPathGps, it.Id, exifcommon.TypeUndefined, raw)
+}
+
+// timeOffsetTags are the tags of the offsets of the dates of the image.
+var timeOffsetTags = []string{"OffsetTime", "OffsetTimeOriginal", "OffsetTimeDigitized"}
+
+// writeTimeOffset writes the offset of every date of the image.
+func writeTimeOffset(editor *exifEditor, value reflect.Value) error {
+	seconds, err := parseTimeOffset(value.String())
+	if err != nil {
+		return err
+	}
+	for _, tagName := range timeOffsetTags {
+		if err := editor.set(ifdPathExif, tagName, formatTimeOffset(seconds)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gpsDegreesToRationals converts a decimal coordinate to unsigned degrees,
+// minutes and seconds.
+func gpsDegreesToRationals(coordinate float64) []exifcommon.Rational {
+	// Round the seconds before splitting them so that they never reach 60
+	const precision = 10000
+	seconds := uint64(math.Round(math.Abs(coordinate) * 3600 * precision))
+
+	return []exifcommon.Rational{
+		{Numerator: uint32(seconds / (3600 * precision)), Denominator: 1},
+		{Numerator: uint32(seconds / (60 * precision) % 60), Denominator: 1},
+		{Numerator: uint32(seconds % (60 * precision)), Denominator: precision},
+	}
+}
@@ -2,8 +2,12 @@ package media_image
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
 )
 
 // Rational represents a rational number with a numerator and a denominator.
@@ -12,23 +16,175 @@ type Rational struct {
 	Denominator int
 }
 
+// NewRational parses a rational written as a fraction, e.g. "1/250" or
+// "-1/3", or as a decimal number, e.g. "2.8", approximated by a fraction.
 func NewRational(s string) (Rational, error) {
-	parts := strings.Split(s, "/")
-	if len(parts) != 2 {
+	s = strings.TrimSpace(s)
+	if numerator, denominator, ok := strings.Cut(s, "/"); ok {
+		n, err := strconv.Atoi(strings.TrimSpace(numerator))
+		if err != nil {
+			return Rational{}, fmt.Errorf("invalid format for Rational: %s", s)
+		}
+		d, err := strconv.Atoi(strings.TrimSpace(denominator))
+		if err != nil {
+			return Rational{}, fmt.Errorf("invalid format for Rational: %s", s)
+		}
+		// Keep the sign on the numerator
+		if d < 0 {
+			n, d = -n, -d
+		}
+		return Rational{Numerator: n, Denominator: d}, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
 		return Rational{}, fmt.Errorf("invalid format for Rational: %s", s)
 	}
-	numerator, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return Rational{}, err
+	return rationalFromFloat(f), nil
+}
+
+// parseExifNumber parses a number formatted either as a decimal or as a
+// rational "numerator/denominator".
+func parseExifNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if numerator, denominator, ok := strings.Cut(s, "/"); ok {
+		n, errN := strconv.ParseFloat(numerator, 64)
+		d, errD := strconv.ParseFloat(denominator, 64)
+		if errN != nil || errD != nil || d == 0 {
+			return 0, false
+		}
+		return n / d, true
 	}
-	denominator, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return Rational{}, err
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+// Float returns the value of the Rational, zero when its denominator is zero
+// as EXIF uses 0/0 for unknown values.
+func (r Rational) Float() float64 {
+	if r.Denominator == 0 {
+		return 0
 	}
-	return Rational{Numerator: numerator, Denominator: denominator}, nil
+	return float64(r.Numerator) / float64(r.Denominator)
 }
 
 // String converts a Rational to a string formatted as "numerator/denominator".
 func (r Rational) String() string {
 	return fmt.Sprintf("%d/%d", r.Numerator, r.Denominator)
 }
+
+// rationalFromFloat approximates a float with the closest Rational whose
+// denominator does not exceed one million.
+func rationalFromFloat(f float64) Rational {
+	const maxDenominator = 1_000_000
+
+	if f == math.Trunc(f) && math.Abs(f) <= math.MaxInt32 {
+		return Rational{Numerator: int(f), Denominator: 1}
+	}
+
+	sign := 1
+	if f < 0 {
+		sign, f = -1, -f
+	}
+
+	// Continued fraction expansion, stopping before the terms overflow
+	h0, h1 := 0.0, 1.0
+	k0, k1 := 1.0, 0.0
+	x := f
+	for i := 0; i < 64; i++ {
+		a := math.Floor(x)
+		h2, k2 := a*h1+h0, a*k1+k0
+		if k2 > maxDenominator || h2 > math.MaxInt32 {
+			break
+		}
+		h0, h1, k0, k1 = h1, h2, k1, k2
+
+		if x-a < 1e-12 {
+			break
+		}
+		x = 1 / (x - a)
+	}
+
+	if k1 == 0 {
+		return Rational{Numerator: sign * int(math.Min(math.Round(f), math.MaxInt32)), Denominator: 1}
+	}
+	return Rational{Numerator: sign * int(h1), Denominator: int(k1)}
+}
+
+// ifdReader reads the values of an IFD.
+type ifdReader struct {
+	ifd         *exif.Ifd
+	allowedTags map[string]bool // Names of the tags that can be read, nil for every tag
+}
+
+// value returns the value of a tag, or nil when missing, unreadable or not
+// allowed.
+func (r ifdReader) value(tagId uint16) interface{} {
+	entries, err := r.ifd.FindTagWithId(tagId)
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+	if r.allowedTags != nil && !r.allowedTags[entries[0].TagName()] {
+		return nil
+	}
+	value, err := entries[0].Value()
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// rationals returns the values of a rational tag as floats. Values with a
+// zero denominator, used for unknown values, are rejected.
+func (r ifdReader) rationals(tagId uint16, count int) ([]float64, bool) {
+	var values []float64
+	switch v := r.value(tagId).(type) {
+	case []exifcommon.Rational:
+		for _, rational := range v {
+			if rational.Denominator == 0 {
+				return nil, false
+			}
+			values = append(values, float64(rational.Numerator)/float64(rational.Denominator))
+		}
+	case []exifcommon.SignedRational:
+		for _, rational := range v {
+			if rational.Denominator == 0 {
+				return nil, false
+			}
+			values = append(values, float64(rational.Numerator)/float64(rational.Denominator))
+		}
+	}
+	if len(values) < count {
+		return nil, false
+	}
+	return values, true
+}
+
+// rational returns the value of a single rational tag.
+func (r ifdReader) rational(tagId uint16) (float64, bool) {
+	values, ok := r.rationals(tagId, 1)
+	if !ok {
+		return 0, false
+	}
+	return values[0], true
+}
+
+// exactRational returns the value of a single rational tag, signed or not,
+// as a Rational.
+func (r ifdReader) exactRational(tagId uint16) (Rational, bool) {
+	switch v := r.value(tagId).(type) {
+	case []exifcommon.Rational:
+		if len(v) > 0 && v[0].Denominator != 0 && v[0].Numerator <= math.MaxInt32 && v[0].Denominator <= math.MaxInt32 {
+			return Rational{Numerator: int(v[0].Numerator), Denominator: int(v[0].Denominator)}, true
+		}
+	case []exifcommon.SignedRational:
+		if len(v) > 0 && v[0].Denominator != 0 {
+			r := Rational{Numerator: int(v[0].Numerator), Denominator: int(v[0].Denominator)}
+			if r.Denominator < 0 {
+				r.Numerator, r.Denominator = -r.Numerator, -r.Denominator
+			}
+			return r, true
+		}
+	}
+	return Rational{}, false
+}
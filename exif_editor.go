@@ -0,0 +1,396 @@
+package media_image
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// IFD paths used when editing EXIF data.
+const (
+	ifdPathRoot = "IFD"
+	ifdPathExif = "IFD/Exif"
+	ifdPathGps  = "IFD/GPSInfo"
+	ifdPathIop  = "IFD/Exif/Iop"
+)
+
+// exifTimeLayout is the layout of the EXIF date tags.
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// ifdIdentities maps the IFD paths to their identity.
+var ifdIdentities = map[string]*exifcommon.IfdIdentity{
+	ifdPathRoot: exifcommon.IfdStandardIfdIdentity,
+	ifdPathExif: exifcommon.IfdExifStandardIfdIdentity,
+	ifdPathGps:  exifcommon.IfdGpsInfoStandardIfdIdentity,
+	ifdPathIop:  exifcommon.IfdExifIopStandardIfdIdentity,
+}
+
+// childIfdTagIds maps the name of a child IFD to the tag pointing to it.
+var childIfdTagIds = map[string]uint16{
+	"Exif":    exifcommon.IfdExifStandardIfdIdentity.TagId(),
+	"GPSInfo": exifcommon.IfdGpsInfoStandardIfdIdentity.TagId(),
+	"Iop":     exifcommon.IfdExifIopStandardIfdIdentity.TagId(),
+}
+
+// exifEditor gives access to the IFD chain of a file being edited.
+// Values are encoded with the byte order of the original EXIF data.
+type exifEditor struct {
+	rootIb    *exif.IfdBuilder
+	byteOrder binary.ByteOrder
+}
+
+// newExifEditor creates an editor over an existing IFD chain parsed from
+// rawExif, or over an empty one when rootIfd is nil.
+func newExifEditor(rootIfd *exif.Ifd, rawExif []byte) *exifEditor {
+	if rootIfd == nil {
+		byteOrder := exifcommon.EncodeDefaultByteOrder
+		return &exifEditor{
+			rootIb:    exif.NewIfdBuilder(exifIfdMapping, exifTagIndex, exifcommon.IfdStandardIfdIdentity, byteOrder),
+			byteOrder: byteOrder,
+		}
+	}
+
+	e := &exifEditor{
+		rootIb:    exif.NewIfdBuilderFromExistingChain(rootIfd),
+		byteOrder: rootIfd.ByteOrder(),
+	}
+	e.restoreUndefinedTags(rootIfd, e.rootIb, rawExif)
+	return e
+}
+
+// restoreUndefinedTags copies the original bytes of the UNDEFINED tags into
+// the builders. go-exif decodes and re-encodes these values when building from
+// an existing chain, which changes the size of some of them (e.g. SceneType).
+func (e *exifEditor) restoreUndefinedTags(ifd *exif.Ifd, ib *exif.IfdBuilder, rawExif []byte) {
+	for ; ifd != nil && ib != nil; ifd = ifd.NextIfd() {
+		for i, ite := range ifd.Entries() {
+			if ite.TagType() != exifcommon.TypeUndefined || ite.ChildIfdPath() != "" {
+				continue
+			}
+
+			// Entries are 12 bytes long and follow the 2-byte entry count
+			entry := int(ifd.Offset()) + 2 + 12*i
+			if entry+12 > len(rawExif) || e.byteOrder.Uint16(rawExif[entry:]) != ite.TagId() {
+				continue
+			}
+			count := int(ite.UnitCount())
+			raw := rawExif[entry+8 : entry+12]
+			if count > 4 {
+				offset := int(e.byteOrder.Uint32(raw))
+				if offset < 0 || offset+count > len(rawExif) {
+					continue
+				}
+				raw = rawExif[offset : offset+count]
+			}
+
+			bt := exif.NewBuilderTag(ib.IfdIdentity().UnindexedString(), ite.TagId(), exifcommon.TypeUndefined,
+				exif.NewIfdBuilderTagValueFromBytes(append([]byte{}, raw[:count]...)), e.byteOrder)
+			_ = ib.Set(bt)
+		}
+
+		for _, child := range ifd.Children() {
+			if childIb, err := ib.ChildWithTagId(child.IfdIdentity().TagId()); err == nil {
+				e.restoreUndefinedTags(child, childIb, rawExif)
+			}
+		}
+
+		next, err := ib.NextIb()
+		if err != nil {
+			return
+		}
+		ib = next
+	}
+}
+
+// newExifEditorFromRaw creates an editor over a raw EXIF block, or over an
+// empty IFD chain when the block is empty.
+func newExifEditorFromRaw(rawExif []byte) (*exifEditor, error) {
+	if len(rawExif) == 0 {
+		return newExifEditor(nil, nil), nil
+	}
+
+	_, index, err := exif.Collect(exifIfdMapping, exifTagIndex, rawExif)
+	if err != nil {
+		return nil, err
+	}
+	return newExifEditor(index.RootIfd, rawExif), nil
+}
+
+// encode encodes the IFD chain into a raw EXIF block.
+func (e *exifEditor) encode() ([]byte, error) {
+	return exif.NewIfdByteEncoder().EncodeToExif(e.rootIb)
+}
+
+// ifd returns the builder of the IFD at the given path, without creating it.
+func (e *exifEditor) ifd(ifdPath string) (*exif.IfdBuilder, bool) {
+	ib := e.rootIb
+	for _, name := range strings.Split(ifdPath, "/")[1:] {
+		tagId, ok := childIfdTagIds[name]
+		if !ok {
+			return nil, false
+		}
+		child, err := ib.ChildWithTagId(tagId)
+		if err != nil {
+			return nil, false
+		}
+		ib = child
+	}
+	return ib, true
+}
+
+// has checks if the tag is present in the IFD at the given path.
+func (e *exifEditor) has(ifdPath string, tagName string) bool {
+	ib, ok := e.ifd(ifdPath)
+	if !ok {
+		return false
+	}
+	_, err := ib.FindTagWithName(tagName)
+	return err == nil
+}
+
+// set sets the value of a standard tag in the IFD at the given path, creating
+// the IFD when needed. The value is encoded with a type supported by the tag:
+// string, []byte, []uint16, []uint32, []exifcommon.Rational or []exifcommon.SignedRational.
+func (e *exifEditor) set(ifdPath string, tagName string, value interface{}) error {
+	ii, ok := ifdIdentities[ifdPath]
+	if !ok {
+		return fmt.Errorf("unknown IFD: %s", ifdPath)
+	}
+	it, err := exifTagIndex.GetWithName(ii, tagName)
+	if err != nil {
+		return fmt.Errorf("unknown tag %s in %s: %w", tagName, ifdPath, err)
+	}
+
+	tagType, value, err := e.convert(it, value)
+	if err != nil {
+		return fmt.Errorf("failed to set tag %s: %w", tagName, err)
+	}
+
+	encoded, err := exifcommon.NewValueEncoder(e.byteOrder).Encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode tag %s: %w", tagName, err)
+	}
+
+	return e.setRaw(ifdPath, it.Id, tagType, encoded.Encoded)
+}
+
+// setRaw sets the already encoded value of a tag in the IFD at the given path.
+func (e *exifEditor) setRaw(ifdPath string, tagId uint16, tagType exifcommon.TagTypePrimitive, raw []byte) error {
+	ib, err := exif.GetOrCreateIbFromRootIb(e.rootIb, ifdPath)
+	if err != nil {
+		return err
+	}
+
+	bt := exif.NewBuilderTag(ifdPath, tagId, tagType, exif.NewIfdBuilderTagValueFromBytes(raw), e.byteOrder)
+	if err := ib.Set(bt); err != nil {
+		return err
+	}
+
+	// Creating the IFD may have appended its pointer to the parent IFDs
+	parts := strings.Split(ifdPath, "/")
+	for i := range parts {
+		if parent, ok := e.ifd(strings.Join(parts[:i+1], "/")); ok {
+			sortIfdTags(parent)
+		}
+	}
+	return nil
+}
+
+// sortIfdTags sorts the entries of an IFD by tag ID, as required by the TIFF
+// specification, since new tags are appended by the builder.
+func sortIfdTags(ib *exif.IfdBuilder) {
+	sort.SliceStable(ib.Tags(), func(a, b int) bool {
+		return builderTagId(ib.Tags()[a]) < builderTagId(ib.Tags()[b])
+	})
+}
+
+// builderTagId returns the ID of a tag, which the builder does not expose.
+func builderTagId(bt *exif.BuilderTag) uint16 {
+	return uint16(reflect.ValueOf(bt).Elem().FieldByName("tagId").Uint())
+}
+
+// replace sets the value of a standard tag only if it is already present.
+func (e *exifEditor) replace(ifdPath string, tagName string, value interface{}) error {
+	if !e.has(ifdPath, tagName) {
+		return nil
+	}
+	return e.set(ifdPath, tagName, value)
+}
+
+// delete removes every occurrence of the tag from the IFD at the given path.
+func (e *exifEditor) delete(ifdPath string, tagName string) error {
+	ii, ok := ifdIdentities[ifdPath]
+	if !ok {
+		return fmt.Errorf("unknown IFD: %s", ifdPath)
+	}
+	it, err := exifTagIndex.GetWithName(ii, tagName)
+	if err != nil {
+		return fmt.Errorf("unknown tag %s in %s: %w", tagName, ifdPath, err)
+	}
+	return e.deleteId(ifdPath, it.Id)
+}
+
+// deleteId removes every occurrence of the tag ID from the IFD at the given path.
+func (e *exifEditor) deleteId(ifdPath string, tagId uint16) error {
+	ib, ok := e.ifd(ifdPath)
+	if !ok {
+		return nil
+	}
+	_, err := ib.DeleteAll(tagId)
+	return err
+}
+
+// convert selects the type the value is encoded with and converts the value to it.
+func (e *exifEditor) convert(it *exif.IndexedTag, value interface{}) (exifcommon.TagTypePrimitive, interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if it.DoesSupportType(exifcommon.TypeAscii) {
+			return exifcommon.TypeAscii, v, nil
+		}
+
+	case []byte:
+		if it.DoesSupportType(exifcommon.TypeByte) {
+			return exifcommon.TypeByte, v, nil
+		}
+
+	case []uint16:
+		if it.DoesSupportType(exifcommon.TypeShort) {
+			return exifcommon.TypeShort, v, nil
+		}
+		if it.DoesSupportType(exifcommon.TypeLong) {
+			values := make([]uint32, len(v))
+			for i, value := range v {
+				values[i] = uint32(value)
+			}
+			return exifcommon.TypeLong, values, nil
+		}
+
+	case []uint32:
+		if it.DoesSupportType(exifcommon.TypeLong) {
+			return exifcommon.TypeLong, v, nil
+		}
+		if it.DoesSupportType(exifcommon.TypeShort) {
+			values := make([]uint16, len(v))
+			for i, value := range v {
+				if value > 0xffff {
+					return 0, nil, fmt.Errorf("value %d overflows a SHORT", value)
+				}
+				values[i] = uint16(value)
+			}
+			return exifcommon.TypeShort, values, nil
+		}
+
+	case []exifcommon.Rational:
+		if it.DoesSupportType(exifcommon.TypeRational) {
+			return exifcommon.TypeRational, v, nil
+		}
+		if it.DoesSupportType(exifcommon.TypeSignedRational) {
+			values := make([]exifcommon.SignedRational, len(v))
+			for i, value := range v {
+				values[i] = exifcommon.SignedRational{Numerator: int32(value.Numerator), Denominator: int32(value.Denominator)}
+			}
+			return exifcommon.TypeSignedRational, values, nil
+		}
+
+	case []exifcommon.SignedRational:
+		if it.DoesSupportType(exifcommon.TypeSignedRational) {
+			return exifcommon.TypeSignedRational, v, nil
+		}
+		if it.DoesSupportType(exifcommon.TypeRational) {
+			values := make([]exifcommon.Rational, len(v))
+			for i, value := range v {
+				if value.Numerator < 0 || value.Denominator < 0 {
+					return 0, nil, fmt.Errorf("negative value for an unsigned RATIONAL")
+				}
+				values[i] = exifcommon.Rational{Numerator: uint32(value.Numerator), Denominator: uint32(value.Denominator)}
+			}
+			return exifcommon.TypeRational, values, nil
+		}
+
+	default:
+		return 0, nil, fmt.Errorf("unsupported value type %T", value)
+	}
+
+	return 0, nil, fmt.Errorf("value type %T not supported by the tag (%v)", value, it.SupportedTypes)
+}
+
+// isEmpty checks if the IFD chain holds no tag at all.
+func (e *exifEditor) isEmpty() bool {
+	next, err := e.rootIb.NextIb()
+	return len(e.rootIb.Tags()) == 0 && err == nil && next == nil
+}
+
+// deleteWhere removes the tags matching the predicate from every IFD of the
+// chain, drops the child IFDs left empty, and returns the paths of the
+// removed tags.
+func (e *exifEditor) deleteWhere(match func(ifdPath string, tagId uint16) bool) ([]string, error) {
+	var removed []string
+
+	var walk func(ib *exif.IfdBuilder, fqPath string) error
+	walk = func(ib *exif.IfdBuilder, fqPath string) error {
+		ifdPath := ib.IfdIdentity().UnindexedString()
+		for _, bt := range append([]*exif.BuilderTag{}, ib.Tags()...) {
+			tagId := builderTagId(bt)
+
+			if bt.Value().IsIb() {
+				child := bt.Value().Ib()
+				if err := walk(child, fqPath+"/"+child.IfdIdentity().Name()); err != nil {
+					return err
+				}
+				if len(child.Tags()) > 0 {
+					continue
+				}
+			} else if !match(ifdPath, tagId) {
+				continue
+			} else {
+				removed = append(removed, fqPath+"/"+exifTagName(ib.IfdIdentity(), tagId))
+			}
+
+			if _, err := ib.DeleteAll(tagId); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, ib := 0, e.rootIb; ib != nil; i++ {
+		fqPath := ifdPathRoot
+		if i > 0 {
+			fqPath = fmt.Sprintf("%s%d", ifdPathRoot, i)
+		}
+		if err := walk(ib, fqPath); err != nil {
+			return removed, err
+		}
+
+		next, err := ib.NextIb()
+		if err != nil {
+			return removed, err
+		}
+		ib = next
+	}
+
+	return removed, nil
+}
+
+// dropThumbnail removes the IFDs following IFD0, which hold the thumbnail in
+// every format but TIFF, and checks if there were any.
+func (e *exifEditor) dropThumbnail() (bool, error) {
+	next, err := e.rootIb.NextIb()
+	if err != nil || next == nil {
+		return false, err
+	}
+	return true, e.rootIb.SetNextIb(nil)
+}
+
+// exifTagName returns the name of a tag, or its hexadecimal ID when unknown.
+func exifTagName(ii *exifcommon.IfdIdentity, tagId uint16) string {
+	if it, err := exifTagIndex.Get(ii, tagId); err == nil {
+		return it.Name
+	}
+	return fmt.Sprintf("0x%04x", tagId)
+}
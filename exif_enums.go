@@ -0,0 +1,422 @@
+package media_image
+
+import (
+	"fmt"
+	"strings"
+
+	exifundefined "github.com/dsoprea/go-exif/v3/undefined"
+)
+
+// Enumerated tags of the EXIF IFD.
+const (
+	exifTagExposureProgram  = 0x8822
+	exifTagMeteringMode     = 0x9207
+	exifTagLightSource      = 0x9208
+	exifTagFlash            = 0x9209
+	exifTagSensingMethod    = 0xa217
+	exifTagSceneType        = 0xa301
+	exifTagCustomRendered   = 0xa401
+	exifTagWhiteBalance     = 0xa403
+	exifTagSceneCaptureType = 0xa406
+)
+
+// enumFields lists the enumerated fields of ImageData, read from the raw
+// values rather than the formatted strings.
+var enumFields = map[string]bool{
+	"ExposureProgram":  true,
+	"MeteringMode":     true,
+	"LightSource":      true,
+	"Flash":            true,
+	"SensingMethod":    true,
+	"SceneType":        true,
+	"CustomRendered":   true,
+	"WhiteBalance":     true,
+	"SceneCaptureType": true,
+}
+
+// enumString returns the name of an enumerated value, or its raw value when
+// it is not defined.
+func enumString(names map[uint16]string, value uint16) string {
+	if name, ok := names[value]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown (%d)", value)
+}
+
+// Flash is the Flash tag, a bitfield describing the state of the flash. The
+// raw value is the integer value of the type.
+type Flash uint16
+
+// FlashReturn is the state of the strobe return detection.
+type FlashReturn uint16
+
+// Strobe return detection states.
+const (
+	FlashReturnNoDetection FlashReturn = 0 // No detection function
+	FlashReturnNotDetected FlashReturn = 2
+	FlashReturnDetected    FlashReturn = 3
+)
+
+// FlashMode is the firing mode of the flash.
+type FlashMode uint16
+
+// Flash firing modes.
+const (
+	FlashModeUnknown     FlashMode = 0
+	FlashModeCompulsory  FlashMode = 1 // Compulsory firing
+	FlashModeSuppression FlashMode = 2 // Compulsory suppression
+	FlashModeAuto        FlashMode = 3
+)
+
+var flashModeNames = map[uint16]string{
+	uint16(FlashModeUnknown):     "Unknown mode",
+	uint16(FlashModeCompulsory):  "Compulsory",
+	uint16(FlashModeSuppression): "Suppressed",
+	uint16(FlashModeAuto):        "Auto",
+}
+
+// String returns the name of the strobe return detection state.
+func (r FlashReturn) String() string {
+	switch r {
+	case FlashReturnNotDetected:
+		return "Return not detected"
+	case FlashReturnDetected:
+		return "Return detected"
+	}
+	return "No detection function"
+}
+
+// String returns the name of the flash mode.
+func (m FlashMode) String() string {
+	return flashModeNames[uint16(m)]
+}
+
+// Fired checks if the flash fired.
+func (f Flash) Fired() bool {
+	return f&0x01 != 0
+}
+
+// Return returns the state of the strobe return detection.
+func (f Flash) Return() FlashReturn {
+	return FlashReturn(f>>1) & 0x03
+}
+
+// Mode returns the firing mode of the flash.
+func (f Flash) Mode() FlashMode {
+	return FlashMode(f>>3) & 0x03
+}
+
+// Present checks if the camera has a flash function.
+func (f Flash) Present() bool {
+	return f&0x20 == 0
+}
+
+// RedEyeReduction checks if the red-eye reduction was on.
+func (f Flash) RedEyeReduction() bool {
+	return f&0x40 != 0
+}
+
+// String returns the state of the flash, e.g. "Fired, Auto, Return detected".
+func (f Flash) String() string {
+	if !f.Present() {
+		return "No flash function"
+	}
+
+	parts := []string{"Did not fire"}
+	if f.Fired() {
+		parts[0] = "Fired"
+	}
+	if f.Mode() != FlashModeUnknown {
+		parts = append(parts, flashModeNames[uint16(f.Mode())])
+	}
+	switch f.Return() {
+	case FlashReturnNotDetected:
+		parts = append(parts, "Return not detected")
+	case FlashReturnDetected:
+		parts = append(parts, "Return detected")
+	}
+	if f.RedEyeReduction() {
+		parts = append(parts, "Red-eye reduction")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MeteringMode is the MeteringMode tag. The raw value is the integer value
+// of the type.
+type MeteringMode uint16
+
+// Metering modes.
+const (
+	MeteringModeUnknown               MeteringMode = 0
+	MeteringModeAverage               MeteringMode = 1
+	MeteringModeCenterWeightedAverage MeteringMode = 2
+	MeteringModeSpot                  MeteringMode = 3
+	MeteringModeMultiSpot             MeteringMode = 4
+	MeteringModePattern               MeteringMode = 5
+	MeteringModePartial               MeteringMode = 6
+	MeteringModeOther                 MeteringMode = 255
+)
+
+var meteringModeNames = map[uint16]string{
+	0:   "Unknown",
+	1:   "Average",
+	2:   "Center-weighted average",
+	3:   "Spot",
+	4:   "Multi-spot",
+	5:   "Pattern",
+	6:   "Partial",
+	255: "Other",
+}
+
+// String returns the name of the metering mode.
+func (m MeteringMode) String() string {
+	return enumString(meteringModeNames, uint16(m))
+}
+
+// ExposureProgram is the ExposureProgram tag. The raw value is the integer
+// value of the type.
+type ExposureProgram uint16
+
+// Exposure programs.
+const (
+	ExposureProgramNotDefined       ExposureProgram = 0
+	ExposureProgramManual           ExposureProgram = 1
+	ExposureProgramNormal           ExposureProgram = 2
+	ExposureProgramAperturePriority ExposureProgram = 3
+	ExposureProgramShutterPriority  ExposureProgram = 4
+	ExposureProgramCreative         ExposureProgram = 5 // Biased toward depth of field
+	ExposureProgramAction           ExposureProgram = 6 // Biased toward fast shutter speed
+	ExposureProgramPortrait         ExposureProgram = 7
+	ExposureProgramLandscape        ExposureProgram = 8
+)
+
+var exposureProgramNames = map[uint16]string{
+	0: "Not defined",
+	1: "Manual",
+	2: "Normal program",
+	3: "Aperture priority",
+	4: "Shutter priority",
+	5: "Creative program",
+	6: "Action program",
+	7: "Portrait mode",
+	8: "Landscape mode",
+}
+
+// String returns the name of the exposure program.
+func (p ExposureProgram) String() string {
+	return enumString(exposureProgramNames, uint16(p))
+}
+
+// WhiteBalance is the WhiteBalance tag. The raw value is the integer value
+// of the type.
+type WhiteBalance uint16
+
+// White balance modes.
+const (
+	WhiteBalanceAuto   WhiteBalance = 0
+	WhiteBalanceManual WhiteBalance = 1
+)
+
+var whiteBalanceNames = map[uint16]string{
+	0: "Auto",
+	1: "Manual",
+}
+
+// String returns the name of the white balance mode.
+func (w WhiteBalance) String() string {
+	return enumString(whiteBalanceNames, uint16(w))
+}
+
+// SceneCaptureType is the SceneCaptureType tag. The raw value is the integer
+// value of the type.
+type SceneCaptureType uint16
+
+// Scene capture types.
+const (
+	SceneCaptureTypeStandard  SceneCaptureType = 0
+	SceneCaptureTypeLandscape SceneCaptureType = 1
+	SceneCaptureTypePortrait  SceneCaptureType = 2
+	SceneCaptureTypeNight     SceneCaptureType = 3
+)
+
+var sceneCaptureTypeNames = map[uint16]string{
+	0: "Standard",
+	1: "Landscape",
+	2: "Portrait",
+	3: "Night scene",
+}
+
+// String returns the name of the scene capture type.
+func (s SceneCaptureType) String() string {
+	return enumString(sceneCaptureTypeNames, uint16(s))
+}
+
+// LightSource is the LightSource tag. The raw value is the integer value of
+// the type.
+type LightSource uint16
+
+// Light sources.
+const (
+	LightSourceUnknown     LightSource = 0
+	LightSourceDaylight    LightSource = 1
+	LightSourceFluorescent LightSource = 2
+	LightSourceTungsten    LightSource = 3
+	LightSourceFlash       LightSource = 4
+	LightSourceOther       LightSource = 255
+)
+
+var lightSourceNames = map[uint16]string{
+	0:   "Unknown",
+	1:   "Daylight",
+	2:   "Fluorescent",
+	3:   "Tungsten",
+	4:   "Flash",
+	9:   "Fine weather",
+	10:  "Cloudy weather",
+	11:  "Shade",
+	12:  "Daylight fluorescent",
+	13:  "Day white fluorescent",
+	14:  "Cool white fluorescent",
+	15:  "White fluorescent",
+	16:  "Warm white fluorescent",
+	17:  "Standard light A",
+	18:  "Standard light B",
+	19:  "Standard light C",
+	20:  "D55",
+	21:  "D65",
+	22:  "D75",
+	23:  "D50",
+	24:  "ISO studio tungsten",
+	255: "Other",
+}
+
+// String returns the name of the light source.
+func (l LightSource) String() string {
+	return enumString(lightSourceNames, uint16(l))
+}
+
+// SceneType is the SceneType tag. The raw value is the integer value of the
+// type.
+type SceneType uint16
+
+// SceneTypeDirectlyPhotographed is the only scene type defined by EXIF.
+const SceneTypeDirectlyPhotographed SceneType = 1
+
+var sceneTypeNames = map[uint16]string{
+	1: "Directly photographed",
+}
+
+// String returns the name of the scene type, empty when missing.
+func (s SceneType) String() string {
+	if s == 0 {
+		return ""
+	}
+	return enumString(sceneTypeNames, uint16(s))
+}
+
+// SensingMethod is the SensingMethod tag. The raw value is the integer value
+// of the type.
+type SensingMethod uint16
+
+// Sensing methods.
+const (
+	SensingMethodNotDefined            SensingMethod = 1
+	SensingMethodOneChipColorArea      SensingMethod = 2
+	SensingMethodTwoChipColorArea      SensingMethod = 3
+	SensingMethodThreeChipColorArea    SensingMethod = 4
+	SensingMethodColorSequentialArea   SensingMethod = 5
+	SensingMethodTrilinear             SensingMethod = 7
+	SensingMethodColorSequentialLinear SensingMethod = 8
+)
+
+var sensingMethodNames = map[uint16]string{
+	1: "Not defined",
+	2: "One-chip color area",
+	3: "Two-chip color area",
+	4: "Three-chip color area",
+	5: "Color sequential area",
+	7: "Trilinear",
+	8: "Color sequential linear",
+}
+
+// String returns the name of the sensing method, empty when missing.
+func (s SensingMethod) String() string {
+	if s == 0 {
+		return ""
+	}
+	return enumString(sensingMethodNames, uint16(s))
+}
+
+// CustomRendered is the CustomRendered tag. The raw value is the integer
+// value of the type.
+type CustomRendered uint16
+
+// Custom rendering processes. Values above 1 are written by Apple devices.
+const (
+	CustomRenderedNormal      CustomRendered = 0
+	CustomRenderedCustom      CustomRendered = 1
+	CustomRenderedHDR         CustomRendered = 2 // HDR, original not saved
+	CustomRenderedHDRSaved    CustomRendered = 3 // HDR, original saved
+	CustomRenderedHDROriginal CustomRendered = 4 // Original of an HDR image
+	CustomRenderedPanorama    CustomRendered = 6
+	CustomRenderedPortraitHDR CustomRendered = 7
+	CustomRenderedPortrait    CustomRendered = 8
+)
+
+var customRenderedNames = map[uint16]string{
+	0: "Normal",
+	1: "Custom",
+	2: "HDR (no original saved)",
+	3: "HDR (original saved)",
+	4: "Original (for HDR)",
+	6: "Panorama",
+	7: "Portrait HDR",
+	8: "Portrait",
+}
+
+// String returns the name of the rendering process.
+func (c CustomRendered) String() string {
+	return enumString(customRenderedNames, uint16(c))
+}
+
+// short returns the value of a single SHORT or UNDEFINED tag.
+func (r ifdReader) short(tagId uint16) (uint16, bool) {
+	switch v := r.value(tagId).(type) {
+	case []uint16:
+		if len(v) > 0 {
+			return v[0], true
+		}
+	case []uint32:
+		if len(v) > 0 && v[0] <= 0xffff {
+			return uint16(v[0]), true
+		}
+	case []byte:
+		if len(v) > 0 {
+			return uint16(v[0]), true
+		}
+	case exifundefined.TagExifA301SceneType:
+		return uint16(v), true
+	}
+	return 0, false
+}
+
+// parseEnums sets the enumerated fields of the image data, and their Has
+// flags, from the EXIF IFD read by r.
+func parseEnums(r ifdReader, imageData *ImageData) {
+	fields := map[uint16]func(uint16){
+		exifTagExposureProgram:  func(v uint16) { imageData.ExposureProgram, imageData.HasExposureProgram = ExposureProgram(v), true },
+		exifTagMeteringMode:     func(v uint16) { imageData.MeteringMode, imageData.HasMeteringMode = MeteringMode(v), true },
+		exifTagLightSource:      func(v uint16) { imageData.LightSource, imageData.HasLightSource = LightSource(v), true },
+		exifTagFlash:            func(v uint16) { imageData.Flash, imageData.HasFlash = Flash(v), true },
+		exifTagSensingMethod:    func(v uint16) { imageData.SensingMethod, imageData.HasSensingMethod = SensingMethod(v), true },
+		exifTagSceneType:        func(v uint16) { imageData.SceneType, imageData.HasSceneType = SceneType(v), true },
+		exifTagCustomRendered:   func(v uint16) { imageData.CustomRendered, imageData.HasCustomRendered = CustomRendered(v), true },
+		exifTagWhiteBalance:     func(v uint16) { imageData.WhiteBalance, imageData.HasWhiteBalance = WhiteBalance(v), true },
+		exifTagSceneCaptureType: func(v uint16) { imageData.SceneCaptureType, imageData.HasSceneCaptureType = SceneCaptureType(v), true },
+	}
+	for tagId, set := range fields {
+		if value, ok := r.short(tagId); ok {
+			set(value)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package media_image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExifEnums(t *testing.T) {
+	t.Log("Testing EXIF enumerations")
+
+	t.Run("flash", func(t *testing.T) {
+		tests := []struct {
+			value    Flash
+			expected string
+		}{
+			{0x00, "Did not fire"},
+			{0x01, "Fired"},
+			{0x10, "Did not fire, Suppressed"},
+			{0x19, "Fired, Auto"},
+			{0x1f, "Fired, Auto, Return detected"},
+			{0x0d, "Fired, Compulsory, Return not detected"},
+			{0x59, "Fired, Auto, Red-eye reduction"},
+			{0x20, "No flash function"},
+		}
+		for _, tt := range tests {
+			assert.Equal(t, tt.expected, tt.value.String(), "0x%02x", uint16(tt.value))
+		}
+
+		flash := Flash(0x5f)
+		assert.True(t, flash.Fired())
+		assert.True(t, flash.Present())
+		assert.True(t, flash.RedEyeReduction())
+		assert.Equal(t, FlashReturnDetected, flash.Return())
+		assert.Equal(t, FlashModeAuto, flash.Mode())
+		assert.Equal(t, uint16(0x5f), uint16(flash))
+	})
+
+	t.Run("string", func(t *testing.T) {
+		assert.Equal(t, "Center-weighted average", MeteringModeCenterWeightedAverage.String())
+		assert.Equal(t, "Aperture priority", ExposureProgramAperturePriority.String())
+		assert.Equal(t, "Manual", WhiteBalanceManual.String())
+		assert.Equal(t, "Night scene", SceneCaptureTypeNight.String())
+		assert.Equal(t, "D65", LightSource(21).String())
+		assert.Equal(t, "Directly photographed", SceneTypeDirectlyPhotographed.String())
+		assert.Equal(t, "One-chip color area", SensingMethodOneChipColorArea.String())
+		assert.Equal(t, "Portrait", CustomRenderedPortrait.String())
+
+		// Undefined values keep their raw value
+		assert.Equal(t, "Unknown (42)", MeteringMode(42).String())
+		assert.Equal(t, "Unknown (5)", LightSource(5).String())
+		assert.Empty(t, SensingMethod(0).String())
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		data := loadImage(t, "samples/jpg/gps/gps-1.jpg").ImageData
+		assert.Equal(t, Flash(0x10), data.Flash)
+		assert.False(t, data.Flash.Fired())
+		assert.Equal(t, MeteringModePattern, data.MeteringMode)
+		assert.Equal(t, ExposureProgramNormal, data.ExposureProgram)
+		assert.Equal(t, WhiteBalanceAuto, data.WhiteBalance)
+		assert.Equal(t, SceneCaptureTypeStandard, data.SceneCaptureType)
+		assert.Equal(t, SceneTypeDirectlyPhotographed, data.SceneType)
+		assert.True(t, data.HasFlash)
+		assert.True(t, data.HasWhiteBalance)
+		assert.True(t, data.HasSceneCaptureType)
+
+		data = loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg").ImageData
+		assert.Equal(t, SensingMethodOneChipColorArea, data.SensingMethod)
+		assert.Equal(t, MeteringModePattern, data.MeteringMode)
+
+		// Missing tags are not their zero values
+		assert.False(t, data.HasWhiteBalance)
+		assert.False(t, data.HasSceneCaptureType)
+	})
+
+	t.Run("write", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		i := loadImage(t, path)
+		data := i.ImageData
+		data.Flash = 0x19
+		data.MeteringMode = MeteringModeSpot
+		data.WhiteBalance = WhiteBalanceManual
+		data.LightSource = LightSourceDaylight
+		data.CustomRendered = CustomRenderedHDR
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded := loadImage(t, path).ImageData
+		assert.Equal(t, Flash(0x19), reloaded.Flash)
+		assert.Equal(t, MeteringModeSpot, reloaded.MeteringMode)
+		assert.Equal(t, WhiteBalanceManual, reloaded.WhiteBalance)
+		assert.Equal(t, LightSourceDaylight, reloaded.LightSource)
+		assert.Equal(t, CustomRenderedHDR, reloaded.CustomRendered)
+		assert.Equal(t, ExposureProgramNormal, reloaded.ExposureProgram)
+
+		// A flash that did not fire is written, and removed with its flag
+		i = loadImage(t, path)
+		data = i.ImageData
+		data.Flash = 0
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		reloaded = loadImage(t, path).ImageData
+		assert.True(t, reloaded.HasFlash)
+		assert.Equal(t, Flash(0), reloaded.Flash)
+		assert.Contains(t, i.Provenance, "Flash")
+
+		data = i.ImageData
+		data.HasFlash = false
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, loadImage(t, path).ImageData.HasFlash)
+		assert.NotContains(t, i.Provenance, "Flash")
+	})
+}
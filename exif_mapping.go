@@ -0,0 +1,399 @@
+package media_image
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	exifundefined "github.com/dsoprea/go-exif/v3/undefined"
+	"github.com/go-mods/tags"
+)
+
+// ExifUnmarshaler is implemented by types that decode themselves from the
+// tag entry their field is mapped to by ParseInto.
+type ExifUnmarshaler interface {
+	UnmarshalExif(entry ExifTagEntry) error
+}
+
+var (
+	exifUnmarshalerType = reflect.TypeOf((*ExifUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	rationalType        = reflect.TypeOf(Rational{})
+)
+
+// ParseInto parses raw EXIF data into a new value of T, a struct type whose
+// fields carry exif tags as ImageData does. See ExifDataParser.ParseInto.
+func ParseInto[T any](exifData []byte) (T, error) {
+	var dst T
+	err := NewExifDataParser().ParseInto(exifData, &dst)
+	return dst, err
+}
+
+// ParseInto sets the fields of the struct pointed to by dst from raw EXIF
+// data. Fields are mapped with the exif tags of ImageData, e.g.
+// `exif:"Model,CameraModel"`, the first tag found being used. Unlike Parse,
+// values are read from the raw tag values and no field is derived.
+//
+// Supported fields are strings, booleans, signed and unsigned integers,
+// floats, time.Time and Rational, along with:
+//   - pointers, allocated when their tag is found;
+//   - slices, holding every value of multi-value tags, e.g. []float64 for
+//     GPSLatitude, or the raw bytes for []byte;
+//   - types implementing ExifUnmarshaler.
+//
+// Struct fields without exif tag, or pointers to such structs, are mapped
+// recursively. Fields failing to convert are left unset, and their errors
+// are returned joined once every other field is set.
+//
+// Parameters:
+//   - exifData: Raw EXIF data bytes from the image
+//   - dst: Non-nil pointer to the struct to populate
+//
+// Returns:
+//   - error: Any error encountered while reading the tags or setting the
+//     fields, a PanicError when a panic occurs while setting them
+func (p *ExifDataParser) ParseInto(exifData []byte, dst any) (err error) {
+	defer recoverPanic("exif", &err)
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("destination must be a non-nil pointer to a struct, got %T", dst)
+	}
+
+	entries, err := p.Tags(exifData)
+	if err != nil {
+		return err
+	}
+
+	// The first entry of each tag is used, leaving the thumbnail out as Parse does
+	byName := make(map[string]ExifTagEntry)
+	for _, entry := range entries {
+		if entry.TagName == "" || entry.IfdPath == exif.ThumbnailFqIfdPath {
+			continue
+		}
+		if _, ok := byName[entry.TagName]; !ok {
+			byName[entry.TagName] = entry
+		}
+	}
+
+	_, err = p.mapStruct(v.Elem(), byName, "")
+	return err
+}
+
+// mapStruct sets the fields of a struct from the tag entries.
+//
+// Parameters:
+//   - v: Reflect.Value of the struct
+//   - entries: Tag entries by tag name
+//   - path: Path of the struct, prefixing the field names in errors
+//
+// Returns:
+//   - bool: Whether any field was set
+//   - error: The joined errors of the fields that could not be set
+func (p *ExifDataParser) mapStruct(v reflect.Value, entries map[string]ExifTagEntry, path string) (bool, error) {
+	var errs []error
+	set := false
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldValue := v.Field(i)
+		if !fieldValue.CanSet() {
+			continue
+		}
+		name := path + field.Name
+
+		fieldTags, err := p.getExifTags(field)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Fields without exif tag are only descended into
+		if len(fieldTags) == 0 {
+			nestedSet, err := p.mapNested(fieldValue, entries, name+".")
+			if err != nil {
+				errs = append(errs, err)
+			}
+			set = set || nestedSet
+			continue
+		}
+
+		entry, ok := lookupTagEntry(entries, fieldTags)
+		if !ok {
+			continue
+		}
+		if err := p.setFieldFromTag(fieldValue, entry); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		set = true
+	}
+
+	return set, errors.Join(errs...)
+}
+
+// mapNested maps a struct field without exif tag, or a pointer to one,
+// allocating the pointer only when a field of the struct is set.
+func (p *ExifDataParser) mapNested(field reflect.Value, entries map[string]ExifTagEntry, path string) (bool, error) {
+	switch {
+	case field.Kind() == reflect.Struct && field.Type() != timeType && field.Type() != rationalType:
+		return p.mapStruct(field, entries, path)
+
+	case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct:
+		nested := reflect.New(field.Type().Elem())
+		if field.IsNil() {
+			set, err := p.mapStruct(nested.Elem(), entries, path)
+			if set {
+				field.Set(nested)
+			}
+			return set, err
+		}
+		return p.mapStruct(field.Elem(), entries, path)
+	}
+	return false, nil
+}
+
+// lookupTagEntry returns the entry of the first tag name of the exif tags
+// found in the entries, as getValueFromMetadata does.
+func lookupTagEntry(entries map[string]ExifTagEntry, fieldTags []*tags.Tag) (ExifTagEntry, bool) {
+	for _, tag := range fieldTags {
+		for _, name := range strings.Split(tag.Value, ",") {
+			if entry, ok := entries[name]; ok {
+				return entry, true
+			}
+		}
+	}
+	return ExifTagEntry{}, false
+}
+
+// setFieldFromTag sets a field from a tag entry.
+//
+// Parameters:
+//   - field: Reflect.Value of the field to set
+//   - entry: Tag entry the field is mapped to
+//
+// Returns:
+//   - error: Any error encountered while converting the value
+func (p *ExifDataParser) setFieldFromTag(field reflect.Value, entry ExifTagEntry) error {
+	// Unmarshalers decode the entry themselves
+	if field.CanAddr() && field.Addr().Type().Implements(exifUnmarshalerType) {
+		return field.Addr().Interface().(ExifUnmarshaler).UnmarshalExif(entry)
+	}
+
+	switch field.Kind() {
+	case reflect.Pointer:
+		value := reflect.New(field.Type().Elem())
+		if err := p.setFieldFromTag(value.Elem(), entry); err != nil {
+			return err
+		}
+		field.Set(value)
+		return nil
+
+	case reflect.Slice:
+		// Byte slices take the raw bytes of byte and opaque tags
+		if field.Type().Elem().Kind() == reflect.Uint8 && (entry.Type == exifcommon.TypeByte || entry.Type == exifcommon.TypeUndefined) {
+			field.SetBytes(append([]byte(nil), entry.Raw...))
+			return nil
+		}
+
+		values := tagScalars(entry)
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := p.setScalar(slice.Index(i), value); err != nil {
+				return fmt.Errorf("value %d: %w", i, err)
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	values := tagScalars(entry)
+	if len(values) == 0 {
+		return fmt.Errorf("no value for tag %s", entry.TagName)
+	}
+	return p.setScalar(field, values[0])
+}
+
+// tagScalars returns the values of a tag entry as uint64, int64, float64,
+// Rational or string values.
+func tagScalars(entry ExifTagEntry) []interface{} {
+	var values []interface{}
+	switch v := entry.Values.(type) {
+	case nil:
+	case string:
+		values = append(values, strings.TrimRight(v, "\x00"))
+	case []byte:
+		for _, n := range v {
+			values = append(values, uint64(n))
+		}
+	case []uint16:
+		for _, n := range v {
+			values = append(values, uint64(n))
+		}
+	case []uint32:
+		for _, n := range v {
+			values = append(values, uint64(n))
+		}
+	case []int32:
+		for _, n := range v {
+			values = append(values, int64(n))
+		}
+	case []float32:
+		for _, f := range v {
+			values = append(values, float64(f))
+		}
+	case []float64:
+		for _, f := range v {
+			values = append(values, f)
+		}
+	case []exifcommon.Rational:
+		for _, r := range v {
+			values = append(values, Rational{Numerator: int(r.Numerator), Denominator: int(r.Denominator)})
+		}
+	case []exifcommon.SignedRational:
+		for _, r := range v {
+			values = append(values, Rational{Numerator: int(r.Numerator), Denominator: int(r.Denominator)})
+		}
+	case exifundefined.Tag9286UserComment:
+		values = append(values, strings.TrimRight(string(v.EncodingBytes), "\x00 "))
+	default:
+		// Opaque values decoded by the library, numeric ones being integers
+		if rv := reflect.ValueOf(v); rv.CanUint() {
+			values = append(values, rv.Uint())
+		} else {
+			values = append(values, entry.Formatted)
+		}
+	}
+	return values
+}
+
+// setScalar sets a field from a single value of a tag.
+//
+// Parameters:
+//   - field: Reflect.Value of the field to set
+//   - value: Value as returned by tagScalars
+//
+// Returns:
+//   - error: Any error encountered while converting the value
+func (p *ExifDataParser) setScalar(field reflect.Value, value interface{}) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(fmt.Sprint(value))
+		return nil
+
+	case reflect.Bool:
+		if s, ok := value.(string); ok {
+			b, err := strconv.ParseBool(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("failed to parse bool: %v", err)
+			}
+			field.SetBool(b)
+			return nil
+		}
+		f, err := scalarFloat(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(f != 0)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, err := scalarFloat(value)
+		if err != nil {
+			return err
+		}
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || field.OverflowInt(int64(f)) {
+			return fmt.Errorf("value %v out of range for %s", value, field.Type())
+		}
+		field.SetInt(int64(f))
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, err := scalarFloat(value)
+		if err != nil {
+			return err
+		}
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 || field.OverflowUint(uint64(f)) {
+			return fmt.Errorf("value %v out of range for %s", value, field.Type())
+		}
+		field.SetUint(uint64(f))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := scalarFloat(value)
+		if err != nil {
+			return err
+		}
+		if field.OverflowFloat(f) {
+			return fmt.Errorf("value %v out of range for %s", value, field.Type())
+		}
+		field.SetFloat(f)
+		return nil
+
+	case reflect.Struct:
+		switch field.Type() {
+		case timeType:
+			t, err := p.parseTime(strings.TrimSpace(fmt.Sprint(value)))
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(t))
+			return nil
+
+		case rationalType:
+			switch v := value.(type) {
+			case Rational:
+				field.Set(reflect.ValueOf(v))
+				return nil
+			case uint64:
+				if v > math.MaxInt32 {
+					return fmt.Errorf("value %d out of range for %s", v, field.Type())
+				}
+				field.Set(reflect.ValueOf(Rational{Numerator: int(v), Denominator: 1}))
+				return nil
+			case int64:
+				field.Set(reflect.ValueOf(Rational{Numerator: int(v), Denominator: 1}))
+				return nil
+			}
+			r, err := NewRational(fmt.Sprint(value))
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(r))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unsupported field type %s", field.Type())
+}
+
+// scalarFloat converts a value of a tag to a float.
+func scalarFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case uint64:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case Rational:
+		if v.Denominator == 0 {
+			return 0, fmt.Errorf("invalid rational %s", v)
+		}
+		return v.Float(), nil
+	case string:
+		f, ok := parseExifNumber(v)
+		if !ok {
+			return 0, fmt.Errorf("failed to parse number: %q", v)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("unsupported value %v", value)
+}
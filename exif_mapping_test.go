@@ -0,0 +1,110 @@
+package media_image
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cameraTag is a custom type decoding itself from the entry.
+type cameraTag struct {
+	Ifd   string
+	Value string
+}
+
+func (c *cameraTag) UnmarshalExif(entry ExifTagEntry) error {
+	c.Ifd = entry.IfdPath
+	c.Value = fmt.Sprint(entry.Values)
+	return nil
+}
+
+type mappedCamera struct {
+	Make   string     `exif:"Make"`
+	Model  *string    `exif:"Model,CameraModel"`
+	Custom *cameraTag `exif:"Model"`
+}
+
+type mappedExposure struct {
+	ISO          uint16    `exif:"ISOSpeedRatings"`
+	ISO64        int64     `exif:"ISOSpeedRatings"`
+	FNumber      float32   `exif:"FNumber"`
+	ExposureTime Rational  `exif:"ExposureTime"`
+	Flash        Flash     `exif:"Flash"`
+	FlashFired   bool      `exif:"Flash"`
+	Taken        time.Time `exif:"DateTimeOriginal"`
+}
+
+type mappedImage struct {
+	Camera   mappedCamera
+	Exposure *mappedExposure
+	Missing  *struct {
+		Value string `exif:"NoSuchTag"`
+	}
+	Latitude []float64 `exif:"GPSLatitude"`
+	Version  []byte    `exif:"ExifVersion"`
+	Unknown  string    `exif:"NoSuchTag"`
+	ignored  string    `exif:"Make"`
+}
+
+func Test_ParseInto(t *testing.T) {
+	t.Log("Testing the mapping of EXIF data into structs")
+
+	i := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+	rawExif, err := NewExifParser().Parse(i.path(), i.FileType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("nested", func(t *testing.T) {
+		image, err := ParseInto[mappedImage](rawExif)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "NIKON", image.Camera.Make)
+		if assert.NotNil(t, image.Camera.Model) {
+			assert.Equal(t, "COOLPIX P6000", *image.Camera.Model)
+		}
+		if assert.NotNil(t, image.Camera.Custom) {
+			assert.Equal(t, ifdPathRoot, image.Camera.Custom.Ifd)
+			assert.Equal(t, "COOLPIX P6000", image.Camera.Custom.Value)
+		}
+		assert.Nil(t, image.Missing)
+		assert.Empty(t, image.Unknown)
+		assert.Empty(t, image.ignored)
+
+		// Multi-value tags map to slices
+		assert.Len(t, image.Latitude, 3)
+		assert.Equal(t, 43.0, image.Latitude[0])
+		assert.Equal(t, 28.0, image.Latitude[1])
+		assert.Equal(t, []byte("0220"), image.Version)
+	})
+
+	t.Run("types", func(t *testing.T) {
+		exposure, err := ParseInto[mappedExposure](rawExif)
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(64), exposure.ISO)
+		assert.Equal(t, int64(64), exposure.ISO64)
+		assert.Equal(t, float32(4.5), exposure.FNumber)
+		assert.Equal(t, Rational{560852, 100000000}, exposure.ExposureTime)
+		assert.Equal(t, Flash(16), exposure.Flash)
+		assert.True(t, exposure.FlashFired) // Any non-zero value
+		assert.Equal(t, time.Date(2008, 10, 22, 16, 29, 49, 0, time.UTC), exposure.Taken)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		// Fractional seconds do not fit an integer, the other fields are still set
+		var timestamp struct {
+			Make    string `exif:"Make"`
+			Seconds []uint `exif:"GPSTimeStamp"`
+		}
+		err := NewExifDataParser().ParseInto(rawExif, &timestamp)
+		assert.ErrorContains(t, err, "Seconds")
+		assert.Equal(t, "NIKON", timestamp.Make)
+
+		assert.Error(t, NewExifDataParser().ParseInto(rawExif, timestamp))
+		assert.Error(t, NewExifDataParser().ParseInto(rawExif, (*mappedImage)(nil)))
+		_, err = ParseInto[mappedCamera]([]byte("not exif"))
+		assert.Error(t, err)
+	})
+}
@@ -12,15 +12,49 @@ import (
 )
 
 // ExifParser is a struct that contains the EXIF parser.
-type ExifParser struct{}
+type ExifParser struct {
+	limits Limits
+}
 
-// NewExifParser creates a new ExifParser struct.
+// NewExifParser creates a new ExifParser struct, enforcing DefaultLimits.
 func NewExifParser() *ExifParser {
-	return new(ExifParser)
+	return &ExifParser{limits: DefaultLimits}
+}
+
+// SetLimits sets the limits the files and their EXIF data are checked
+// against, of which MaxFileSize and MaxExifSize apply to the parser.
+func (p *ExifParser) SetLimits(limits Limits) {
+	p.limits = limits
+}
+
+// Parse parses the EXIF data from the file. Errors match ErrUnsupportedFileType,
+// ErrNoExif, ErrCorrupt, ErrTruncated or ErrLimitExceeded, file system errors
+// excepted. Panics of the metadata libraries on malformed files are returned
+// as a PanicError.
+func (p *ExifParser) Parse(path string, fileType types.FileType) (rawExif []byte, err error) {
+	defer recoverPanic(string(fileType), &err)
+
+	// The metadata libraries read the whole file
+	if err := p.limits.checkFileSize(path); err != nil {
+		return nil, err
+	}
+
+	rawExif, err = p.parse(path, fileType)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+
+	// The EXIF data of TIFF files is the file itself
+	if fileType != ImageTiff {
+		if err := checkLimit("EXIF size", int64(len(rawExif)), int64(p.limits.MaxExifSize)); err != nil {
+			return nil, err
+		}
+	}
+	return rawExif, nil
 }
 
-// Parse parses the EXIF data from the file.
-func (p *ExifParser) Parse(path string, fileType types.FileType) ([]byte, error) {
+// parse parses the EXIF data from the file with the parser of its type.
+func (p *ExifParser) parse(path string, fileType types.FileType) ([]byte, error) {
 
 	// Switch on the file type
 	switch fileType {
@@ -46,7 +80,7 @@ func (p *ExifParser) Parse(path string, fileType types.FileType) ([]byte, error)
 		return p.parseRaw(path)
 	}
 
-	return nil, fmt.Errorf("unsupported file type: %s", fileType)
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFileType, fileType)
 }
 
 // parseRaw parses the EXIF data from the file using exif.SearchFileAndExtractExif.
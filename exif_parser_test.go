@@ -0,0 +1,126 @@
+package media_image
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartmediafiles/media/media/types"
+)
+
+// addSeeds adds the samples matching the patterns to the fuzzing corpus.
+func addSeeds(f *testing.F, patterns ...string) {
+	f.Helper()
+
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(data)
+		}
+	}
+}
+
+// addExifSeeds adds the raw EXIF data of the JPEG samples to the fuzzing
+// corpus, as a TIFF stream.
+func addExifSeeds(f *testing.F) {
+	f.Helper()
+
+	paths, err := filepath.Glob("samples/jpg/*/*.jpg")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		rawExif, err := NewExifParser().Parse(path, ImageJpeg)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(rawExif)
+	}
+}
+
+// fuzzExifParser parses the fuzzed data as a file of the type, checking
+// that errors are classified and that the extracted EXIF data is parsed.
+func fuzzExifParser(f *testing.F, fileType types.FileType) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "fuzz."+string(fileType))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		rawExif, err := NewExifParser().Parse(path, fileType)
+		if err != nil {
+			if !errors.Is(err, ErrCorrupt) && !errors.Is(err, ErrTruncated) && !errors.Is(err, ErrNoExif) && !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("unclassified error: %v", err)
+			}
+			return
+		}
+		_, _ = NewExifDataParser().Parse(rawExif)
+	})
+}
+
+func FuzzExifParser_Jpeg(f *testing.F) {
+	addSeeds(f, "samples/jpg/*/*.jpg")
+	fuzzExifParser(f, ImageJpeg)
+}
+
+func FuzzExifParser_Heic(f *testing.F) {
+	addSeeds(f, "samples/heic/*.heic", "samples/heif/*.heif")
+	fuzzExifParser(f, ImageHeic)
+}
+
+func FuzzExifParser_Png(f *testing.F) {
+	// There are no PNG samples, an encoded image gives the structure of the format
+	var data bytes.Buffer
+	if err := png.Encode(&data, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data.Bytes())
+	fuzzExifParser(f, ImagePng)
+}
+
+func FuzzExifParser_Tiff(f *testing.F) {
+	// There are no TIFF samples, raw EXIF data being a TIFF stream
+	addExifSeeds(f)
+	fuzzExifParser(f, ImageTiff)
+}
+
+func FuzzExifParser_Gif(f *testing.F) {
+	addSeeds(f, "samples/gif/*.gif")
+	fuzzExifParser(f, ImageGif)
+}
+
+func FuzzExifParser_Webp(f *testing.F) {
+	addSeeds(f, "samples/webp/*.webp")
+	fuzzExifParser(f, ImageWebp)
+}
+
+func FuzzExifDataParser(f *testing.F) {
+	addExifSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p := NewExifDataParser()
+		_, _ = p.Parse(data)
+		_, _ = p.Tags(data)
+
+		var dst struct {
+			Make      string    `exif:"Make"`
+			DateTime  string    `exif:"DateTimeOriginal"`
+			ISO       int       `exif:"ISOSpeedRatings"`
+			Latitude  []float64 `exif:"GPSLatitude"`
+			Exposure  Rational  `exif:"ExposureTime"`
+			UserNotes []byte    `exif:"UserComment"`
+		}
+		_ = p.ParseInto(data, &dst)
+	})
+}
@@ -0,0 +1,156 @@
+package media_image
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// ExifTagEntry is a tag of the EXIF data as recorded, for the tags ImageData
+// does not model.
+type ExifTagEntry struct {
+	IfdPath   string // Fully-qualified path of the IFD, e.g. "IFD/Exif", or "IFD1" for the thumbnail
+	TagId     uint16
+	TagName   string // Empty when the tag is unknown
+	Type      exifcommon.TagTypePrimitive
+	TypeName  string // e.g. "SHORT" or "RATIONAL"
+	Count     uint32 // Number of values, or of bytes for ASCII and UNDEFINED tags
+	ByteOrder binary.ByteOrder
+
+	// Raw is the encoded value, in ByteOrder
+	Raw []byte
+
+	// Values holds every decoded value: a string for ASCII tags, []byte for
+	// BYTE tags, slices of uint16, uint32, int32, exifcommon.Rational or
+	// exifcommon.SignedRational for numeric tags, and a type of the
+	// exifundefined package for known UNDEFINED tags, []byte for unknown
+	// ones. It is nil when the value cannot be decoded.
+	Values interface{}
+
+	// Formatted is the human-readable form of every value, empty when the
+	// value cannot be decoded
+	Formatted string
+
+	// ChildIfdPath is the path of the IFD the tag points to, empty for a
+	// value tag
+	ChildIfdPath string
+}
+
+// String returns a short description of the tag entry.
+func (e ExifTagEntry) String() string {
+	return fmt.Sprintf("ExifTagEntry<IFD=[%s] ID=(0x%04x) NAME=[%s] TYPE=[%s] COUNT=(%d) VALUE=[%s]>", e.IfdPath, e.TagId, e.TagName, e.TypeName, e.Count, e.Formatted)
+}
+
+// Tags returns every tag entry of the raw EXIF data, in the order of the
+// IFDs, including the duplicates across IFDs and the thumbnail IFD.
+//
+// Parameters:
+//   - exifData: Raw EXIF data bytes from the image
+//
+// Returns:
+//   - []ExifTagEntry: Tag entries of every IFD
+//   - error: Any error encountered while reading the IFDs, matching
+//     ErrLimitExceeded when the data exceeds the limits of the parser, a
+//     PanicError when the EXIF library panics on malformed data
+func (p *ExifDataParser) Tags(exifData []byte) (entries []ExifTagEntry, err error) {
+	defer func() {
+		if err != nil {
+			entries = nil
+		}
+	}()
+	defer recoverPanic("exif", &err)
+
+	if err := checkLimit("EXIF size", int64(len(exifData)), int64(p.limits.MaxExifSize)); err != nil {
+		return nil, err
+	}
+
+	header, err := exif.ParseExifHeader(exifData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EXIF header: %w", classifyError(err))
+	}
+
+	limiter := newIfdLimiter(p.limits)
+	enumerate := exif.NewIfdEnumerate(exifIfdMapping, exifTagIndex, exif.NewExifReadSeekerWithBytes(exifData), header.ByteOrder)
+	visitor := func(ite *exif.IfdTagEntry) error {
+		if err := limiter.visit(ite); err != nil {
+			return err
+		}
+		entries = append(entries, p.tagEntry(ite, header.ByteOrder))
+		return nil
+	}
+	if _, err := enumerate.Scan(exifcommon.IfdStandardIfdIdentity, header.FirstIfdOffset, visitor, nil); err != nil {
+		if limiter.err != nil {
+			return nil, limiter.err
+		}
+		return nil, fmt.Errorf("failed to read EXIF tags: %w", classifyError(err))
+	}
+
+	return entries, nil
+}
+
+// tagEntry converts an entry of the library, keeping what it can of the
+// values it fails to decode.
+//
+// Parameters:
+//   - ite: Tag entry of the library
+//   - byteOrder: Byte order of the EXIF data
+//
+// Returns:
+//   - ExifTagEntry: The converted tag entry
+func (p *ExifDataParser) tagEntry(ite *exif.IfdTagEntry, byteOrder binary.ByteOrder) ExifTagEntry {
+	entry := ExifTagEntry{
+		IfdPath:      ite.IfdPath(),
+		TagId:        ite.TagId(),
+		TagName:      ite.TagName(),
+		Type:         ite.TagType(),
+		TypeName:     ite.TagType().String(),
+		Count:        ite.UnitCount(),
+		ByteOrder:    byteOrder,
+		ChildIfdPath: ite.ChildIfdPath(),
+	}
+
+	// Tags of another IFD than the one they are defined in, e.g. TIFF/EP
+	// tags in IFD0, are only known by their ID
+	if entry.TagName == "" {
+		if it, err := exifTagIndex.FindFirst(entry.TagId, entry.Type, nil); err == nil {
+			entry.TagName = it.Name
+		}
+	}
+
+	if raw, err := ite.GetRawBytes(); err == nil {
+		// Values of up to 4 bytes are read from the padded offset field
+		if size := int(entry.Count) * tagTypeSize(entry.Type); size > 0 && size < len(raw) {
+			raw = raw[:size]
+		}
+		entry.Raw = raw
+	}
+	if values, err := ite.Value(); err == nil {
+		entry.Values = values
+		if formatted, err := ite.Format(); err == nil {
+			entry.Formatted = formatted
+		}
+	} else if entry.Type == exifcommon.TypeUndefined && entry.Raw != nil {
+		// Unknown UNDEFINED tags are opaque bytes
+		entry.Values = entry.Raw
+	}
+
+	return entry
+}
+
+// tagTypeSize returns the size, in bytes, of a value of a tag type, 0 when
+// the type is unknown.
+func tagTypeSize(tagType exifcommon.TagTypePrimitive) int {
+	switch tagType {
+	case exifcommon.TypeByte, exifcommon.TypeAscii, exifcommon.TypeAsciiNoNul, exifcommon.TypeUndefined:
+		return 1
+	case exifcommon.TypeShort:
+		return 2
+	case exifcommon.TypeLong, exifcommon.TypeSignedLong, exifcommon.TypeFloat:
+		return 4
+	case exifcommon.TypeRational, exifcommon.TypeSignedRational, exifcommon.TypeDouble:
+		return 8
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package media_image
+
+import (
+	"encoding/binary"
+	"testing"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExifTags(t *testing.T) {
+	t.Log("Testing the raw tag dump")
+
+	i := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+	rawExif, err := NewExifParser().Parse(i.path(), i.FileType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := NewExifDataParser().Tags(rawExif)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(ifdPath string, tagName string) (ExifTagEntry, bool) {
+		for _, entry := range entries {
+			if entry.IfdPath == ifdPath && entry.TagName == tagName {
+				return entry, true
+			}
+		}
+		return ExifTagEntry{}, false
+	}
+
+	t.Run("duplicates", func(t *testing.T) {
+		// The main image and the thumbnail both have a resolution
+		main, ok := find(ifdPathRoot, "XResolution")
+		assert.True(t, ok)
+		assert.Equal(t, "[300/1]", main.Formatted)
+		thumbnail, ok := find("IFD1", "XResolution")
+		assert.True(t, ok)
+		assert.Equal(t, "[72/1]", thumbnail.Formatted)
+	})
+
+	t.Run("multiple values", func(t *testing.T) {
+		latitude, ok := find(ifdPathGps, "GPSLatitude")
+		assert.True(t, ok)
+		assert.Equal(t, uint16(gpsTagLatitude), latitude.TagId)
+		assert.Equal(t, exifcommon.TypeRational, latitude.Type)
+		assert.Equal(t, "RATIONAL", latitude.TypeName)
+		assert.Equal(t, uint32(3), latitude.Count)
+		assert.Len(t, latitude.Raw, 24)
+
+		values, ok := latitude.Values.([]exifcommon.Rational)
+		assert.True(t, ok)
+		assert.Len(t, values, 3)
+		assert.Equal(t, exifcommon.Rational{Numerator: 43, Denominator: 1}, values[0])
+
+		// The raw bytes decode to the same values
+		assert.Equal(t, uint32(43), latitude.ByteOrder.Uint32(latitude.Raw[0:4]))
+		assert.Equal(t, uint32(28), latitude.ByteOrder.Uint32(latitude.Raw[8:12]))
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		flash, ok := find(ifdPathExif, "Flash")
+		assert.True(t, ok)
+		assert.Equal(t, []uint16{16}, flash.Values)
+		assert.Len(t, flash.Raw, 2)
+		assert.Equal(t, binary.LittleEndian, flash.ByteOrder)
+
+		// Values shorter than 4 bytes are not padded
+		sceneType, ok := find(ifdPathExif, "SceneType")
+		assert.True(t, ok)
+		assert.Equal(t, []byte{1}, sceneType.Raw)
+
+		pointer, ok := find(ifdPathRoot, "GPSTag")
+		assert.True(t, ok)
+		assert.Equal(t, ifdPathGps, pointer.ChildIfdPath)
+	})
+
+	_, err = NewExifDataParser().Tags([]byte("not exif"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,439 @@
+package media_image
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dsoprea/go-exif/v3"
+	jpegstructure "github.com/dsoprea/go-jpeg-image-structure/v2"
+	pngstructure "github.com/dsoprea/go-png-image-structure/v2"
+	"github.com/smartmediafiles/media/media/types"
+)
+
+// maxJpegSegmentSize is the largest payload of a JPEG segment
+// (65535 bytes minus the two bytes of the segment length).
+const maxJpegSegmentSize = 65535 - 2
+
+// metadataEdit describes a modification of the metadata blocks of a file.
+// A nil function leaves the corresponding block untouched.
+type metadataEdit struct {
+	// exif edits the EXIF data, which is empty when the file has none.
+	exif func(editor *exifEditor) error
+
+	// xmp receives the XMP packet and returns its new content.
+	// Returning a nil packet removes the XMP block.
+	xmp func(packet []byte) ([]byte, error)
+
+	// stripText removes the free-form text blocks: JPEG comments, PNG text
+	// chunks and GIF comments.
+	stripText bool
+
+	// stripIptc removes the IPTC and Photoshop blocks of JPEG files.
+	stripIptc bool
+
+	// removed is notified of the blocks removed by stripText and stripIptc.
+	removed func(block string)
+}
+
+// notifyRemoved reports a removed block to the edit, if it asked for it.
+func (edit metadataEdit) notifyRemoved(block string) {
+	if edit.removed != nil {
+		edit.removed(block)
+	}
+}
+
+// ExifWriter is a struct that contains the EXIF writer.
+type ExifWriter struct{}
+
+// NewExifWriter creates a new ExifWriter struct.
+func NewExifWriter() *ExifWriter {
+	return new(ExifWriter)
+}
+
+// update applies the edit to the metadata of the file and writes it back.
+// Pixel data and unrelated segments are kept as they are.
+func (w *ExifWriter) update(path string, fileType types.FileType, edit metadataEdit) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var updated []byte
+	switch fileType {
+	case ImageGif:
+		updated, err = w.updateGif(data, edit)
+
+	case ImageHeic, ImageHeif:
+		updated, err = w.updateHeic(data, edit)
+
+	case ImageJpeg:
+		updated, err = w.updateJpeg(data, edit)
+
+	case ImagePng:
+		updated, err = w.updatePng(data, edit)
+
+	case ImageTiff:
+		updated, err = w.updateTiff(data, edit)
+
+	case ImageWebp:
+		updated, err = w.updateWebp(data, edit)
+
+	default:
+		return fmt.Errorf("%w for writing: %s", ErrUnsupportedFileType, fileType)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, updated)
+}
+
+// updateJpeg applies the edit to the APP1 segments of a JPEG file.
+func (w *ExifWriter) updateJpeg(data []byte, edit metadataEdit) ([]byte, error) {
+	jpegMediaParser := jpegstructure.NewJpegMediaParser()
+	mediaContext, err := jpegMediaParser.ParseBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	segments := mediaContext.(*jpegstructure.SegmentList)
+
+	if edit.exif != nil {
+		rootIfd, rawExif, err := segments.Exif()
+		if err != nil && !errors.Is(err, exif.ErrNoExif) {
+			return nil, err
+		}
+
+		editor := newExifEditor(rootIfd, rawExif)
+		if err := edit.exif(editor); err != nil {
+			return nil, err
+		}
+
+		if editor.isEmpty() {
+			// Drop the segment rather than writing an empty EXIF block
+			if index, _, err := segments.FindExif(); err == nil {
+				all := segments.Segments()
+				segments = jpegstructure.NewSegmentList(append(all[:index:index], all[index+1:]...))
+			}
+		} else {
+			if err := segments.SetExif(editor.rootIb); err != nil {
+				return nil, err
+			}
+			if _, segment, err := segments.FindExif(); err == nil && len(segment.Data) > maxJpegSegmentSize {
+				return nil, fmt.Errorf("EXIF block too large for a JPEG segment: %d bytes", len(segment.Data))
+			}
+		}
+	}
+
+	if edit.xmp != nil {
+		if segments, err = w.updateJpegXmp(segments, edit.xmp); err != nil {
+			return nil, err
+		}
+		if segments, err = w.updateJpegXmpExtensions(segments, edit.xmp); err != nil {
+			return nil, err
+		}
+	}
+
+	if edit.stripText || edit.stripIptc {
+		var kept []*jpegstructure.Segment
+		for _, segment := range segments.Segments() {
+			switch {
+			case edit.stripText && segment.MarkerId == jpegstructure.MARKER_COM:
+				edit.notifyRemoved("JPEG comment")
+			case edit.stripIptc && segment.MarkerId == jpegstructure.MARKER_APP13:
+				edit.notifyRemoved("IPTC")
+			default:
+				kept = append(kept, segment)
+			}
+		}
+		segments = jpegstructure.NewSegmentList(kept)
+	}
+
+	b := new(bytes.Buffer)
+	if err := segments.Write(b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// updateJpegXmp applies the XMP edit to the XMP segment of a JPEG file, if any.
+func (w *ExifWriter) updateJpegXmp(segments *jpegstructure.SegmentList, edit func([]byte) ([]byte, error)) (*jpegstructure.SegmentList, error) {
+	index, segment, err := segments.FindXmp()
+	if errors.Is(err, jpegstructure.ErrNoXmp) {
+		return segments, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	packet, err := edit(segment.Data[len(xmpJpegPrefix):])
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the segment when the packet is dropped
+	if packet == nil {
+		all := segments.Segments()
+		return jpegstructure.NewSegmentList(append(all[:index:index], all[index+1:]...)), nil
+	}
+
+	segment.Data = append(append([]byte{}, xmpJpegPrefix...), packet...)
+	if len(segment.Data) > maxJpegSegmentSize {
+		return nil, fmt.Errorf("XMP packet too large for a JPEG segment: %d bytes", len(segment.Data))
+	}
+	return segments, nil
+}
+
+// updateJpegXmpExtensions applies the XMP edit to the extended XMP packets of
+// a JPEG file, and points the main packet to their new GUID.
+func (w *ExifWriter) updateJpegXmpExtensions(segments *jpegstructure.SegmentList, edit func([]byte) ([]byte, error)) (*jpegstructure.SegmentList, error) {
+	all := segments.Segments()
+	extensions, err := jpegXmpExtensions(all)
+	if err != nil || len(extensions) == 0 {
+		return segments, err
+	}
+
+	guids := make(map[string]string)                   // New GUID by old one, empty for a removed packet
+	inserted := make(map[int][]*jpegstructure.Segment) // New segments by index of the first old one
+	removed := make(map[int]bool)
+	for _, extension := range extensions {
+		packet, err := edit(extension.packet)
+		if err != nil {
+			return nil, err
+		}
+		if packet != nil && bytes.Equal(packet, extension.packet) {
+			continue
+		}
+
+		for _, index := range extension.segments {
+			removed[index] = true
+		}
+		guids[extension.guid] = ""
+		if packet != nil {
+			guids[extension.guid], inserted[extension.segments[0]] = encodeXmpExtension(packet)
+		}
+	}
+	if len(guids) == 0 {
+		return segments, nil
+	}
+
+	var kept []*jpegstructure.Segment
+	for index, segment := range all {
+		kept = append(kept, inserted[index]...)
+		if !removed[index] {
+			kept = append(kept, segment)
+		}
+	}
+
+	return w.updateJpegXmp(jpegstructure.NewSegmentList(kept), func(packet []byte) ([]byte, error) {
+		guid, ok := xmpPropertyValue(packet, xmpExtensionProperty)
+		newGuid, changed := guids[guid]
+		switch {
+		case !ok || !changed:
+			return packet, nil
+		case newGuid == "":
+			packet, _ = removeXmpProperty(packet, xmpExtensionProperty)
+			return packet, nil
+		}
+		return mapXmpProperty(packet, xmpExtensionProperty, func(string) (string, bool) { return newGuid, true }), nil
+	})
+}
+
+// updatePng applies the edit to the eXIf and iTXt chunks of a PNG file.
+func (w *ExifWriter) updatePng(data []byte, edit metadataEdit) ([]byte, error) {
+	pngMediaParser := pngstructure.NewPngMediaParser()
+	mediaContext, err := pngMediaParser.ParseBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	chunks := mediaContext.(*pngstructure.ChunkSlice)
+
+	if edit.exif != nil {
+		rootIfd, rawExif, err := chunks.Exif()
+		if err != nil && !errors.Is(err, exif.ErrNoExif) {
+			return nil, err
+		}
+
+		editor := newExifEditor(rootIfd, rawExif)
+		if err := edit.exif(editor); err != nil {
+			return nil, err
+		}
+
+		if editor.isEmpty() {
+			chunks = w.filterPngChunks(chunks, func(chunk *pngstructure.Chunk) bool { return chunk.Type != "eXIf" })
+		} else if err := chunks.SetExif(editor.rootIb); err != nil {
+			return nil, err
+		}
+
+		// Text chunks stripped below are not worth editing
+		if !edit.stripText {
+			if chunks, err = w.updatePngRawProfiles(chunks, edit.exif); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if edit.xmp != nil {
+		if chunks, err = w.updatePngXmp(chunks, edit.xmp); err != nil {
+			return nil, err
+		}
+	}
+
+	if edit.stripText {
+		chunks = w.filterPngChunks(chunks, func(chunk *pngstructure.Chunk) bool {
+			switch chunk.Type {
+			case "tEXt", "zTXt":
+			case "iTXt":
+				if bytes.HasPrefix(chunk.Data, xmpPngKeyword) {
+					return true
+				}
+			default:
+				return true
+			}
+			edit.notifyRemoved("PNG " + chunk.Type)
+			return false
+		})
+	}
+
+	b := new(bytes.Buffer)
+	if err := chunks.WriteTo(b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// updatePngRawProfiles applies the EXIF edit to the raw EXIF profiles of a
+// PNG file, the copies of the EXIF data older tools store in text chunks.
+func (w *ExifWriter) updatePngRawProfiles(chunks *pngstructure.ChunkSlice, edit func(editor *exifEditor) error) (*pngstructure.ChunkSlice, error) {
+	var kept []*pngstructure.Chunk
+	for _, chunk := range chunks.Chunks() {
+		if !isPngRawExifProfile(chunk) {
+			kept = append(kept, chunk)
+			continue
+		}
+
+		rawExif, prefix, err := decodePngRawProfile(chunk)
+		if err != nil {
+			return nil, err
+		}
+		editor, err := newExifEditorFromRaw(rawExif)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read raw EXIF profile: %w", err)
+		}
+		if err := edit(editor); err != nil {
+			return nil, err
+		}
+		if editor.isEmpty() {
+			continue
+		}
+
+		if rawExif, err = editor.encode(); err != nil {
+			return nil, err
+		}
+		keyword, _, _ := bytes.Cut(chunk.Data, []byte{0})
+		if chunk, err = encodePngRawProfile(string(keyword), prefix, rawExif); err != nil {
+			return nil, err
+		}
+		kept = append(kept, chunk)
+	}
+	return pngstructure.NewChunkSlice(kept), nil
+}
+
+// updatePngXmp applies the XMP edit to the XMP iTXt chunk of a PNG file, if any.
+func (w *ExifWriter) updatePngXmp(chunks *pngstructure.ChunkSlice, edit func([]byte) ([]byte, error)) (*pngstructure.ChunkSlice, error) {
+	all := chunks.Chunks()
+	for index, chunk := range all {
+		if chunk.Type != "iTXt" || !bytes.HasPrefix(chunk.Data, xmpPngKeyword) {
+			continue
+		}
+
+		// Keyword, compression flag and method, language tag and translated keyword
+		header, packet, ok := splitPngITXt(chunk.Data)
+		if !ok {
+			return nil, fmt.Errorf("compressed or malformed XMP iTXt chunk")
+		}
+
+		packet, err := edit(packet)
+		if err != nil {
+			return nil, err
+		}
+
+		if packet == nil {
+			return pngstructure.NewChunkSlice(append(all[:index:index], all[index+1:]...)), nil
+		}
+
+		chunk.Data = append(append([]byte{}, header...), packet...)
+		chunk.Length = uint32(len(chunk.Data))
+		chunk.UpdateCrc32()
+		return chunks, nil
+	}
+
+	return chunks, nil
+}
+
+// filterPngChunks returns the chunks for which keep returns true.
+func (w *ExifWriter) filterPngChunks(chunks *pngstructure.ChunkSlice, keep func(chunk *pngstructure.Chunk) bool) *pngstructure.ChunkSlice {
+	var kept []*pngstructure.Chunk
+	for _, chunk := range chunks.Chunks() {
+		if keep(chunk) {
+			kept = append(kept, chunk)
+		}
+	}
+	return pngstructure.NewChunkSlice(kept)
+}
+
+// splitPngITXt splits an uncompressed iTXt chunk into its header and its text.
+func splitPngITXt(data []byte) ([]byte, []byte, bool) {
+	// keyword\0
+	keywordEnd := bytes.IndexByte(data, 0)
+	if keywordEnd < 0 || len(data) < keywordEnd+3 || data[keywordEnd+1] != 0 {
+		return nil, nil, false
+	}
+
+	// compression flag, compression method, language\0, translated keyword\0
+	offset := keywordEnd + 3
+	for i := 0; i < 2; i++ {
+		end := bytes.IndexByte(data[offset:], 0)
+		if end < 0 {
+			return nil, nil, false
+		}
+		offset += end + 1
+	}
+
+	return data[:offset], data[offset:], true
+}
+
+// writeFileAtomic writes the data to a temporary file next to the target and
+// renames it over the target, so readers never observe a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, path)
+}
@@ -0,0 +1,226 @@
+package media_image
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// GIF block introducers and extension labels.
+const (
+	gifExtension      = byte(0x21)
+	gifImageSeparator = byte(0x2c)
+	gifTrailer        = byte(0x3b)
+	gifLabelComment   = byte(0xfe)
+	gifLabelApp       = byte(0xff)
+)
+
+// gifXmpIdentifier is the application identifier and authentication code of
+// the XMP application extension.
+var gifXmpIdentifier = []byte("XMP DataXMP")
+
+// gifXmpTrailer returns the "magic trailer" ending the XMP application
+// extension, which makes the raw packet readable as a chain of sub-blocks.
+func gifXmpTrailer() []byte {
+	trailer := make([]byte, 0, 258)
+	trailer = append(trailer, 0x01)
+	for i := 0xff; i >= 0; i-- {
+		trailer = append(trailer, byte(i))
+	}
+	return append(trailer, 0x00)
+}
+
+// updateGif applies the edit to the XMP application extension and the comment
+// extensions of a GIF file. GIF files cannot hold EXIF data.
+func (w *ExifWriter) updateGif(data []byte, edit metadataEdit) ([]byte, error) {
+	if edit.exif != nil {
+		return nil, fmt.Errorf("GIF files cannot hold EXIF data")
+	}
+
+	g, err := newGifReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(bytes.Buffer)
+	b.Write(g.header)
+	for {
+		introducer, label, err := g.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch introducer {
+		case gifTrailer:
+			// Bytes past the trailer are kept as they are
+			rest, err := io.ReadAll(g.r)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteByte(gifTrailer)
+			b.Write(rest)
+			return b.Bytes(), nil
+
+		case gifImageSeparator:
+			image, err := g.image(true)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteByte(gifImageSeparator)
+			b.Write(image)
+
+		case gifExtension:
+			subBlocks, err := g.subBlocks(true, "", 0)
+			if err != nil {
+				return nil, err
+			}
+			block := append([]byte{gifExtension, label}, subBlocks...)
+
+			switch {
+			case label == gifLabelComment && edit.stripText:
+				edit.notifyRemoved("GIF comment")
+
+			case label == gifLabelApp && edit.xmp != nil && isGifXmpExtension(block):
+				block, err := w.updateGifXmp(block, edit.xmp)
+				if err != nil {
+					return nil, err
+				}
+				b.Write(block)
+
+			default:
+				b.Write(block)
+			}
+		}
+	}
+}
+
+// updateGifXmp applies the XMP edit to an XMP application extension.
+func (w *ExifWriter) updateGifXmp(block []byte, edit func([]byte) ([]byte, error)) ([]byte, error) {
+	// Introducer, label, block size and identifier, then the raw packet
+	header := 3 + len(gifXmpIdentifier)
+	trailer := gifXmpTrailer()
+	if len(block) < header+len(trailer) {
+		return nil, fmt.Errorf("malformed GIF XMP extension")
+	}
+
+	packet, err := edit(block[header : len(block)-len(trailer)])
+	if err != nil {
+		return nil, err
+	}
+	if packet == nil {
+		return nil, nil
+	}
+	if bytes.IndexByte(packet, 0) >= 0 {
+		return nil, fmt.Errorf("XMP packet cannot contain null bytes in a GIF file")
+	}
+
+	return append(append(append([]byte{}, block[:header]...), packet...), trailer...), nil
+}
+
+// isGifXmpExtension checks if an application extension holds XMP.
+func isGifXmpExtension(block []byte) bool {
+	return len(block) > 3+len(gifXmpIdentifier) && block[2] == byte(len(gifXmpIdentifier)) &&
+		bytes.Equal(block[3:3+len(gifXmpIdentifier)], gifXmpIdentifier)
+}
+
+// gifReader streams the blocks of a GIF file.
+type gifReader struct {
+	r      *bufio.Reader
+	header []byte // Header, logical screen descriptor and global color table
+}
+
+// newGifReader reads the header of a GIF file, up to its first block.
+func newGifReader(r io.Reader) (*gifReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(header, []byte("GIF8")) {
+		return nil, fmt.Errorf("%w: not a GIF file", ErrCorrupt)
+	}
+	if flags := header[10]; flags&0x80 != 0 {
+		table := make([]byte, 3<<(flags&0x07+1))
+		if _, err := io.ReadFull(br, table); err != nil {
+			return nil, err
+		}
+		header = append(header, table...)
+	}
+	return &gifReader{r: br, header: header}, nil
+}
+
+// next reads the introducer of the next block, and the label of extensions.
+// The rest of the block is read by image or subBlocks.
+func (g *gifReader) next() (introducer byte, label byte, err error) {
+	if introducer, err = g.r.ReadByte(); err != nil {
+		return 0, 0, err
+	}
+	switch introducer {
+	case gifTrailer, gifImageSeparator:
+		return introducer, 0, nil
+	case gifExtension:
+		label, err = g.r.ReadByte()
+		return introducer, label, err
+	}
+	return 0, 0, fmt.Errorf("%w: unexpected GIF block 0x%02x", ErrCorrupt, introducer)
+}
+
+// image reads the rest of an image block: its descriptor, local color table
+// and image data. The bytes read are returned when keep is set.
+func (g *gifReader) image(keep bool) ([]byte, error) {
+	descriptor := make([]byte, 9)
+	if _, err := io.ReadFull(g.r, descriptor); err != nil {
+		return nil, err
+	}
+	raw := descriptor
+	if flags := descriptor[8]; flags&0x80 != 0 {
+		table := make([]byte, 3<<(flags&0x07+1))
+		if _, err := io.ReadFull(g.r, table); err != nil {
+			return nil, err
+		}
+		raw = append(raw, table...)
+	}
+
+	// LZW minimum code size, then the image data
+	codeSize, err := g.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := g.subBlocks(keep, "", 0)
+	if err != nil || !keep {
+		return nil, err
+	}
+	return append(append(raw, codeSize), data...), nil
+}
+
+// subBlocks reads a chain of sub-blocks, returning their bytes, sizes and
+// terminator included, when keep is set. The bytes kept are checked against
+// the limit named name, 0 being no limit.
+func (g *gifReader) subBlocks(keep bool, name string, limit int64) ([]byte, error) {
+	var raw []byte
+	for {
+		size, err := g.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			if _, err := g.r.Discard(int(size)); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := checkLimit(name, int64(len(raw))+1+int64(size), limit); err != nil {
+				return nil, err
+			}
+			raw = append(raw, size)
+			block := make([]byte, size)
+			if _, err := io.ReadFull(g.r, block); err != nil {
+				return nil, err
+			}
+			raw = append(raw, block...)
+		}
+		if size == 0 {
+			return raw, nil
+		}
+	}
+}
@@ -0,0 +1,878 @@
+package media_image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// HEIF item types and content types holding metadata.
+const (
+	heifItemTypeExif = "Exif"
+	heifItemTypeMime = "mime"
+	heifContentXmp   = "application/rdf+xml"
+)
+
+// heifExifPrefix starts the Exif item of a new file: the offset of the TIFF
+// header followed by the usual Exif marker.
+var heifExifPrefix = []byte{0, 0, 0, 6, 'E', 'x', 'i', 'f', 0, 0}
+
+// isoBox is a box of an ISO base media file, located by its offsets in the file.
+type isoBox struct {
+	boxType string
+	start   int
+	header  int
+	end     int
+}
+
+// payload returns the content of the box, after its header.
+func (b isoBox) payload(data []byte) []byte {
+	return data[b.start+b.header : b.end]
+}
+
+// heifItem is an entry of the item information box.
+type heifItem struct {
+	id          uint32
+	itemType    string
+	contentType string
+	raw         []byte
+}
+
+// heifExtent is an extent of an item location.
+type heifExtent struct {
+	index  uint64
+	offset uint64
+	length uint64
+}
+
+// heifLocation is an entry of the item location box.
+type heifLocation struct {
+	id                 uint32
+	constructionMethod uint8
+	dataReferenceIndex uint16
+	baseOffset         uint64
+	extents            []heifExtent
+
+	// data is the new content of the item, and appended tells if it is stored
+	// at the end of the file rather than in place of the previous content.
+	data     []byte
+	appended bool
+}
+
+// heifReference is a reference from an item to other items.
+type heifReference struct {
+	refType string
+	from    uint32
+	to      []uint32
+}
+
+// heifFile gives access to the items of a HEIF file being edited.
+type heifFile struct {
+	data []byte
+	meta isoBox
+
+	// Content of the meta box
+	children   []isoBox
+	items      []heifItem
+	locations  []*heifLocation
+	references []heifReference
+	primary    uint32
+	idat       []byte
+
+	// Header fields of the rewritten boxes
+	iinfVersion    byte
+	ilocVersion    byte
+	ilocSizes      [4]int // offset, length, base offset and index sizes
+	irefVersion    byte
+	itemsChanged   bool
+	removedExtents []heifLocation
+}
+
+// updateHeic applies the edit to the Exif and XMP items of a HEIF file.
+//
+// Items whose new content fits in place are overwritten, others are moved to a
+// new mdat box at the end of the file. The previous content of moved or removed
+// items is zeroed so that no stale metadata remains in the file.
+func (w *ExifWriter) updateHeic(data []byte, edit metadataEdit) ([]byte, error) {
+	heif, err := parseHeifFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if edit.exif != nil {
+		if err := heif.updateExif(edit.exif); err != nil {
+			return nil, err
+		}
+	}
+
+	if edit.xmp != nil {
+		if err := heif.updateXmp(edit.xmp); err != nil {
+			return nil, err
+		}
+	}
+
+	return heif.encode()
+}
+
+// updateExif applies the edit to the Exif item, adding the item when missing
+// and removing it when left empty.
+func (h *heifFile) updateExif(edit func(editor *exifEditor) error) error {
+	prefix := heifExifPrefix
+	var rawExif []byte
+
+	item := h.findItem(func(item heifItem) bool { return item.itemType == heifItemTypeExif })
+	if item != nil {
+		itemData, err := h.itemData(item.id)
+		if err != nil {
+			return err
+		}
+		if len(itemData) < 4 {
+			return fmt.Errorf("malformed HEIF Exif item")
+		}
+		offset := int(binary.BigEndian.Uint32(itemData))
+		if offset > len(itemData)-4 {
+			return fmt.Errorf("malformed HEIF Exif item")
+		}
+		prefix, rawExif = itemData[:4+offset], itemData[4+offset:]
+	}
+
+	editor, err := newExifEditorFromRaw(rawExif)
+	if err != nil {
+		return err
+	}
+	if err := edit(editor); err != nil {
+		return err
+	}
+
+	// Drop the item rather than writing an empty EXIF block
+	if editor.isEmpty() {
+		if item != nil {
+			return h.removeItem(item.id)
+		}
+		return nil
+	}
+	if rawExif, err = editor.encode(); err != nil {
+		return err
+	}
+
+	id := uint32(0)
+	if item != nil {
+		id = item.id
+	} else {
+		id = h.addItem(heifItemTypeExif)
+		h.references = append(h.references, heifReference{refType: "cdsc", from: id, to: []uint32{h.primary}})
+	}
+
+	return h.setItemData(id, append(append([]byte{}, prefix...), rawExif...))
+}
+
+// updateXmp applies the edit to the XMP item, if any.
+func (h *heifFile) updateXmp(edit func(packet []byte) ([]byte, error)) error {
+	item := h.findItem(func(item heifItem) bool {
+		return item.itemType == heifItemTypeMime && item.contentType == heifContentXmp
+	})
+	if item == nil {
+		return nil
+	}
+
+	packet, err := h.itemData(item.id)
+	if err != nil {
+		return err
+	}
+	if packet, err = edit(packet); err != nil {
+		return err
+	}
+
+	if packet == nil {
+		return h.removeItem(item.id)
+	}
+	return h.setItemData(item.id, packet)
+}
+
+// parseHeifFile parses the meta box of a HEIF file.
+func parseHeifFile(data []byte) (*heifFile, error) {
+	boxes, err := parseIsoBoxes(data, 0, len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	h := &heifFile{data: data}
+	found := false
+	for _, box := range boxes {
+		switch box.boxType {
+		case "meta":
+			h.meta, found = box, true
+		case "moov":
+			return nil, fmt.Errorf("HEIF image sequences are not supported for writing")
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no meta box found in HEIF file")
+	}
+
+	// The meta box is a full box: skip its version and flags
+	if h.meta.end-h.meta.start < h.meta.header+4 {
+		return nil, fmt.Errorf("malformed HEIF meta box")
+	}
+	if h.children, err = parseIsoBoxes(data, h.meta.start+h.meta.header+4, h.meta.end); err != nil {
+		return nil, err
+	}
+
+	for _, box := range h.children {
+		r := &heifReader{data: box.payload(data)}
+		switch box.boxType {
+		case "pitm":
+			version := r.fullBox()
+			h.primary = r.id(version == 0)
+		case "iinf":
+			err = h.parseIinf(r)
+		case "iloc":
+			err = h.parseIloc(r)
+		case "iref":
+			err = h.parseIref(r)
+		case "idat":
+			h.idat = r.data
+		}
+		if err == nil {
+			err = r.err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed HEIF %s box: %w", box.boxType, err)
+		}
+	}
+
+	return h, nil
+}
+
+// parseIinf parses the item information box.
+func (h *heifFile) parseIinf(r *heifReader) error {
+	h.iinfVersion = r.fullBox()
+	count := r.id(h.iinfVersion == 0)
+
+	boxes, err := parseIsoBoxes(r.data, r.offset, len(r.data))
+	if err != nil {
+		return err
+	}
+	if len(boxes) != int(count) {
+		return fmt.Errorf("expected %d item entries, found %d", count, len(boxes))
+	}
+
+	for _, box := range boxes {
+		item := heifItem{raw: r.data[box.start:box.end]}
+		entry := &heifReader{data: box.payload(r.data)}
+		version := entry.fullBox()
+		if version < 2 {
+			// Versions 0 and 1 have no item type
+			item.id = uint32(entry.uint(2))
+		} else {
+			item.id = entry.id(version == 2)
+			entry.uint(2) // item_protection_index
+			item.itemType = string(entry.bytes(4))
+			entry.string() // item_name
+			if item.itemType == heifItemTypeMime {
+				item.contentType = entry.string()
+			}
+		}
+		if entry.err != nil {
+			return entry.err
+		}
+		h.items = append(h.items, item)
+	}
+
+	return nil
+}
+
+// parseIloc parses the item location box.
+func (h *heifFile) parseIloc(r *heifReader) error {
+	h.ilocVersion = r.fullBox()
+	sizes := r.uint(2)
+	h.ilocSizes = [4]int{int(sizes >> 12 & 0xf), int(sizes >> 8 & 0xf), int(sizes >> 4 & 0xf), int(sizes & 0xf)}
+	if h.ilocVersion == 0 {
+		h.ilocSizes[3] = 0
+	}
+
+	count := r.id(h.ilocVersion < 2)
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		location := &heifLocation{id: r.id(h.ilocVersion < 2)}
+		if h.ilocVersion > 0 {
+			location.constructionMethod = uint8(r.uint(2) & 0xf)
+		}
+		location.dataReferenceIndex = uint16(r.uint(2))
+		location.baseOffset = r.uint(h.ilocSizes[2])
+
+		extentCount := int(r.uint(2))
+		for j := 0; j < extentCount && r.err == nil; j++ {
+			var extent heifExtent
+			extent.index = r.uint(h.ilocSizes[3])
+			extent.offset = r.uint(h.ilocSizes[0])
+			extent.length = r.uint(h.ilocSizes[1])
+			location.extents = append(location.extents, extent)
+		}
+		h.locations = append(h.locations, location)
+	}
+
+	return r.err
+}
+
+// parseIref parses the item reference box.
+func (h *heifFile) parseIref(r *heifReader) error {
+	h.irefVersion = r.fullBox()
+
+	boxes, err := parseIsoBoxes(r.data, r.offset, len(r.data))
+	if err != nil {
+		return err
+	}
+	for _, box := range boxes {
+		entry := &heifReader{data: box.payload(r.data)}
+		reference := heifReference{refType: box.boxType, from: entry.id(h.irefVersion == 0)}
+		count := int(entry.uint(2))
+		for i := 0; i < count && entry.err == nil; i++ {
+			reference.to = append(reference.to, entry.id(h.irefVersion == 0))
+		}
+		if entry.err != nil {
+			return entry.err
+		}
+		h.references = append(h.references, reference)
+	}
+
+	return nil
+}
+
+// findItem returns the first item matching the predicate, or nil.
+func (h *heifFile) findItem(match func(item heifItem) bool) *heifItem {
+	for i := range h.items {
+		if match(h.items[i]) {
+			return &h.items[i]
+		}
+	}
+	return nil
+}
+
+// location returns the location of an item, or nil.
+func (h *heifFile) location(id uint32) *heifLocation {
+	for _, location := range h.locations {
+		if location.id == id {
+			return location
+		}
+	}
+	return nil
+}
+
+// itemData returns the content of an item.
+func (h *heifFile) itemData(id uint32) ([]byte, error) {
+	location := h.location(id)
+	if location == nil {
+		return nil, fmt.Errorf("no location for HEIF item %d", id)
+	}
+
+	var source []byte
+	switch location.constructionMethod {
+	case 0:
+		source = h.data
+	case 1:
+		source = h.idat
+	default:
+		return nil, fmt.Errorf("unsupported construction method %d for HEIF item %d", location.constructionMethod, id)
+	}
+
+	var itemData []byte
+	for _, extent := range location.extents {
+		start := location.baseOffset + extent.offset
+		end := uint64(len(source))
+		if extent.length > 0 {
+			end = start + extent.length
+		}
+		if start > end || end > uint64(len(source)) {
+			return nil, fmt.Errorf("HEIF item %d out of bounds", id)
+		}
+		itemData = append(itemData, source[start:end]...)
+	}
+	return itemData, nil
+}
+
+// setItemData sets the new content of an item, stored in place when it fits
+// in the single extent of the item, or at the end of the file otherwise.
+func (h *heifFile) setItemData(id uint32, itemData []byte) error {
+	location := h.location(id)
+	if location == nil {
+		location = &heifLocation{id: id}
+		h.locations = append(h.locations, location)
+	}
+
+	if location.constructionMethod == 2 {
+		return fmt.Errorf("unsupported construction method %d for HEIF item %d", location.constructionMethod, id)
+	}
+
+	inPlace := location.constructionMethod == 0 && len(location.extents) == 1 &&
+		location.extents[0].length > 0 && uint64(len(itemData)) <= location.extents[0].length
+	if !inPlace {
+		if location.constructionMethod == 1 && h.ilocVersion == 0 {
+			return fmt.Errorf("unsupported construction method for HEIF item %d", id)
+		}
+		h.removedExtents = append(h.removedExtents, *location)
+		location.constructionMethod = 0
+		location.baseOffset = 0
+		location.extents = []heifExtent{{}}
+	}
+
+	location.data = itemData
+	location.appended = !inPlace
+	return nil
+}
+
+// addItem adds an item of the given type and returns its ID.
+func (h *heifFile) addItem(itemType string) uint32 {
+	id := uint32(0)
+	for _, item := range h.items {
+		if item.id > id {
+			id = item.id
+		}
+	}
+	id++
+
+	b := new(bytes.Buffer)
+	version := byte(2)
+	if id > math.MaxUint16 {
+		version = 3
+	}
+	b.Write([]byte{version, 0, 0, 0})
+	writeHeifId(b, id, version == 2)
+	b.Write([]byte{0, 0}) // item_protection_index
+	b.WriteString(itemType)
+	b.WriteByte(0) // item_name
+
+	h.items = append(h.items, heifItem{id: id, itemType: itemType, raw: isoBoxBytes("infe", b.Bytes())})
+	h.itemsChanged = true
+	return id
+}
+
+// removeItem removes an item along with its location and references.
+func (h *heifFile) removeItem(id uint32) error {
+	items := h.items[:0]
+	for _, item := range h.items {
+		if item.id != id {
+			items = append(items, item)
+		}
+	}
+	h.items = items
+
+	locations := h.locations[:0]
+	for _, location := range h.locations {
+		if location.id != id {
+			locations = append(locations, location)
+		} else if location.data == nil {
+			h.removedExtents = append(h.removedExtents, *location)
+		}
+	}
+	h.locations = locations
+
+	references := h.references[:0]
+	for _, reference := range h.references {
+		if reference.from == id {
+			continue
+		}
+		to := reference.to[:0]
+		for _, toId := range reference.to {
+			if toId != id {
+				to = append(to, toId)
+			}
+		}
+		if len(to) > 0 {
+			reference.to = to
+			references = append(references, reference)
+		}
+	}
+	h.references = references
+
+	h.itemsChanged = true
+	return nil
+}
+
+// encode assembles the edited file.
+func (h *heifFile) encode() ([]byte, error) {
+	out := append([]byte{}, h.data...)
+
+	// Zero the previous content of moved and removed items, in the file or in
+	// the item data box
+	for _, location := range h.removedExtents {
+		var source []byte
+		switch location.constructionMethod {
+		case 0:
+			source = out
+		case 1:
+			idat := h.findChild("idat")
+			if idat < 0 {
+				return nil, fmt.Errorf("cannot clear HEIF item %d", location.id)
+			}
+			source = h.children[idat].payload(out)
+		default:
+			continue
+		}
+		for _, extent := range location.extents {
+			start := location.baseOffset + extent.offset
+			end := start + extent.length
+			if extent.length == 0 && location.constructionMethod == 1 {
+				end = uint64(len(source))
+			}
+			if start >= end || end > uint64(len(source)) {
+				return nil, fmt.Errorf("cannot clear HEIF item %d", location.id)
+			}
+			clear(source[start:end])
+		}
+	}
+
+	// Overwrite the items stored in place
+	mdat := new(bytes.Buffer)
+	for _, location := range h.locations {
+		switch {
+		case location.data == nil:
+		case location.appended:
+			location.extents[0] = heifExtent{offset: uint64(mdat.Len()), length: uint64(len(location.data))}
+			mdat.Write(location.data)
+		default:
+			extent := &location.extents[0]
+			start := location.baseOffset + extent.offset
+			clear(out[start : start+extent.length])
+			copy(out[start:], location.data)
+			extent.length = uint64(len(location.data))
+		}
+	}
+
+	// Rebuilding the meta box moves what follows it, so iterate until the
+	// offsets written in the item location box are stable
+	metaSize := h.meta.end - h.meta.start
+	delta := 0
+	var meta []byte
+	for i := 0; ; i++ {
+		mdatStart := uint64(len(out) + delta + 8)
+
+		var err error
+		if meta, err = h.encodeMeta(out, delta, mdatStart); err != nil {
+			return nil, err
+		}
+		if len(meta)-metaSize == delta {
+			break
+		}
+		if i == 3 {
+			return nil, fmt.Errorf("failed to relocate HEIF items")
+		}
+		delta = len(meta) - metaSize
+	}
+
+	b := new(bytes.Buffer)
+	b.Write(out[:h.meta.start])
+	b.Write(meta)
+	b.Write(out[h.meta.end:])
+	if mdat.Len() > 0 {
+		b.Write(isoBoxBytes("mdat", mdat.Bytes()))
+	}
+	return b.Bytes(), nil
+}
+
+// encodeMeta encodes the meta box, moving the file offsets following it by
+// delta and placing the appended items from mdatStart. The boxes left as they
+// are are copied from data, the file with the previous content of the items
+// cleared.
+func (h *heifFile) encodeMeta(data []byte, delta int, mdatStart uint64) ([]byte, error) {
+	b := new(bytes.Buffer)
+	b.Write(data[h.meta.start+h.meta.header : h.meta.start+h.meta.header+4])
+
+	hasIref := false
+	for _, box := range h.children {
+		switch box.boxType {
+		case "iinf":
+			if h.itemsChanged {
+				b.Write(h.encodeIinf())
+				if !hasIref && len(h.references) > 0 && h.findChild("iref") < 0 {
+					b.Write(h.encodeIref())
+					hasIref = true
+				}
+				continue
+			}
+		case "iref":
+			if h.itemsChanged {
+				if len(h.references) > 0 {
+					b.Write(h.encodeIref())
+				}
+				continue
+			}
+		case "iloc":
+			iloc, err := h.encodeIloc(delta, mdatStart)
+			if err != nil {
+				return nil, err
+			}
+			b.Write(iloc)
+			continue
+		}
+		b.Write(data[box.start:box.end])
+	}
+
+	return isoBoxBytes("meta", b.Bytes()), nil
+}
+
+// findChild returns the index of the first child box of the meta box with the type, or -1.
+func (h *heifFile) findChild(boxType string) int {
+	for i, box := range h.children {
+		if box.boxType == boxType {
+			return i
+		}
+	}
+	return -1
+}
+
+// encodeIinf encodes the item information box.
+func (h *heifFile) encodeIinf() []byte {
+	version := h.iinfVersion
+	if len(h.items) > math.MaxUint16 {
+		version = 1
+	}
+
+	b := new(bytes.Buffer)
+	b.Write([]byte{version, 0, 0, 0})
+	writeHeifId(b, uint32(len(h.items)), version == 0)
+	for _, item := range h.items {
+		b.Write(item.raw)
+	}
+	return isoBoxBytes("iinf", b.Bytes())
+}
+
+// encodeIref encodes the item reference box.
+func (h *heifFile) encodeIref() []byte {
+	version := h.irefVersion
+	for _, reference := range h.references {
+		for _, id := range append([]uint32{reference.from}, reference.to...) {
+			if id > math.MaxUint16 {
+				version = 1
+			}
+		}
+	}
+
+	b := new(bytes.Buffer)
+	b.Write([]byte{version, 0, 0, 0})
+	for _, reference := range h.references {
+		r := new(bytes.Buffer)
+		writeHeifId(r, reference.from, version == 0)
+		_ = binary.Write(r, binary.BigEndian, uint16(len(reference.to)))
+		for _, id := range reference.to {
+			writeHeifId(r, id, version == 0)
+		}
+		b.Write(isoBoxBytes(reference.refType, r.Bytes()))
+	}
+	return isoBoxBytes("iref", b.Bytes())
+}
+
+// encodeIloc encodes the item location box, moving the file offsets following
+// the meta box by delta and placing the appended items from mdatStart.
+func (h *heifFile) encodeIloc(delta int, mdatStart uint64) ([]byte, error) {
+	metaStart, metaEnd := uint64(h.meta.start), uint64(h.meta.end)
+	move := func(offset uint64) (uint64, error) {
+		switch {
+		case offset >= metaEnd:
+			return uint64(int64(offset) + int64(delta)), nil
+		case offset >= metaStart && delta != 0:
+			return 0, fmt.Errorf("cannot move HEIF item data stored in the meta box")
+		}
+		return offset, nil
+	}
+
+	// Compute the new locations and the field sizes they need
+	locations := make([]heifLocation, len(h.locations))
+	sizes := h.ilocSizes
+	for i, location := range h.locations {
+		l := *location
+		l.extents = append([]heifExtent{}, location.extents...)
+
+		switch {
+		case l.appended:
+			l.extents[0].offset += mdatStart
+		case l.constructionMethod == 0 && l.baseOffset >= metaEnd:
+			l.baseOffset = uint64(int64(l.baseOffset) + int64(delta))
+		case l.constructionMethod == 0:
+			for j := range l.extents {
+				moved, err := move(l.baseOffset + l.extents[j].offset)
+				if err != nil {
+					return nil, err
+				}
+				l.extents[j].offset = moved - l.baseOffset
+			}
+		}
+
+		for _, extent := range l.extents {
+			sizes[0] = heifFieldSize(sizes[0], extent.offset)
+			sizes[1] = heifFieldSize(sizes[1], extent.length)
+		}
+		sizes[2] = heifFieldSize(sizes[2], l.baseOffset)
+		locations[i] = l
+	}
+
+	version := h.ilocVersion
+	if len(locations) > math.MaxUint16 {
+		version = 2
+	}
+
+	b := new(bytes.Buffer)
+	b.Write([]byte{version, 0, 0, 0})
+	b.WriteByte(byte(sizes[0]<<4 | sizes[1]))
+	b.WriteByte(byte(sizes[2]<<4 | sizes[3]))
+	writeHeifId(b, uint32(len(locations)), version < 2)
+	for _, l := range locations {
+		writeHeifId(b, l.id, version < 2)
+		if version > 0 {
+			_ = binary.Write(b, binary.BigEndian, uint16(l.constructionMethod))
+		}
+		_ = binary.Write(b, binary.BigEndian, l.dataReferenceIndex)
+		writeHeifUint(b, l.baseOffset, sizes[2])
+		_ = binary.Write(b, binary.BigEndian, uint16(len(l.extents)))
+		for _, extent := range l.extents {
+			writeHeifUint(b, extent.index, sizes[3])
+			writeHeifUint(b, extent.offset, sizes[0])
+			writeHeifUint(b, extent.length, sizes[1])
+		}
+	}
+	return isoBoxBytes("iloc", b.Bytes()), nil
+}
+
+// parseIsoBoxes parses the boxes found between start and end.
+func parseIsoBoxes(data []byte, start int, end int) ([]isoBox, error) {
+	var boxes []isoBox
+	for offset := start; offset < end; {
+		if end-offset < 8 {
+			return nil, fmt.Errorf("truncated box at offset %d", offset)
+		}
+
+		box := isoBox{boxType: string(data[offset+4 : offset+8]), start: offset, header: 8}
+		size := uint64(binary.BigEndian.Uint32(data[offset:]))
+		switch size {
+		case 0:
+			size = uint64(end - offset)
+		case 1:
+			if end-offset < 16 {
+				return nil, fmt.Errorf("truncated box at offset %d", offset)
+			}
+			size = binary.BigEndian.Uint64(data[offset+8:])
+			box.header = 16
+		}
+		if size < uint64(box.header) || size > uint64(end-offset) {
+			return nil, fmt.Errorf("invalid size for box %q at offset %d", box.boxType, offset)
+		}
+		if box.boxType == "uuid" {
+			box.header += 16
+		}
+
+		box.end = offset + int(size)
+		boxes = append(boxes, box)
+		offset = box.end
+	}
+	return boxes, nil
+}
+
+// isoBoxBytes encodes a box with its header.
+func isoBoxBytes(boxType string, payload []byte) []byte {
+	b := new(bytes.Buffer)
+	if size := uint64(len(payload)) + 8; size <= math.MaxUint32 {
+		_ = binary.Write(b, binary.BigEndian, uint32(size))
+		b.WriteString(boxType)
+	} else {
+		_ = binary.Write(b, binary.BigEndian, uint32(1))
+		b.WriteString(boxType)
+		_ = binary.Write(b, binary.BigEndian, size+8)
+	}
+	b.Write(payload)
+	return b.Bytes()
+}
+
+// heifFieldSize returns the size of an iloc field able to hold the value,
+// keeping the current size when it is large enough.
+func heifFieldSize(size int, value uint64) int {
+	switch {
+	case value > math.MaxUint32:
+		return 8
+	case value > 0 && size < 4:
+		return 4
+	}
+	return size
+}
+
+// writeHeifUint writes a big-endian integer of 0, 4 or 8 bytes.
+func writeHeifUint(b *bytes.Buffer, value uint64, size int) {
+	switch size {
+	case 4:
+		_ = binary.Write(b, binary.BigEndian, uint32(value))
+	case 8:
+		_ = binary.Write(b, binary.BigEndian, value)
+	}
+}
+
+// writeHeifId writes an item ID or a count on 16 or 32 bits.
+func writeHeifId(b *bytes.Buffer, id uint32, short bool) {
+	if short {
+		_ = binary.Write(b, binary.BigEndian, uint16(id))
+	} else {
+		_ = binary.Write(b, binary.BigEndian, id)
+	}
+}
+
+// heifReader reads the big-endian fields of a box, recording the first error.
+type heifReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+// bytes reads n bytes.
+func (r *heifReader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.data)-r.offset {
+		if r.err == nil {
+			r.err = fmt.Errorf("truncated box")
+		}
+		return make([]byte, n)
+	}
+	b := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return b
+}
+
+// uint reads an integer of 0, 2, 4 or 8 bytes.
+func (r *heifReader) uint(size int) uint64 {
+	b := r.bytes(size)
+	switch size {
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b))
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b))
+	case 8:
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// id reads an item ID or a count on 16 or 32 bits.
+func (r *heifReader) id(short bool) uint32 {
+	if short {
+		return uint32(r.uint(2))
+	}
+	return uint32(r.uint(4))
+}
+
+// fullBox reads the version and flags of a full box and returns the version.
+func (r *heifReader) fullBox() byte {
+	return r.bytes(4)[0]
+}
+
+// string reads a null-terminated string.
+func (r *heifReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.offset:], 0)
+	if end < 0 {
+		// Some writers omit the terminator of the last string
+		s := string(r.data[r.offset:])
+		r.offset = len(r.data)
+		return s
+	}
+	s := string(r.data[r.offset : r.offset+end])
+	r.offset += end + 1
+	return s
+}
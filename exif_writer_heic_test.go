@@ -0,0 +1,237 @@
+package media_image
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// heifItemsData returns the content of every item of a HEIF file but the Exif one.
+func heifItemsData(t *testing.T, path string) map[uint32][]byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heif, err := parseHeifFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := make(map[uint32][]byte)
+	for _, item := range heif.items {
+		if item.itemType == heifItemTypeExif {
+			continue
+		}
+		if items[item.id], err = heif.itemData(item.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return items
+}
+
+// fileSize returns the size of a file.
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+// moveHeifExifToIdat rewrites a HEIF file with its Exif item stored in the
+// item data box of the meta box, after the data of the other items.
+func moveHeifExifToIdat(t *testing.T, path string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heif, err := parseHeifFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exifItem := heif.findItem(func(item heifItem) bool { return item.itemType == heifItemTypeExif })
+	exifData, err := heif.itemData(exifItem.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clear the previous copy and point the item to the end of the item data
+	location := heif.location(exifItem.id)
+	for _, extent := range location.extents {
+		clear(data[location.baseOffset+extent.offset : location.baseOffset+extent.offset+extent.length])
+	}
+	location.constructionMethod = 1
+	location.baseOffset = 0
+	location.extents = []heifExtent{{offset: uint64(len(heif.idat)), length: uint64(len(exifData))}}
+
+	payload := append([]byte{}, data[heif.meta.start+heif.meta.header:heif.meta.start+heif.meta.header+4]...)
+	for _, box := range heif.children {
+		switch box.boxType {
+		case "idat":
+			payload = append(payload, isoBoxBytes("idat", append(append([]byte{}, heif.idat...), exifData...))...)
+		case "iloc":
+			iloc, err := heif.encodeIloc(len(exifData), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload = append(payload, iloc...)
+		default:
+			payload = append(payload, data[box.start:box.end]...)
+		}
+	}
+
+	moved := append(append([]byte{}, data[:heif.meta.start]...), isoBoxBytes("meta", payload)...)
+	moved = append(moved, data[heif.meta.end:]...)
+	if err := os.WriteFile(path, moved, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ExifWriterHeic(t *testing.T) {
+	t.Log("Testing HEIC exif writer")
+
+	samples := []string{
+		"samples/heic/holyroodhouse.heic",
+		"samples/heic/netherlands.heic",
+		"samples/heif/madrid.heif",
+	}
+
+	for _, sample := range samples {
+		t.Run(filepath.Base(sample), func(t *testing.T) {
+			path := copySample(t, sample)
+			items := heifItemsData(t, path)
+			i := loadImage(t, path)
+			cameraModel := i.ImageData.CameraModel
+
+			data := i.ImageData
+			data.DateTimeOriginal = time.Date(2024, 5, 3, 10, 20, 30, 0, time.UTC)
+			data.GPSLatitude = 55.952725
+			data.GPSLongitude = -3.172095
+			if err := i.WriteExif(data); err != nil {
+				t.Fatal(err)
+			}
+
+			reloaded := loadImage(t, path)
+			assert.Equal(t, "2024-05-03 10:20:30", wallClock(reloaded.ImageData.DateTimeOriginal).Format(time.DateTime))
+			assert.InDelta(t, 55.952725, reloaded.ImageData.GPSLatitude, 1e-6)
+			assert.InDelta(t, -3.172095, reloaded.ImageData.GPSLongitude, 1e-6)
+			assert.Equal(t, cameraModel, reloaded.ImageData.CameraModel)
+			assert.Equal(t, items, heifItemsData(t, path))
+		})
+	}
+
+	t.Run("moved", func(t *testing.T) {
+		path := copySample(t, "samples/heic/netherlands.heic")
+		items := heifItemsData(t, path)
+		i := loadImage(t, path)
+
+		// A description larger than the Exif item forces it to the end of the file
+		data := i.ImageData
+		data.Description = strings.Repeat("Lorem ipsum dolor sit amet. ", 100)
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, data.Description, loadImage(t, path).ImageData.Description)
+		assert.Equal(t, items, heifItemsData(t, path))
+	})
+
+	t.Run("in place", func(t *testing.T) {
+		path := copySample(t, "samples/heic/netherlands.heic")
+		size := fileSize(t, path)
+		items := heifItemsData(t, path)
+		i := loadImage(t, path)
+
+		// A smaller Exif item is overwritten in place
+		data := i.ImageData
+		data.Software = ""
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Empty(t, loadImage(t, path).ImageData.Software)
+		assert.Equal(t, size, fileSize(t, path))
+		assert.Equal(t, items, heifItemsData(t, path))
+	})
+
+	t.Run("added", func(t *testing.T) {
+		path := copySample(t, "samples/heic/netherlands.heic")
+
+		// Remove the Exif item
+		original, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		heif, err := parseHeifFile(original)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exifItem := heif.findItem(func(item heifItem) bool { return item.itemType == heifItemTypeExif })
+		if err := heif.removeItem(exifItem.id); err != nil {
+			t.Fatal(err)
+		}
+		stripped, err := heif.encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, stripped, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		items := heifItemsData(t, path)
+
+		i := loadImage(t, path)
+		assert.Empty(t, i.ImageData.CameraModel)
+
+		data := i.ImageData
+		data.Artist = "Jane Doe"
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "Jane Doe", loadImage(t, path).ImageData.Artist)
+		assert.Equal(t, items, heifItemsData(t, path))
+	})
+
+	t.Run("item data box", func(t *testing.T) {
+		path := copySample(t, "samples/heic/netherlands.heic")
+		i := loadImage(t, path)
+		cameraModel := i.ImageData.CameraModel
+		moveHeifExifToIdat(t, path)
+		items := heifItemsData(t, path)
+
+		data := i.ImageData
+		data.Artist = "Jane Doe"
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded := loadImage(t, path)
+		assert.Equal(t, "Jane Doe", reloaded.ImageData.Artist)
+		assert.Equal(t, cameraModel, reloaded.ImageData.CameraModel)
+		assert.Equal(t, items, heifItemsData(t, path))
+
+		// The Exif item moved out of the item data box, leaving zeros behind
+		written, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		heif, err := parseHeifFile(written)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exifItem := heif.findItem(func(item heifItem) bool { return item.itemType == heifItemTypeExif })
+		assert.Equal(t, uint8(0), heif.location(exifItem.id).constructionMethod)
+		assert.NotContains(t, string(heif.idat), "Exif")
+		assert.Equal(t, make([]byte, len(heif.idat)-8), heif.idat[8:])
+	})
+}
@@ -0,0 +1,495 @@
+package media_image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	jpegstructure "github.com/dsoprea/go-jpeg-image-structure/v2"
+	pngstructure "github.com/dsoprea/go-png-image-structure/v2"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// writeTestImage encodes a small generated image into a temporary file.
+func writeTestImage(t *testing.T, name string, encode func(*bytes.Buffer, image.Image) error) string {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 8; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 16), G: uint8(y * 32), B: 128, A: 255})
+		}
+	}
+
+	b := new(bytes.Buffer)
+	if err := encode(b, img); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// decodeImage decodes the pixels of an image file.
+func decodeImage(t *testing.T, path string) image.Image {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// findExifTag returns the entry of a tag of the EXIF data of an image file.
+func findExifTag(t *testing.T, path string, tagName string) (ExifTagEntry, bool) {
+	t.Helper()
+
+	i := loadImage(t, path)
+	rawExif, err := NewExifParser().Parse(i.path(), i.FileType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := NewExifDataParser().Tags(rawExif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.TagName == tagName && entry.IfdPath != "IFD1" {
+			return entry, true
+		}
+	}
+	return ExifTagEntry{}, false
+}
+
+// addTiffThumbnail appends to a TIFF file an IFD1 holding a JPEG thumbnail,
+// and links it to the last IFD of the chain.
+func addTiffThumbnail(t *testing.T, path string, thumbnail []byte) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := binary.LittleEndian // As written by the tiff package
+
+	// Find the offset of the next IFD field of the last IFD
+	next := 4
+	for offset := order.Uint32(data[next:]); offset != 0; offset = order.Uint32(data[next:]) {
+		next = int(offset) + 2 + 12*int(order.Uint16(data[offset:]))
+	}
+
+	// JPEGInterchangeFormat and JPEGInterchangeFormatLength, then the thumbnail
+	ifd1 := len(data) + len(data)%2
+	data = append(data, make([]byte, ifd1-len(data))...)
+	order.PutUint32(data[next:], uint32(ifd1))
+	data = order.AppendUint16(data, 2)
+	for _, entry := range [][2]uint32{{0x0201, uint32(ifd1 + 2 + 2*12 + 4)}, {0x0202, uint32(len(thumbnail))}} {
+		data = order.AppendUint16(data, uint16(entry[0]))
+		data = order.AppendUint16(data, 4) // LONG
+		data = order.AppendUint32(data, 1)
+		data = order.AppendUint32(data, entry[1])
+	}
+	data = order.AppendUint32(data, 0)
+	data = append(data, thumbnail...)
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ExifWriter(t *testing.T) {
+	t.Log("Testing exif writer")
+
+	dateTime := time.Date(2024, 5, 3, 10, 20, 30, 0, time.UTC)
+
+	update := func(data ImageData) ImageData {
+		data.Artist = "Jane Doe"
+		data.Copyright = "(c) Jane Doe"
+		data.DateTimeOriginal = dateTime
+		data.ImageOrientation = 6
+		data.GPSLatitude = 48.858222
+		data.GPSLongitude = -2.2945
+		data.GPSAltitude = -12.5
+		return data
+	}
+
+	check := func(t *testing.T, path string) {
+		reloaded := loadImage(t, path)
+		assert.Equal(t, "Jane Doe", reloaded.ImageData.Artist)
+		assert.Equal(t, "(c) Jane Doe", reloaded.ImageData.Copyright)
+		assert.Equal(t, wallClock(dateTime), wallClock(reloaded.ImageData.DateTimeOriginal))
+		assert.Equal(t, 6, reloaded.ImageData.ImageOrientation)
+		assert.InDelta(t, 48.858222, reloaded.ImageData.GPSLatitude, 1e-6)
+		assert.InDelta(t, -2.2945, reloaded.ImageData.GPSLongitude, 1e-6)
+	}
+
+	t.Run("jpeg", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		pixels := decodeImage(t, path)
+		i := loadImage(t, path)
+		cameraModel := i.ImageData.CameraModel
+
+		if err := i.WriteExif(update(i.ImageData)); err != nil {
+			t.Fatal(err)
+		}
+		check(t, path)
+		assert.Equal(t, cameraModel, loadImage(t, path).ImageData.CameraModel)
+		assert.Equal(t, pixels, decodeImage(t, path))
+	})
+
+	t.Run("png", func(t *testing.T) {
+		path := writeTestImage(t, "test.png", func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) })
+		pixels := decodeImage(t, path)
+		i := loadImage(t, path)
+
+		if err := i.WriteExif(update(i.ImageData)); err != nil {
+			t.Fatal(err)
+		}
+		check(t, path)
+		assert.Equal(t, pixels, decodeImage(t, path))
+	})
+
+	t.Run("tiff", func(t *testing.T) {
+		path := writeTestImage(t, "test.tiff", func(b *bytes.Buffer, img image.Image) error { return tiff.Encode(b, img, nil) })
+		pixels := decodeImage(t, path)
+		i := loadImage(t, path)
+
+		if err := i.WriteExif(update(i.ImageData)); err != nil {
+			t.Fatal(err)
+		}
+		check(t, path)
+		assert.Equal(t, pixels, decodeImage(t, path))
+	})
+
+	t.Run("tiff thumbnail", func(t *testing.T) {
+		thumbnail, err := os.ReadFile(writeTestImage(t, "thumbnail.jpg", func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := writeTestImage(t, "test.tiff", func(b *bytes.Buffer, img image.Image) error { return tiff.Encode(b, img, nil) })
+		addTiffThumbnail(t, path, thumbnail)
+		pixels := decodeImage(t, path)
+		i := loadImage(t, path)
+
+		if err := i.WriteExif(update(i.ImageData)); err != nil {
+			t.Fatal(err)
+		}
+		check(t, path)
+		assert.Equal(t, pixels, decodeImage(t, path))
+
+		// The thumbnail offset of IFD1 follows the moved data, the thumbnail
+		// being kept in place
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 1, bytes.Count(data, thumbnail))
+		_, index, err := exif.Collect(exifIfdMapping, exifTagIndex, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.NotNil(t, index.RootIfd.NextIfd()) {
+			written, err := index.RootIfd.NextIfd().Thumbnail()
+			assert.NoError(t, err)
+			assert.Equal(t, thumbnail, written)
+		}
+	})
+
+	for _, sample := range []string{"samples/webp/Nærøyfjorden.webp", "samples/webp/giphy.webp"} {
+		t.Run(filepath.Base(sample), func(t *testing.T) {
+			path := copySample(t, sample)
+			i := loadImage(t, path)
+
+			if err := i.WriteExif(update(i.ImageData)); err != nil {
+				t.Fatal(err)
+			}
+			check(t, path)
+			if filepath.Base(sample) == "Nærøyfjorden.webp" {
+				decodeImage(t, path)
+			}
+		})
+	}
+
+	t.Run("preserved", func(t *testing.T) {
+		// Everything but the EXIF data is kept byte for byte
+		t.Run("jpeg", func(t *testing.T) {
+			path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+			others := func() []*jpegstructure.Segment {
+				var segments []*jpegstructure.Segment
+				for _, segment := range jpegSegments(t, path).Segments() {
+					if segment.MarkerId != jpegstructure.MARKER_APP1 || !bytes.HasPrefix(segment.Data, webpExifPrefix) {
+						segments = append(segments, &jpegstructure.Segment{MarkerId: segment.MarkerId, Data: segment.Data})
+					}
+				}
+				return segments
+			}
+			original := others()
+
+			i := loadImage(t, path)
+			if err := i.WriteExif(update(i.ImageData)); err != nil {
+				t.Fatal(err)
+			}
+			check(t, path)
+			assert.Equal(t, original, others())
+		})
+
+		t.Run("png", func(t *testing.T) {
+			path := writeTestImage(t, "test.png", func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) })
+			chunks := pngChunks(t, path).Chunks()
+			text := &pngstructure.Chunk{Type: "tEXt", Data: []byte("Comment\x00Kept as is")}
+			private := &pngstructure.Chunk{Type: "prVt", Data: []byte{1, 2, 3}}
+			for _, chunk := range []*pngstructure.Chunk{text, private} {
+				chunk.Length = uint32(len(chunk.Data))
+				chunk.UpdateCrc32()
+			}
+			writePngChunks(t, path, append([]*pngstructure.Chunk{chunks[0], text, private}, chunks[1:]...))
+			others := func() [][]interface{} {
+				var fields [][]interface{}
+				for _, chunk := range pngChunks(t, path).Chunks() {
+					if chunk.Type != "eXIf" {
+						fields = append(fields, []interface{}{chunk.Type, chunk.Data, chunk.Crc})
+					}
+				}
+				return fields
+			}
+			original := others()
+
+			i := loadImage(t, path)
+			if err := i.WriteExif(update(i.ImageData)); err != nil {
+				t.Fatal(err)
+			}
+			check(t, path)
+			assert.Equal(t, original, others())
+		})
+
+		for _, sample := range []string{"samples/webp/Nærøyfjorden.webp", "samples/webp/giphy.webp"} {
+			t.Run(filepath.Base(sample), func(t *testing.T) {
+				path := copySample(t, sample)
+				others := func() []webpChunk {
+					data, err := os.ReadFile(path)
+					if err != nil {
+						t.Fatal(err)
+					}
+					chunks, err := parseWebpChunks(data)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var kept []webpChunk
+					for _, chunk := range chunks {
+						switch chunk.fourCC {
+						case webpChunkExif:
+						case webpChunkVp8x:
+							// The flags change along with the chunks present
+							kept = append(kept, webpChunk{chunk.fourCC, chunk.data[1:]})
+						default:
+							kept = append(kept, chunk)
+						}
+					}
+					return kept
+				}
+				original := others()
+
+				i := loadImage(t, path)
+				if err := i.WriteExif(update(i.ImageData)); err != nil {
+					t.Fatal(err)
+				}
+				check(t, path)
+				written := others()
+				if original[0].fourCC != webpChunkVp8x && written[0].fourCC == webpChunkVp8x {
+					// The simple format gets a VP8X chunk along with the EXIF one
+					written = written[1:]
+				}
+				assert.Equal(t, original, written)
+			})
+		}
+	})
+
+	t.Run("time offset", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		i := loadImage(t, path)
+
+		data := i.ImageData
+		data.TimeOffset = "-07:00"
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "-07:00", loadImage(t, path).ImageData.TimeOffset)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		i := loadImage(t, path)
+
+		data := i.ImageData
+		data.CameraMake = ""
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		assert.Empty(t, loadImage(t, path).ImageData.CameraMake)
+	})
+
+	t.Run("zero values", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		i := loadImage(t, path)
+		longitude := i.ImageData.GPSLongitude
+		assert.NotZero(t, longitude)
+
+		// On the equator
+		data := i.ImageData
+		data.GPSLatitude = 0
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		reloaded := loadImage(t, path).ImageData
+		if assert.NotNil(t, reloaded.GPS) {
+			assert.True(t, reloaded.GPS.HasPosition)
+		}
+		assert.Zero(t, reloaded.GPSLatitude)
+		assert.InDelta(t, longitude, reloaded.GPSLongitude, 1e-6)
+
+		// On the Greenwich meridian
+		i = loadImage(t, path)
+		data = i.ImageData
+		data.GPSLatitude = 51.4779
+		data.GPSLongitude = 0
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		reloaded = loadImage(t, path).ImageData
+		assert.InDelta(t, 51.4779, reloaded.GPSLatitude, 1e-6)
+		assert.Zero(t, reloaded.GPSLongitude)
+		_, ok := findExifTag(t, path, "GPSLongitude")
+		assert.True(t, ok)
+
+		// Pointing north
+		if err := NewExifWriter().Write(path, ImageJpeg, []ExifChange{{Field: "GPSImgDirection", Value: 87.5}}); err != nil {
+			t.Fatal(err)
+		}
+		i = loadImage(t, path)
+		data = i.ImageData
+		data.GPSImgDirection = 0
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		reloaded = loadImage(t, path).ImageData
+		if assert.NotNil(t, reloaded.GPS) {
+			assert.Equal(t, GPSBearing{Degrees: 0, Ref: GPSBearingTrue, Valid: true}, reloaded.GPS.ImgDirection)
+		}
+
+		// A flash that did not fire, over one that was off
+		if err := NewExifWriter().Write(path, ImageJpeg, []ExifChange{{Field: "Flash", Value: Flash(16)}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := NewExifWriter().Write(path, ImageJpeg, []ExifChange{{Field: "Flash", Value: Flash(0)}}); err != nil {
+			t.Fatal(err)
+		}
+		entry, ok := findExifTag(t, path, "Flash")
+		if assert.True(t, ok) {
+			assert.Equal(t, []uint16{0}, entry.Values)
+		}
+
+		// Deleting is explicit
+		changes := []ExifChange{{Field: "GPSImgDirection", Delete: true}, {Field: "Flash", Delete: true}}
+		if err := NewExifWriter().Write(path, ImageJpeg, changes); err != nil {
+			t.Fatal(err)
+		}
+		reloaded = loadImage(t, path).ImageData
+		if assert.NotNil(t, reloaded.GPS) {
+			assert.False(t, reloaded.GPS.ImgDirection.Valid)
+		}
+		_, ok = findExifTag(t, path, "Flash")
+		assert.False(t, ok)
+		assert.Error(t, NewExifWriter().Write(path, ImageJpeg, []ExifChange{{Field: "Flash"}}))
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		i := loadImage(t, path)
+
+		data := i.ImageData
+		data.ImageWidth++
+		assert.Error(t, i.WriteExif(data))
+	})
+}
+
+func Test_ExifWriterGif(t *testing.T) {
+	t.Log("Testing GIF metadata writer")
+
+	b := new(bytes.Buffer)
+	img := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White})
+	if err := gif.Encode(b, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	original := b.Bytes()
+
+	// Insert an XMP extension and a comment after the global color table
+	offset := 13 + 3<<(original[10]&0x07+1)
+	extension := append([]byte{gifExtension, gifLabelApp, 11}, gifXmpIdentifier...)
+	extension = append(append(extension, `<x:xmpmeta xmp:Rating="3"/>`...), gifXmpTrailer()...)
+	comment := []byte{gifExtension, gifLabelComment, 5, 'h', 'e', 'l', 'l', 'o', 0}
+	data := append(append(append(append([]byte{}, original[:offset]...), extension...), comment...), original[offset:]...)
+
+	w := NewExifWriter()
+	updated, err := w.updateGif(data, metadataEdit{
+		xmp: func(packet []byte) ([]byte, error) {
+			assert.Equal(t, `<x:xmpmeta xmp:Rating="3"/>`, string(packet))
+			return []byte(`<x:xmpmeta xmp:Rating="5"/>`), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, bytes.Replace(data, []byte(`"3"`), []byte(`"5"`), 1), updated)
+
+	var removed []string
+	updated, err = w.updateGif(data, metadataEdit{
+		xmp:       func(packet []byte) ([]byte, error) { return nil, nil },
+		stripText: true,
+		removed:   func(block string) { removed = append(removed, block) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, original, updated)
+	assert.Equal(t, []string{"GIF comment"}, removed)
+
+	_, err = w.updateGif(data, metadataEdit{exif: func(editor *exifEditor) error { return nil }})
+	assert.Error(t, err)
+}
+
+func Test_RationalFromFloat(t *testing.T) {
+	t.Log("Testing rational approximation")
+
+	assert.Equal(t, Rational{Numerator: 3, Denominator: 1}, rationalFromFloat(3))
+	assert.Equal(t, Rational{Numerator: 1, Denominator: 3}, rationalFromFloat(1.0/3))
+	assert.Equal(t, Rational{Numerator: -5, Denominator: 2}, rationalFromFloat(-2.5))
+}
+
+func Test_GpsDegreesToRationals(t *testing.T) {
+	t.Log("Testing GPS coordinate conversion")
+
+	assert.Equal(t, []exifcommon.Rational{{Numerator: 48, Denominator: 1}, {Numerator: 51, Denominator: 1}, {Numerator: 294696, Denominator: 10000}}, gpsDegreesToRationals(48.858186))
+	assert.Equal(t, []exifcommon.Rational{{Numerator: 10, Denominator: 1}, {Numerator: 31, Denominator: 1}, {Numerator: 0, Denominator: 10000}}, gpsDegreesToRationals(10.5166666639))
+	assert.Equal(t, []exifcommon.Rational{{Numerator: 11, Denominator: 1}, {Numerator: 0, Denominator: 1}, {Numerator: 0, Denominator: 10000}}, gpsDegreesToRationals(-10.99999999999))
+}
@@ -0,0 +1,210 @@
+package media_image
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// TIFF tags holding absolute file offsets to image data.
+var tiffOffsetTagIds = []uint16{
+	0x0111, // StripOffsets
+	0x0120, // FreeOffsets
+	0x0144, // TileOffsets
+	tiffThumbnailTagId,
+}
+
+// TIFF tags describing structures the writer cannot relocate, and the
+// JPEGInterchangeFormat tag holding the offset of the thumbnail of IFD1.
+const (
+	tiffSubIfdsTagId   = uint16(0x014a)
+	tiffXmlPacketTagId = uint16(0x02bc)
+	tiffThumbnailTagId = uint16(0x0201)
+)
+
+// updateTiff applies the edit to the IFD chain of a TIFF file.
+//
+// The rebuilt IFDs are written right after the header and the original content
+// follows them unchanged, so the image data offsets are moved by the size of
+// the new IFDs.
+func (w *ExifWriter) updateTiff(data []byte, edit metadataEdit) ([]byte, error) {
+	_, index, err := exif.Collect(exifIfdMapping, exifTagIndex, data)
+	if err != nil {
+		return nil, err
+	}
+	rootIfd := index.RootIfd
+	editor := newExifEditor(rootIfd, data)
+
+	if edit.exif != nil {
+		if err := edit.exif(editor); err != nil {
+			return nil, err
+		}
+	}
+
+	if edit.xmp != nil {
+		if err := w.updateTiffXmp(rootIfd, editor, edit.xmp); err != nil {
+			return nil, err
+		}
+	}
+
+	// Collect the offsets of the image data of every IFD in the chain
+	var offsets [][][]uint32
+	for ifd := rootIfd; ifd != nil; ifd = ifd.NextIfd() {
+		if _, found := ifd.EntriesByTagId()[tiffSubIfdsTagId]; found {
+			return nil, fmt.Errorf("TIFF files with sub-IFDs are not supported for writing")
+		}
+
+		ifdOffsets := make([][]uint32, len(tiffOffsetTagIds))
+		for i, tagId := range tiffOffsetTagIds {
+			if ifdOffsets[i], err = w.readTiffOffsets(data, ifd, tagId); err != nil {
+				return nil, err
+			}
+		}
+		offsets = append(offsets, ifdOffsets)
+	}
+
+	// Measure the new IFDs, then move the offsets by their size. The offsets are
+	// always stored as LONG so that moving them does not change the size, and
+	// the thumbnail stays in place instead of being copied into the IFDs.
+	if err := w.setTiffOffsets(editor, offsets, 0); err != nil {
+		return nil, err
+	}
+	payload, err := exif.NewIfdByteEncoder().EncodeToExifPayload(editor.rootIb)
+	if err != nil {
+		return nil, err
+	}
+
+	size := len(payload)
+	if err := w.setTiffOffsets(editor, offsets, uint32(size)); err != nil {
+		return nil, err
+	}
+	if payload, err = exif.NewIfdByteEncoder().EncodeToExifPayload(editor.rootIb); err != nil {
+		return nil, err
+	}
+	if len(payload) != size {
+		return nil, fmt.Errorf("TIFF IFD size changed while relocating offsets: %d != %d", len(payload), size)
+	}
+
+	header, err := exif.BuildExifHeader(editor.byteOrder, exif.ExifDefaultFirstIfdOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(bytes.Buffer)
+	b.Write(header)
+	b.Write(payload)
+	b.Write(data[exif.ExifDefaultFirstIfdOffset:])
+	return b.Bytes(), nil
+}
+
+// updateTiffXmp applies the XMP edit to the XMLPacket tag of IFD0, if any.
+func (w *ExifWriter) updateTiffXmp(rootIfd *exif.Ifd, editor *exifEditor, edit func([]byte) ([]byte, error)) error {
+	entries, found := rootIfd.EntriesByTagId()[tiffXmlPacketTagId]
+	if !found {
+		return nil
+	}
+
+	packet, err := entries[0].GetRawBytes()
+	if err != nil {
+		return err
+	}
+	if packet, err = edit(packet); err != nil {
+		return err
+	}
+
+	if packet == nil {
+		return editor.deleteId(ifdPathRoot, tiffXmlPacketTagId)
+	}
+	return editor.setRaw(ifdPathRoot, tiffXmlPacketTagId, exifcommon.TypeByte, packet)
+}
+
+// readTiffOffsets reads the values of an offset tag as LONGs.
+func (w *ExifWriter) readTiffOffsets(data []byte, ifd *exif.Ifd, tagId uint16) ([]uint32, error) {
+	entries, found := ifd.EntriesByTagId()[tagId]
+	if !found {
+		return nil, nil
+	}
+
+	// The library reads the thumbnail in place of its offset, which is read
+	// from the IFD entry itself
+	if tagId == tiffThumbnailTagId {
+		return w.readTiffEntryOffset(data, ifd, tagId)
+	}
+
+	value, err := entries[0].Value()
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case []uint32:
+		return v, nil
+	case []uint16:
+		offsets := make([]uint32, len(v))
+		for i, offset := range v {
+			offsets[i] = uint32(offset)
+		}
+		return offsets, nil
+	}
+	return nil, fmt.Errorf("unexpected type %T for offset tag 0x%04x", value, tagId)
+}
+
+// readTiffEntryOffset reads the single LONG value of a tag from the raw entries
+// of an IFD.
+func (w *ExifWriter) readTiffEntryOffset(data []byte, ifd *exif.Ifd, tagId uint16) ([]uint32, error) {
+	order := ifd.ByteOrder()
+	offset := int(ifd.Offset())
+	if offset+2 > len(data) {
+		return nil, fmt.Errorf("IFD offset out of range: %d", offset)
+	}
+	count := int(order.Uint16(data[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + 12*i
+		if entry+12 > len(data) {
+			break
+		}
+		if order.Uint16(data[entry:]) != tagId {
+			continue
+		}
+		if order.Uint16(data[entry+2:]) != uint16(exifcommon.TypeLong) || order.Uint32(data[entry+4:]) != 1 {
+			return nil, fmt.Errorf("unexpected format for offset tag 0x%04x", tagId)
+		}
+		return []uint32{order.Uint32(data[entry+8:])}, nil
+	}
+	return nil, fmt.Errorf("offset tag 0x%04x not found in IFD", tagId)
+}
+
+// setTiffOffsets writes the offsets, moved by delta, into the IFD chain.
+func (w *ExifWriter) setTiffOffsets(editor *exifEditor, offsets [][][]uint32, delta uint32) error {
+	ib := editor.rootIb
+	for _, ifdOffsets := range offsets {
+		for i, tagId := range tiffOffsetTagIds {
+			if ifdOffsets[i] == nil {
+				continue
+			}
+
+			raw := make([]byte, 4*len(ifdOffsets[i]))
+			for j, offset := range ifdOffsets[i] {
+				editor.byteOrder.PutUint32(raw[4*j:], offset+delta)
+			}
+
+			bt := exif.NewBuilderTag(ib.IfdIdentity().UnindexedString(), tagId, exifcommon.TypeLong,
+				exif.NewIfdBuilderTagValueFromBytes(raw), editor.byteOrder)
+			if err := ib.Set(bt); err != nil {
+				return err
+			}
+		}
+
+		next, err := ib.NextIb()
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			break
+		}
+		ib = next
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package media_image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// WebP chunk identifiers.
+const (
+	webpChunkVp8x = "VP8X"
+	webpChunkVp8  = "VP8 "
+	webpChunkVp8l = "VP8L"
+	webpChunkExif = "EXIF"
+	webpChunkXmp  = "XMP "
+	webpChunkAnmf = "ANMF"
+)
+
+// VP8X feature flags.
+const (
+	webpFlagXmp   = byte(0x04)
+	webpFlagExif  = byte(0x08)
+	webpFlagAlpha = byte(0x10)
+)
+
+// webpExifPrefix is the non-standard prefix some writers put before the TIFF header.
+var webpExifPrefix = []byte("Exif\x00\x00")
+
+// webpChunk is a chunk of a WebP RIFF container.
+type webpChunk struct {
+	fourCC string
+	data   []byte
+}
+
+// updateWebp applies the edit to the EXIF and XMP chunks of a WebP file,
+// converting a simple file to the extended format when a chunk is added.
+func (w *ExifWriter) updateWebp(data []byte, edit metadataEdit) ([]byte, error) {
+	chunks, err := parseWebpChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if edit.exif != nil {
+		var rawExif []byte
+		if index := findWebpChunk(chunks, webpChunkExif); index >= 0 {
+			rawExif = bytes.TrimPrefix(chunks[index].data, webpExifPrefix)
+		}
+
+		editor, err := newExifEditorFromRaw(rawExif)
+		if err != nil {
+			return nil, err
+		}
+		if err := edit.exif(editor); err != nil {
+			return nil, err
+		}
+		rawExif = nil
+		if !editor.isEmpty() {
+			if rawExif, err = editor.encode(); err != nil {
+				return nil, err
+			}
+		}
+
+		chunks = setWebpChunk(chunks, webpChunkExif, rawExif)
+	}
+
+	if edit.xmp != nil {
+		if index := findWebpChunk(chunks, webpChunkXmp); index >= 0 {
+			packet, err := edit.xmp(chunks[index].data)
+			if err != nil {
+				return nil, err
+			}
+			chunks = setWebpChunk(chunks, webpChunkXmp, packet)
+		}
+	}
+
+	if chunks, err = updateWebpFeatures(chunks); err != nil {
+		return nil, err
+	}
+
+	return writeWebpChunks(chunks), nil
+}
+
+// parseWebpChunks splits a WebP file into its chunks.
+func parseWebpChunks(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("not a WebP file")
+	}
+
+	var chunks []webpChunk
+	offset := 12
+	for offset+8 <= len(data) {
+		fourCC := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		if size > len(data)-offset-8 {
+			return nil, fmt.Errorf("truncated WebP chunk %q", fourCC)
+		}
+
+		chunks = append(chunks, webpChunk{fourCC: fourCC, data: data[offset+8 : offset+8+size]})
+		offset += 8 + size + size&1
+	}
+
+	return chunks, nil
+}
+
+// writeWebpChunks assembles the chunks into a WebP file.
+func writeWebpChunks(chunks []webpChunk) []byte {
+	b := new(bytes.Buffer)
+	b.WriteString("RIFF")
+	b.Write([]byte{0, 0, 0, 0})
+	b.WriteString("WEBP")
+
+	for _, chunk := range chunks {
+		b.WriteString(chunk.fourCC)
+		_ = binary.Write(b, binary.LittleEndian, uint32(len(chunk.data)))
+		b.Write(chunk.data)
+		if len(chunk.data)&1 == 1 {
+			b.WriteByte(0)
+		}
+	}
+
+	data := b.Bytes()
+	binary.LittleEndian.PutUint32(data[4:8], uint32(len(data)-8))
+	return data
+}
+
+// findWebpChunk returns the index of the first chunk with the identifier, or -1.
+func findWebpChunk(chunks []webpChunk, fourCC string) int {
+	for i, chunk := range chunks {
+		if chunk.fourCC == fourCC {
+			return i
+		}
+	}
+	return -1
+}
+
+// setWebpChunk replaces, appends or, when data is nil, removes a chunk.
+// New chunks are appended, which matches the order of the extended format
+// where EXIF and XMP follow the image data.
+func setWebpChunk(chunks []webpChunk, fourCC string, data []byte) []webpChunk {
+	index := findWebpChunk(chunks, fourCC)
+	switch {
+	case index < 0 && data == nil:
+		return chunks
+	case index < 0:
+		// Keep EXIF before XMP
+		if fourCC == webpChunkExif {
+			if xmp := findWebpChunk(chunks, webpChunkXmp); xmp >= 0 {
+				return append(chunks[:xmp:xmp], append([]webpChunk{{fourCC, data}}, chunks[xmp:]...)...)
+			}
+		}
+		return append(chunks, webpChunk{fourCC, data})
+	case data == nil:
+		return append(chunks[:index:index], chunks[index+1:]...)
+	default:
+		chunks[index].data = data
+		return chunks
+	}
+}
+
+// updateWebpFeatures sets the EXIF and XMP flags of the VP8X chunk, adding the
+// chunk when a simple file gains metadata.
+func updateWebpFeatures(chunks []webpChunk) ([]webpChunk, error) {
+	hasExif := findWebpChunk(chunks, webpChunkExif) >= 0
+	hasXmp := findWebpChunk(chunks, webpChunkXmp) >= 0
+
+	index := findWebpChunk(chunks, webpChunkVp8x)
+	if index < 0 {
+		if !hasExif && !hasXmp {
+			return chunks, nil
+		}
+
+		vp8x, err := newWebpVp8xChunk(chunks)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append([]webpChunk{vp8x}, chunks...)
+		index = 0
+	}
+
+	if len(chunks[index].data) < 10 {
+		return nil, fmt.Errorf("malformed WebP VP8X chunk")
+	}
+	data := append([]byte{}, chunks[index].data...)
+	data[0] &^= webpFlagExif | webpFlagXmp
+	if hasExif {
+		data[0] |= webpFlagExif
+	}
+	if hasXmp {
+		data[0] |= webpFlagXmp
+	}
+	chunks[index].data = data
+
+	return chunks, nil
+}
+
+// newWebpVp8xChunk builds the VP8X chunk of a simple file from its bitstream header.
+func newWebpVp8xChunk(chunks []webpChunk) (webpChunk, error) {
+	var width, height int
+	var flags byte
+
+	switch {
+	case findWebpChunk(chunks, webpChunkVp8) >= 0:
+		data := chunks[findWebpChunk(chunks, webpChunkVp8)].data
+		if len(data) < 10 || data[3] != 0x9d || data[4] != 0x01 || data[5] != 0x2a {
+			return webpChunk{}, fmt.Errorf("malformed WebP VP8 chunk")
+		}
+		width = int(binary.LittleEndian.Uint16(data[6:8]) & 0x3fff)
+		height = int(binary.LittleEndian.Uint16(data[8:10]) & 0x3fff)
+
+	case findWebpChunk(chunks, webpChunkVp8l) >= 0:
+		data := chunks[findWebpChunk(chunks, webpChunkVp8l)].data
+		if len(data) < 5 || data[0] != 0x2f {
+			return webpChunk{}, fmt.Errorf("malformed WebP VP8L chunk")
+		}
+		bits := binary.LittleEndian.Uint32(data[1:5])
+		width = int(bits&0x3fff) + 1
+		height = int((bits>>14)&0x3fff) + 1
+		if bits&(1<<28) != 0 {
+			flags |= webpFlagAlpha
+		}
+
+	default:
+		return webpChunk{}, fmt.Errorf("no image data found in WebP file")
+	}
+
+	data := make([]byte, 10)
+	data[0] = flags
+	putUint24(data[4:7], uint32(width-1))
+	putUint24(data[7:10], uint32(height-1))
+	return webpChunk{fourCC: webpChunkVp8x, data: data}, nil
+}
+
+// putUint24 writes a little-endian 24-bit integer.
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
@@ -0,0 +1,70 @@
+package media_image
+
+import "math"
+
+// Exposure tags of the EXIF IFD.
+const (
+	exifTagExposureTime      = 0x829a
+	exifTagFNumber           = 0x829d
+	exifTagShutterSpeedValue = 0x9201
+	exifTagApertureValue     = 0x9202
+	exifTagExposureBiasValue = 0x9204
+	exifTagMaxApertureValue  = 0x9205
+	exifTagFocalLength       = 0x920a
+)
+
+// exposureFields lists the typed exposure fields of ImageData, read from the
+// raw tag values rather than from their formatted strings.
+var exposureFields = map[string]bool{
+	"ExposureTime":       true,
+	"ExposureBias":       true,
+	"ShutterSpeedTime":   true,
+	"FNumber":            true,
+	"ApertureFNumber":    true,
+	"MaxApertureFNumber": true,
+	"FocalLength":        true,
+
+	"FocalPlaneXResolution":    true,
+	"FocalPlaneYResolution":    true,
+	"FocalPlaneResolutionUnit": true,
+}
+
+// apexExposureTime converts an APEX time value, as in ShutterSpeedValue, to
+// an exposure time in seconds.
+func apexExposureTime(tv float64) float64 {
+	return math.Pow(2, -tv)
+}
+
+// apexFNumber converts an APEX aperture value, as in ApertureValue, to an
+// f-number.
+func apexFNumber(av float64) float64 {
+	return math.Pow(2, av/2)
+}
+
+// parseExposure sets the typed exposure fields of the image data from the
+// EXIF IFD read by r.
+func parseExposure(r ifdReader, imageData *ImageData) {
+	if exposureTime, ok := r.exactRational(exifTagExposureTime); ok && exposureTime.Numerator > 0 {
+		imageData.ExposureTime = exposureTime
+	}
+	if bias, ok := r.exactRational(exifTagExposureBiasValue); ok {
+		imageData.ExposureBias = bias
+	}
+	if fNumber, ok := r.rational(exifTagFNumber); ok && fNumber > 0 {
+		imageData.FNumber = fNumber
+	}
+	if focalLength, ok := r.rational(exifTagFocalLength); ok && focalLength > 0 {
+		imageData.FocalLength = focalLength
+	}
+
+	// APEX values are logarithmic, a huge value being as meaningless as a missing one
+	if tv, ok := r.rational(exifTagShutterSpeedValue); ok && math.Abs(tv) < 64 {
+		imageData.ShutterSpeedTime = apexExposureTime(tv)
+	}
+	if av, ok := r.rational(exifTagApertureValue); ok && av >= 0 && av < 64 {
+		imageData.ApertureFNumber = apexFNumber(av)
+	}
+	if av, ok := r.rational(exifTagMaxApertureValue); ok && av >= 0 && av < 64 {
+		imageData.MaxApertureFNumber = apexFNumber(av)
+	}
+}
@@ -0,0 +1,93 @@
+package media_image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Exposure(t *testing.T) {
+	t.Log("Testing exposure values")
+
+	t.Run("rational", func(t *testing.T) {
+		tests := []struct {
+			value    string
+			expected Rational
+		}{
+			{"1/250", Rational{1, 250}},
+			{"-1/3", Rational{-1, 3}},
+			{"+2/3", Rational{2, 3}},
+			{" 7 / 10 ", Rational{7, 10}},
+			{"1/-3", Rational{-1, 3}},
+			{"2.8", Rational{14, 5}},
+			{"-0.5", Rational{-1, 2}},
+			{"5", Rational{5, 1}},
+		}
+		for _, tt := range tests {
+			r, err := NewRational(tt.value)
+			assert.NoError(t, err, tt.value)
+			assert.Equal(t, tt.expected, r, tt.value)
+		}
+
+		for _, value := range []string{"", "abc", "1/2/3", "1/x", "NaN"} {
+			_, err := NewRational(value)
+			assert.Error(t, err, value)
+		}
+
+		assert.Equal(t, -0.5, Rational{-1, 2}.Float())
+		assert.Zero(t, Rational{}.Float())
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		data := loadImage(t, "samples/jpg/gps/gps-1.jpg").ImageData
+		assert.Equal(t, Rational{560852, 100000000}, data.ExposureTime)
+		assert.Equal(t, 4.5, data.FNumber)
+		assert.Equal(t, 6.0, data.FocalLength)
+		assert.InDelta(t, 2.73, data.MaxApertureFNumber, 0.01)
+		assert.Zero(t, data.ExposureBias.Float())
+
+		// Display strings are kept as they were
+		assert.Equal(t, "45/10", data.LensAperture)
+
+		// Older cameras only record APEX values
+		data = loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg").ImageData
+		assert.Equal(t, Rational{}, data.ExposureTime)
+		assert.InDelta(t, 1.0/169, data.ShutterSpeedTime, 0.0001)
+		assert.InDelta(t, 6.96, data.ApertureFNumber, 0.01)
+		assert.InDelta(t, 3.14, data.MaxApertureFNumber, 0.01)
+	})
+
+	t.Run("write", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		i := loadImage(t, path)
+		data := i.ImageData
+		data.ExposureTime = Rational{1, 250}
+		data.ExposureBias = Rational{-2, 3}
+		data.FNumber = 2.8
+		data.FocalLength = 12.5
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded := loadImage(t, path).ImageData
+		assert.Equal(t, Rational{1, 250}, reloaded.ExposureTime)
+		assert.Equal(t, Rational{-2, 3}, reloaded.ExposureBias)
+		assert.Equal(t, 2.8, reloaded.FNumber)
+		assert.Equal(t, 12.5, reloaded.FocalLength)
+
+		// The display forms follow the typed values, and are not written
+		assert.Equal(t, reloaded.CameraExposure, i.ImageData.CameraExposure)
+		assert.NotEqual(t, data.CameraExposure, reloaded.CameraExposure)
+		assert.NotEqual(t, data.LensAperture, reloaded.LensAperture)
+		data = reloaded
+		data.CameraExposure = "1/60"
+		assert.Error(t, i.WriteExif(data))
+
+		data = reloaded
+		data.ExposureBias = Rational{1, 3}
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, Rational{1, 3}, loadImage(t, path).ImageData.ExposureBias)
+	})
+}
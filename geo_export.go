@@ -0,0 +1,368 @@
+package media_image
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the view cones drawn by a GeoExporter.
+const (
+	DefaultViewConeAngle  = 60.0 // degrees
+	DefaultViewConeLength = 50.0 // meters
+)
+
+// viewConeArcSteps is the number of segments the arc of a view cone is drawn with.
+const viewConeArcSteps = 8
+
+// GeoExport describes what a GeoExporter draws besides the image locations.
+type GeoExport struct {
+	// ViewCones draws, for every image recording the direction it was taken
+	// in, a cone of ViewConeAngle degrees and ViewConeLength meters.
+	ViewCones      bool
+	ViewConeAngle  float64
+	ViewConeLength float64
+
+	// TrackLine draws a line joining the images ordered by capture time.
+	TrackLine bool
+}
+
+// GeoExporter exports the locations of images to map formats.
+type GeoExporter struct {
+	export GeoExport
+}
+
+// geoImage is an image with a location, along with its capture time.
+type geoImage struct {
+	image *ImageInfo
+	time  time.Time
+}
+
+// NewGeoExporter creates a new GeoExporter.
+func NewGeoExporter(export GeoExport) (*GeoExporter, error) {
+	if export.ViewConeAngle == 0 {
+		export.ViewConeAngle = DefaultViewConeAngle
+	}
+	if export.ViewConeLength == 0 {
+		export.ViewConeLength = DefaultViewConeLength
+	}
+	if export.ViewConeAngle < 0 || export.ViewConeAngle >= 360 {
+		return nil, fmt.Errorf("invalid view cone angle: %v", export.ViewConeAngle)
+	}
+	if export.ViewConeLength < 0 {
+		return nil, fmt.Errorf("invalid view cone length: %v", export.ViewConeLength)
+	}
+	return &GeoExporter{export: export}, nil
+}
+
+// locatedImages returns the images with a location, ordered by capture time.
+func (e *GeoExporter) locatedImages(images []*ImageInfo) []geoImage {
+	var located []geoImage
+	for _, image := range images {
+		data := image.ImageData
+		if data.GPSLatitude == 0 && data.GPSLongitude == 0 {
+			continue
+		}
+
+		t := data.DateTimeOriginal
+		if t.IsZero() {
+			t = data.DateTimeDigitized
+		}
+		if t.IsZero() {
+			t = data.GPSTimestamp
+		}
+		located = append(located, geoImage{image: image, time: t})
+	}
+
+	sort.SliceStable(located, func(a, b int) bool { return located[a].time.Before(located[b].time) })
+	return located
+}
+
+// imageDirection returns the direction the camera was pointing to, if
+// recorded. Zero is a valid direction, due north.
+func imageDirection(data ImageData) (float64, bool) {
+	if data.GPS == nil || !data.GPS.ImgDirection.Valid {
+		return 0, false
+	}
+	return data.GPS.ImgDirection.Degrees, true
+}
+
+// viewCone returns the outline of the view cone of an image, as a closed ring
+// of [longitude, latitude] positions, or nil when its direction is unknown.
+// The ring is counterclockwise, as RFC 7946 requires of exterior rings.
+func (e *GeoExporter) viewCone(data ImageData) [][2]float64 {
+	direction, ok := imageDirection(data)
+	if !e.export.ViewCones || !ok {
+		return nil
+	}
+
+	// Bearings turn clockwise, the arc is drawn from its last bearing back
+	apex := [2]float64{data.GPSLongitude, data.GPSLatitude}
+	ring := [][2]float64{apex}
+	start := direction + e.export.ViewConeAngle/2
+	for step := 0; step <= viewConeArcSteps; step++ {
+		bearing := start - e.export.ViewConeAngle*float64(step)/viewConeArcSteps
+		latitude, longitude := destinationPoint(data.GPSLatitude, data.GPSLongitude, bearing, e.export.ViewConeLength)
+		ring = append(ring, [2]float64{longitude, latitude})
+	}
+	return append(ring, apex)
+}
+
+// geoCamera returns the make and model of the camera of an image.
+func geoCamera(data ImageData) string {
+	camera := strings.TrimSpace(data.CameraModel)
+	if cameraMake := strings.TrimSpace(data.CameraMake); cameraMake != "" && !strings.HasPrefix(camera, cameraMake) {
+		camera = strings.TrimSpace(cameraMake + " " + camera)
+	}
+	return camera
+}
+
+// geoJsonFeature is a GeoJSON feature.
+type geoJsonFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJsonGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// geoJsonGeometry is a GeoJSON geometry.
+type geoJsonGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// GeoJSON writes the images with a location as a GeoJSON FeatureCollection.
+// Every image is a Point feature; view cones are Polygon features and the
+// track line a LineString feature, told apart by their "kind" property.
+func (e *GeoExporter) GeoJSON(w io.Writer, images []*ImageInfo) error {
+	located := e.locatedImages(images)
+	features := make([]geoJsonFeature, 0, len(located))
+	var track [][2]float64
+
+	for _, l := range located {
+		data := l.image.ImageData
+		coordinates := []float64{data.GPSLongitude, data.GPSLatitude}
+		if data.GPSAltitude != 0 {
+			coordinates = append(coordinates, data.GPSAltitude)
+		}
+
+		properties := map[string]interface{}{
+			"kind":     "image",
+			"fileName": l.image.FileInfo.Name(),
+		}
+		if !l.time.IsZero() {
+			properties["captureTime"] = l.time.Format(time.RFC3339)
+		}
+		if camera := geoCamera(data); camera != "" {
+			properties["camera"] = camera
+		}
+		if direction, ok := imageDirection(data); ok {
+			properties["direction"] = direction
+		}
+		features = append(features, geoJsonFeature{
+			Type:       "Feature",
+			Geometry:   geoJsonGeometry{Type: "Point", Coordinates: coordinates},
+			Properties: properties,
+		})
+
+		if cone := e.viewCone(data); cone != nil {
+			features = append(features, geoJsonFeature{
+				Type:     "Feature",
+				Geometry: geoJsonGeometry{Type: "Polygon", Coordinates: [][][2]float64{cone}},
+				Properties: map[string]interface{}{
+					"kind":      "viewCone",
+					"fileName":  l.image.FileInfo.Name(),
+					"direction": data.GPS.ImgDirection.Degrees,
+				},
+			})
+		}
+		track = append(track, [2]float64{data.GPSLongitude, data.GPSLatitude})
+	}
+
+	if e.export.TrackLine && len(track) > 1 {
+		features = append(features, geoJsonFeature{
+			Type:       "Feature",
+			Geometry:   geoJsonGeometry{Type: "LineString", Coordinates: track},
+			Properties: map[string]interface{}{"kind": "track"},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Type     string           `json:"type"`
+		Features []geoJsonFeature `json:"features"`
+	}{"FeatureCollection", features})
+}
+
+// kmlDocument is the root of a KML document.
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Namespace  string         `xml:"xmlns,attr"`
+	Styles     []kmlStyle     `xml:"Document>Style"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+// kmlStyle is a KML style, shared or inlined in a placemark.
+type kmlStyle struct {
+	Id        string        `xml:"id,attr,omitempty"`
+	IconStyle *kmlIconStyle `xml:"IconStyle,omitempty"`
+	LineStyle *kmlLineStyle `xml:"LineStyle,omitempty"`
+	PolyStyle *kmlPolyStyle `xml:"PolyStyle,omitempty"`
+}
+
+// kmlIconStyle orients the icon of a placemark.
+type kmlIconStyle struct {
+	Heading float64 `xml:"heading"`
+}
+
+// kmlLineStyle draws lines and outlines.
+type kmlLineStyle struct {
+	Color string  `xml:"color"`
+	Width float64 `xml:"width"`
+}
+
+// kmlPolyStyle fills polygons.
+type kmlPolyStyle struct {
+	Color string `xml:"color"`
+}
+
+// kmlPlacemark is a KML placemark holding a single geometry. Its elements
+// are listed in the order required by the KML schema.
+type kmlPlacemark struct {
+	Name         string           `xml:"name,omitempty"`
+	Description  string           `xml:"description,omitempty"`
+	TimeStamp    *kmlTimeStamp    `xml:"TimeStamp,omitempty"`
+	StyleUrl     string           `xml:"styleUrl,omitempty"`
+	Style        *kmlStyle        `xml:"Style,omitempty"`
+	ExtendedData *kmlExtendedData `xml:"ExtendedData,omitempty"`
+	Point        *kmlPoint        `xml:"Point,omitempty"`
+	LineString   *kmlPoint        `xml:"LineString,omitempty"`
+	Polygon      *kmlPoint        `xml:"Polygon>outerBoundaryIs>LinearRing,omitempty"`
+}
+
+// kmlTimeStamp is the time of a placemark.
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+// kmlExtendedData holds the named values of a placemark.
+type kmlExtendedData struct {
+	Data []kmlData `xml:"Data"`
+}
+
+// kmlPoint holds the coordinates of a KML geometry.
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// kmlData is a named value of the extended data of a placemark.
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+// KML writes the images with a location as a KML document, with a placemark
+// per image, view cone and track line.
+func (e *GeoExporter) KML(w io.Writer, images []*ImageInfo) error {
+	doc := kmlDocument{
+		Namespace: "http://www.opengis.net/kml/2.2",
+		Styles: []kmlStyle{
+			{Id: "viewCone", LineStyle: &kmlLineStyle{Color: "ff00a5ff", Width: 1}, PolyStyle: &kmlPolyStyle{Color: "4000a5ff"}},
+			{Id: "track", LineStyle: &kmlLineStyle{Color: "ffff0000", Width: 3}},
+		},
+	}
+	var track []string
+
+	for _, l := range e.locatedImages(images) {
+		data := l.image.ImageData
+		position := kmlCoordinates(data.GPSLongitude, data.GPSLatitude, data.GPSAltitude)
+
+		placemark := kmlPlacemark{
+			Name:  l.image.FileInfo.Name(),
+			Point: &kmlPoint{Coordinates: position},
+		}
+		var description []string
+		var extendedData []kmlData
+		if !l.time.IsZero() {
+			when := l.time.Format(time.RFC3339)
+			placemark.TimeStamp = &kmlTimeStamp{When: when}
+			extendedData = append(extendedData, kmlData{Name: "captureTime", Value: when})
+			description = append(description, l.time.Format("2006-01-02 15:04:05"))
+		}
+		if camera := geoCamera(data); camera != "" {
+			extendedData = append(extendedData, kmlData{Name: "camera", Value: camera})
+			description = append(description, camera)
+		}
+		if direction, ok := imageDirection(data); ok {
+			extendedData = append(extendedData, kmlData{Name: "direction", Value: strconv.FormatFloat(direction, 'f', -1, 64)})
+			placemark.Style = &kmlStyle{IconStyle: &kmlIconStyle{Heading: direction}}
+		}
+		if extendedData != nil {
+			placemark.ExtendedData = &kmlExtendedData{Data: extendedData}
+		}
+		placemark.Description = strings.Join(description, "\n")
+		doc.Placemarks = append(doc.Placemarks, placemark)
+
+		if cone := e.viewCone(data); cone != nil {
+			coordinates := make([]string, len(cone))
+			for k, p := range cone {
+				coordinates[k] = kmlCoordinates(p[0], p[1], 0)
+			}
+			doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+				Name:     l.image.FileInfo.Name(),
+				StyleUrl: "#viewCone",
+				Polygon:  &kmlPoint{Coordinates: strings.Join(coordinates, " ")},
+			})
+		}
+		track = append(track, position)
+	}
+
+	if e.export.TrackLine && len(track) > 1 {
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			Name:       "Track",
+			StyleUrl:   "#track",
+			LineString: &kmlPoint{Coordinates: strings.Join(track, " ")},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// kmlCoordinates formats a KML position, omitting a zero altitude.
+func kmlCoordinates(longitude, latitude, altitude float64) string {
+	s := strconv.FormatFloat(longitude, 'f', -1, 64) + "," + strconv.FormatFloat(latitude, 'f', -1, 64)
+	if altitude != 0 {
+		s += "," + strconv.FormatFloat(altitude, 'f', -1, 64)
+	}
+	return s
+}
+
+// destinationPoint returns the position reached from a position, given in
+// decimal degrees, after travelling the distance in meters along the bearing.
+func destinationPoint(latitude, longitude, bearing, distance float64) (float64, float64) {
+	phi := latitude * math.Pi / 180
+	lambda := longitude * math.Pi / 180
+	theta := bearing * math.Pi / 180
+	delta := distance / earthRadius
+
+	phi2 := math.Asin(math.Sin(phi)*math.Cos(delta) + math.Cos(phi)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi), math.Cos(delta)-math.Sin(phi)*math.Sin(phi2))
+
+	longitude2 := math.Mod(lambda2*180/math.Pi+540, 360) - 180
+	return phi2 * 180 / math.Pi, longitude2
+}
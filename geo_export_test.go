@@ -0,0 +1,135 @@
+package media_image
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GeoExporter(t *testing.T) {
+	t.Log("Testing geo exporter")
+
+	located := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+	located.ImageData.GPSImgDirection = 90
+	located.ImageData.GPS.ImgDirection = GPSBearing{Degrees: 90, Ref: GPSBearingTrue, Valid: true}
+	other := loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg")
+	other.ImageData.GPSLatitude = 43.47
+	other.ImageData.GPSLongitude = 11.89
+	unlocated := loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg")
+	images := []*ImageInfo{located, other, unlocated}
+
+	t.Run("geojson", func(t *testing.T) {
+		exporter, err := NewGeoExporter(GeoExport{ViewCones: true, TrackLine: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := exporter.GeoJSON(&b, images); err != nil {
+			t.Fatal(err)
+		}
+
+		var collection struct {
+			Type     string
+			Features []struct {
+				Geometry struct {
+					Type        string
+					Coordinates interface{}
+				}
+				Properties map[string]interface{}
+			}
+		}
+		if err := json.Unmarshal(b.Bytes(), &collection); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "FeatureCollection", collection.Type)
+		if !assert.Len(t, collection.Features, 4) {
+			return
+		}
+
+		// Images are ordered by capture time
+		assert.Equal(t, "exif-org-1.jpg", collection.Features[0].Properties["fileName"])
+		assert.Equal(t, []interface{}{11.89, 43.47}, collection.Features[0].Geometry.Coordinates)
+		assert.Equal(t, "FUJIFILM MX-1700ZOOM", collection.Features[0].Properties["camera"])
+		assert.Equal(t, "2000-09-02T14:30:10Z", collection.Features[0].Properties["captureTime"])
+
+		assert.Equal(t, "gps-1.jpg", collection.Features[1].Properties["fileName"])
+		assert.Equal(t, 90.0, collection.Features[1].Properties["direction"])
+		assert.Equal(t, "viewCone", collection.Features[2].Properties["kind"])
+		assert.Equal(t, "Polygon", collection.Features[2].Geometry.Type)
+
+		// Exterior rings are counterclockwise, of a positive signed area
+		ring := exporter.viewCone(located.ImageData)
+		area := 0.0
+		for k := 0; k+1 < len(ring); k++ {
+			area += ring[k][0]*ring[k+1][1] - ring[k+1][0]*ring[k][1]
+		}
+		assert.Equal(t, ring[0], ring[len(ring)-1])
+		assert.Greater(t, area, 0.0)
+		assert.Equal(t, "track", collection.Features[3].Properties["kind"])
+		assert.Equal(t, "LineString", collection.Features[3].Geometry.Type)
+	})
+
+	t.Run("kml", func(t *testing.T) {
+		exporter, _ := NewGeoExporter(GeoExport{ViewCones: true, TrackLine: true})
+		var b bytes.Buffer
+		if err := exporter.KML(&b, images); err != nil {
+			t.Fatal(err)
+		}
+		kml := b.String()
+		assert.Contains(t, kml, `<kml xmlns="http://www.opengis.net/kml/2.2">`)
+		assert.Contains(t, kml, `<name>gps-1.jpg</name>`)
+		assert.Contains(t, kml, `<heading>90</heading>`)
+		assert.Contains(t, kml, `<styleUrl>#viewCone</styleUrl>`)
+		assert.Contains(t, kml, `<coordinates>11.89,43.47 11.885394999997223,43.46715666666389</coordinates>`)
+
+		// The timestamped image placemarks read back as a track
+		track, err := ParseKml(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, track.Points, 2)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		exporter, _ := NewGeoExporter(GeoExport{})
+		var b bytes.Buffer
+		if err := exporter.GeoJSON(&b, images); err != nil {
+			t.Fatal(err)
+		}
+		assert.NotContains(t, b.String(), "viewCone")
+		assert.NotContains(t, b.String(), "LineString")
+
+		_, err := NewGeoExporter(GeoExport{ViewConeAngle: 400})
+		assert.Error(t, err)
+	})
+
+	t.Run("destination", func(t *testing.T) {
+		latitude, longitude := destinationPoint(0, 179.9999, 90, 1000)
+		assert.InDelta(t, 0, latitude, 1e-9)
+		assert.InDelta(t, -179.991107, longitude, 1e-6)
+		assert.InDelta(t, 1000, haversineDistance(0, 179.9999, latitude, longitude), 1e-6)
+	})
+
+	t.Run("north", func(t *testing.T) {
+		// A camera pointing due north has a direction of zero
+		north := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+		north.ImageData.GPSImgDirection = 0
+		north.ImageData.GPS.ImgDirection = GPSBearing{Degrees: 0, Ref: GPSBearingTrue, Valid: true}
+
+		exporter, _ := NewGeoExporter(GeoExport{ViewCones: true})
+		var b bytes.Buffer
+		if err := exporter.GeoJSON(&b, []*ImageInfo{north}); err != nil {
+			t.Fatal(err)
+		}
+		assert.Contains(t, b.String(), `"direction": 0`)
+		assert.Contains(t, b.String(), "viewCone")
+
+		b.Reset()
+		if err := exporter.KML(&b, []*ImageInfo{north}); err != nil {
+			t.Fatal(err)
+		}
+		assert.Contains(t, b.String(), `<heading>0</heading>`)
+	})
+}
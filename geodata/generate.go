@@ -0,0 +1,203 @@
+//go:build ignore
+
+// generate builds the places dataset embedded by the reverse geocoder from the
+// GeoNames cities of https://github.com/lutangar/cities.json, and the ISO 3166
+// country list and the countries of the time zones of the time zone database.
+//
+// Usage:
+//
+//	go run geodata/generate.go <cities.json dir> <iso3166.tab> <zone.tab> <output>
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type city struct {
+	Name    string `json:"name"`
+	Lat     string `json:"lat"`
+	Lng     string `json:"lng"`
+	Country string `json:"country"`
+	Admin1  string `json:"admin1"`
+}
+
+type region struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+func main() {
+	if len(os.Args) != 5 {
+		log.Fatal("usage: generate <cities.json dir> <iso3166.tab> <zone.tab> <output>")
+	}
+
+	var cities []city
+	readJson(filepath.Join(os.Args[1], "cities.json"), &cities)
+	var regions []region
+	readJson(filepath.Join(os.Args[1], "admin1.json"), &regions)
+	countries := readCountries(os.Args[2])
+	zones := readZones(os.Args[3])
+
+	out, err := os.Create(os.Args[4])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+	zw, err := gzip.NewWriterLevel(out, gzip.BestCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := bufio.NewWriter(zw)
+
+	// n: country code, name
+	for _, code := range sortedKeys(countries) {
+		fmt.Fprintf(w, "n\t%s\t%s\n", code, countries[code])
+	}
+
+	// z: time zone, country code
+	for _, zone := range sortedKeys(zones) {
+		fmt.Fprintf(w, "z\t%s\t%s\n", zone, zones[zone])
+	}
+
+	// r: country code.admin1 code, name
+	sort.Slice(regions, func(a, b int) bool { return regions[a].Code < regions[b].Code })
+	for _, r := range regions {
+		fmt.Fprintf(w, "r\t%s\t%s\n", r.Code, clean(r.Name))
+	}
+
+	// c: name, latitude, longitude, country code, admin1 code
+	sort.SliceStable(cities, func(a, b int) bool { return cities[a].Country < cities[b].Country })
+	for _, c := range cities {
+		fmt.Fprintf(w, "c\t%s\t%s\t%s\t%s\t%s\n", clean(c.Name), round(c.Lat), round(c.Lng), c.Country, c.Admin1)
+	}
+
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readJson(path string, v interface{}) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+}
+
+// countryNames replaces the short names of iso3166.tab that are not fit for display.
+var countryNames = map[string]string{
+	"AS": "American Samoa",
+	"CD": "Democratic Republic of the Congo",
+	"CG": "Republic of the Congo",
+	"GB": "United Kingdom",
+	"KP": "North Korea",
+	"KR": "South Korea",
+	"MF": "Saint Martin",
+	"MM": "Myanmar",
+	"SX": "Sint Maarten",
+	"SZ": "Eswatini",
+	"TC": "Turks and Caicos Islands",
+	"VG": "British Virgin Islands",
+	"VI": "United States Virgin Islands",
+	"WS": "Samoa",
+}
+
+func readCountries(path string) map[string]string {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	countries := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		code, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if override, ok := countryNames[code]; ok {
+			name = override
+		} else {
+			name = strings.ReplaceAll(name, " & ", " and ")
+			if rest, ok := strings.CutPrefix(name, "St "); ok {
+				name = "Saint " + rest
+			}
+		}
+		countries[code] = name
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return countries
+}
+
+// readZones reads the country of every time zone, the zone.tab file listing
+// a single country for each zone, unlike zone1970.tab.
+func readZones(path string) map[string]string {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	zones := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Country code, coordinates, zone and comments
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		zones[fields[2]] = fields[0]
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return zones
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// round keeps 4 decimal places, about 11 m, which is plenty to find a city.
+func round(value string) string {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("invalid coordinate: %s", value)
+	}
+	s := strings.TrimRight(strconv.FormatFloat(f, 'f', 4, 64), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func clean(s string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(strings.TrimSpace(s))
+}
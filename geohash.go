@@ -0,0 +1,94 @@
+package media_image
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GeohashPrecision is the number of characters of the geohash of ImageData,
+// a cell of about 5 by 5 meters.
+const GeohashPrecision = 9
+
+// geohashAlphabet is the base 32 alphabet of geohashes.
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// EncodeGeohash returns the geohash of a location, with precision characters
+// from 1 to 12.
+func EncodeGeohash(latitude, longitude float64, precision int) string {
+	precision = max(1, min(12, precision))
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	var hash strings.Builder
+	bits, char := 0, 0
+	for even := true; hash.Len() < precision; even = !even {
+		// Bits alternate between the longitude and the latitude, longitude first
+		value, r := latitude, &latRange
+		if even {
+			value, r = longitude, &lonRange
+		}
+		mid := (r[0] + r[1]) / 2
+		char <<= 1
+		if value >= mid {
+			char |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+
+		if bits++; bits == 5 {
+			hash.WriteByte(geohashAlphabet[char])
+			bits, char = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+// GeohashBounds returns the cell of a geohash.
+func GeohashBounds(hash string) (south, west, north, east float64, err error) {
+	if hash == "" {
+		return 0, 0, 0, 0, fmt.Errorf("empty geohash")
+	}
+
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		char := strings.IndexRune(geohashAlphabet, c)
+		if char < 0 {
+			return 0, 0, 0, 0, fmt.Errorf("invalid geohash: %s", hash)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			r := &latRange
+			if even {
+				r = &lonRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if char&(1<<bit) != 0 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	return latRange[0], lonRange[0], latRange[1], lonRange[1], nil
+}
+
+// DecodeGeohash returns the center of the cell of a geohash.
+func DecodeGeohash(hash string) (latitude, longitude float64, err error) {
+	south, west, north, east, err := GeohashBounds(hash)
+	if err != nil {
+		return 0, 0, err
+	}
+	return (south + north) / 2, (west + east) / 2, nil
+}
+
+// Geohash returns the geohash of the GPS position of the image, with
+// precision characters, or an empty string without position.
+func (d ImageData) Geohash(precision int) string {
+	if d.GPSLatitude == 0 && d.GPSLongitude == 0 {
+		return ""
+	}
+	return EncodeGeohash(d.GPSLatitude, d.GPSLongitude, precision)
+}
@@ -0,0 +1,48 @@
+package media_image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Geohash(t *testing.T) {
+	t.Log("Testing geohash")
+
+	t.Run("encode", func(t *testing.T) {
+		assert.Equal(t, "u4pruydqqvj", EncodeGeohash(57.64911, 10.40744, 11))
+		assert.Equal(t, "u4pru", EncodeGeohash(57.64911, 10.40744, 5))
+		assert.Equal(t, "7zzzzzzzzzzz", EncodeGeohash(-0.0000001, -0.0000001, 20))
+		assert.Equal(t, "s", EncodeGeohash(0, 0, 0))
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		south, west, north, east, err := GeohashBounds("ezs42")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.InDelta(t, 42.605, (south+north)/2, 0.001)
+		assert.InDelta(t, -5.603, (west+east)/2, 0.001)
+		assert.InDelta(t, 0.0439, north-south, 0.0001)
+		assert.InDelta(t, 0.0439, east-west, 0.0001)
+
+		latitude, longitude, err := DecodeGeohash("U4PRUYDQQVJ")
+		assert.NoError(t, err)
+		assert.InDelta(t, 57.64911, latitude, 0.000001)
+		assert.InDelta(t, 10.40744, longitude, 0.000001)
+
+		_, _, err = DecodeGeohash("u4pa")
+		assert.Error(t, err)
+		_, _, err = DecodeGeohash("")
+		assert.Error(t, err)
+	})
+
+	t.Run("image", func(t *testing.T) {
+		i := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+		assert.Len(t, i.ImageData.GPSGeohash, GeohashPrecision)
+		assert.Equal(t, EncodeGeohash(i.ImageData.GPSLatitude, i.ImageData.GPSLongitude, GeohashPrecision), i.ImageData.GPSGeohash)
+		assert.Equal(t, i.ImageData.GPSGeohash[:4], i.ImageData.Geohash(4))
+
+		assert.Empty(t, loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg").ImageData.Geohash(GeohashPrecision))
+	})
+}
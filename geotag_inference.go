@@ -0,0 +1,149 @@
+package media_image
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// earthRadius is the mean radius of the Earth, in meters.
+const earthRadius = 6371008.8
+
+// geotagInferenceDistanceScale is the distance, in meters, between the
+// reference photos at which the distance confidence drops to one half.
+const geotagInferenceDistanceScale = 1000.0
+
+// GeotagProposal is the position inferred for an untagged image from the
+// geotagged images taken around the same time.
+type GeotagProposal struct {
+	GeotagResult
+
+	// TimeDistance is the time between the image and the closest reference photo.
+	TimeDistance time.Duration
+
+	// Distance is the distance, in meters, between the reference photos the
+	// position is interpolated between. It is zero when the position comes
+	// from a single reference photo.
+	Distance float64
+
+	// Confidence ranges from 0 to 1. It decreases as the image is further in
+	// time from the reference photos and as they are further apart.
+	Confidence float64
+}
+
+// GeotagInferrer locates untagged images, typically from a camera without
+// GPS, using the geotagged images of the same set, typically from a phone,
+// as a track log.
+type GeotagInferrer struct {
+	geotagger *Geotagger
+	images    []*ImageInfo
+}
+
+// NewGeotagInferrer creates a new GeotagInferrer. The geotagging describes
+// the untagged images; its Overwrite field is ignored as the geotagged images
+// are the reference.
+func NewGeotagInferrer(geotagging Geotagging, images []*ImageInfo) (*GeotagInferrer, error) {
+	track, err := TrackFromImages(images)
+	if err != nil {
+		return nil, err
+	}
+
+	geotagging.Overwrite = false
+	geotagger, err := NewGeotagger(geotagging, track)
+	if err != nil {
+		return nil, err
+	}
+	return &GeotagInferrer{geotagger: geotagger, images: images}, nil
+}
+
+// TrackFromImages builds a synthetic track from the geotagged images, ordered
+// by their capture time.
+func TrackFromImages(images []*ImageInfo) (*Track, error) {
+	var points []TrackPoint
+	for _, image := range images {
+		data := image.ImageData
+		if data.GPSLatitude == 0 && data.GPSLongitude == 0 {
+			continue
+		}
+
+		t, ok := data.CaptureInstant()
+		if !ok {
+			continue
+		}
+
+		point := TrackPoint{
+			Time:      t.UTC(),
+			Latitude:  data.GPSLatitude,
+			Longitude: data.GPSLongitude,
+		}
+		if gps := data.GPS; gps != nil && gps.HasAltitude && !gps.HasIssue(GPSIssueImpossibleAltitude) {
+			point.Altitude, point.HasAltitude = gps.Altitude, true
+		}
+		points = append(points, point)
+	}
+
+	track, err := newTrack(points)
+	if err != nil {
+		return nil, fmt.Errorf("no dated geotagged image found: %w", err)
+	}
+	return track, nil
+}
+
+// Preview proposes a position for every untagged image without modifying anything.
+func (g *GeotagInferrer) Preview() []GeotagProposal {
+	results := g.geotagger.Preview(g.images)
+	proposals := make([]GeotagProposal, 0, len(results))
+	for _, result := range results {
+		proposal := GeotagProposal{GeotagResult: result}
+		if result.Matched {
+			g.rate(&proposal)
+		}
+		proposals = append(proposals, proposal)
+	}
+	return proposals
+}
+
+// Apply sets the proposed positions reaching the minimum confidence, as
+// described by Geotagger.Apply.
+func (g *GeotagInferrer) Apply(proposals []GeotagProposal, minConfidence float64) error {
+	var results []GeotagResult
+	for _, proposal := range proposals {
+		if proposal.Matched && proposal.Confidence >= minConfidence {
+			results = append(results, proposal.GeotagResult)
+		}
+	}
+	return g.geotagger.Apply(results)
+}
+
+// rate computes the confidence of a proposal from the reference photos its
+// position was interpolated between.
+func (g *GeotagInferrer) rate(proposal *GeotagProposal) {
+	_, before, after, _ := g.geotagger.track.locate(proposal.Time, g.geotagger.geotagging.MaxGap)
+
+	proposal.TimeDistance = min(absDuration(proposal.Time.Sub(before.Time)), absDuration(after.Time.Sub(proposal.Time)))
+	proposal.Distance = haversineDistance(before.Latitude, before.Longitude, after.Latitude, after.Longitude)
+
+	timeConfidence := 1 - float64(proposal.TimeDistance)/float64(g.geotagger.geotagging.MaxGap)
+	distanceConfidence := 1 / (1 + proposal.Distance/geotagInferenceDistanceScale)
+	proposal.Confidence = math.Max(0, timeConfidence) * distanceConfidence
+}
+
+// absDuration returns the absolute value of a duration.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// haversineDistance returns the great-circle distance, in meters, between two
+// positions given in decimal degrees.
+func haversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
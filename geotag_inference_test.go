@@ -0,0 +1,93 @@
+package media_image
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GeotagInferrer(t *testing.T) {
+	t.Log("Testing geotag inference")
+
+	// Phone photos taken around the camera photo, dated 2000-09-02 14:30:10
+	// camera time, their clock set to the time of Paris
+	phone := func(t *testing.T, latitude, longitude float64, capture time.Time, fix time.Time) *ImageInfo {
+		i := loadImage(t, copySample(t, "samples/jpg/exif-org/exif-org-1.jpg"))
+		data := i.ImageData
+		data.DateTimeOriginal = capture.Add(2 * time.Hour)
+		data.GPSLatitude = latitude
+		data.GPSLongitude = longitude
+		data.GPSTimestamp = fix
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+
+	t.Run("infer", func(t *testing.T) {
+		camera := loadImage(t, copySample(t, "samples/jpg/exif-org/exif-org-1.jpg"))
+		images := []*ImageInfo{
+			phone(t, 48.858, 2.294, time.Date(2000, 9, 2, 12, 31, 0, 0, time.UTC), time.Date(2000, 9, 2, 12, 31, 0, 0, time.UTC)),
+			camera,
+			phone(t, 48.856, 2.292, time.Date(2000, 9, 2, 12, 29, 0, 0, time.UTC), time.Date(2000, 9, 2, 12, 29, 0, 0, time.UTC)),
+		}
+
+		inferrer, err := NewGeotagInferrer(Geotagging{TimeOffset: "+02:00", ClockCorrection: -10 * time.Second}, images)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proposals := inferrer.Preview()
+		if !assert.Len(t, proposals, 1) {
+			return
+		}
+		proposal := proposals[0]
+		assert.Same(t, camera, proposal.Image)
+		assert.True(t, proposal.Matched)
+		assert.InDelta(t, 48.857, proposal.Position.Latitude, 1e-9)
+		assert.InDelta(t, 2.293, proposal.Position.Longitude, 1e-9)
+		assert.Equal(t, time.Minute, proposal.TimeDistance)
+		assert.InDelta(t, 266, proposal.Distance, 1)
+		assert.InDelta(t, 0.8*1/(1+0.266), proposal.Confidence, 1e-3)
+
+		// Proposals below the minimum confidence are left out
+		assert.NoError(t, inferrer.Apply(proposals, 0.9))
+		assert.Zero(t, camera.ImageData.GPSLatitude)
+		assert.NoError(t, inferrer.Apply(proposals, 0.5))
+		assert.InDelta(t, 48.857, camera.ImageData.GPSLatitude, 1e-9)
+	})
+
+	t.Run("capture time", func(t *testing.T) {
+		// A stale fix, and a fix at sea level
+		stale := phone(t, 48.858, 2.294, time.Date(2000, 9, 2, 12, 31, 0, 0, time.UTC), time.Date(2000, 8, 30, 8, 0, 0, 0, time.UTC))
+		assert.True(t, stale.ImageData.GPS.HasIssue(GPSIssueTimestampMismatch))
+		assert.Equal(t, time.UTC, stale.ImageData.DateTimeOriginal.Location())
+		stale.ImageData.TimeOffset = "+02:00"
+		seaLevel := phone(t, 48.856, 2.292, time.Date(2000, 9, 2, 12, 29, 0, 0, time.UTC), time.Date(2000, 9, 2, 12, 29, 0, 0, time.UTC))
+		data := seaLevel.ImageData
+		data.GPSAltitude = 0
+		data.GPS.Altitude, data.GPS.HasAltitude = 0, true
+		seaLevel.ImageData = data
+
+		track, err := TrackFromImages([]*ImageInfo{stale, seaLevel})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, track.Points, 2) {
+			assert.Equal(t, time.Date(2000, 9, 2, 12, 29, 0, 0, time.UTC), track.Points[0].Time)
+			assert.True(t, track.Points[0].HasAltitude)
+			assert.Equal(t, time.Date(2000, 9, 2, 12, 31, 0, 0, time.UTC), track.Points[1].Time)
+		}
+	})
+
+	t.Run("no reference", func(t *testing.T) {
+		camera := loadImage(t, copySample(t, "samples/jpg/exif-org/exif-org-1.jpg"))
+		_, err := NewGeotagInferrer(Geotagging{}, []*ImageInfo{camera})
+		assert.Error(t, err)
+	})
+
+	t.Run("distance", func(t *testing.T) {
+		assert.InDelta(t, 343_500, haversineDistance(48.8566, 2.3522, 51.5074, -0.1278), 1000)
+		assert.Zero(t, haversineDistance(10, 20, 10, 20))
+	})
+}
@@ -0,0 +1,175 @@
+package media_image
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultGeotagMaxGap is the maximum gap used when Geotagging.MaxGap is zero.
+const DefaultGeotagMaxGap = 5 * time.Minute
+
+// Geotagging describes how images are matched against track logs.
+type Geotagging struct {
+	// ClockCorrection is added to the capture time of every image, e.g.
+	// 90*time.Second when the camera clock was 90 seconds late.
+	ClockCorrection time.Duration
+
+	// TimeOffset is the offset the camera clock was set to (e.g. "+01:00"),
+	// used for images recording no offset of their own.
+	TimeOffset string
+
+	// MaxGap is the maximum time between two track points a position is
+	// interpolated across, and the maximum time an image may be taken before
+	// the start or after the end of the track.
+	MaxGap time.Duration
+
+	// Overwrite geotags images already holding a location.
+	Overwrite bool
+
+	// WriteBack writes the positions into the image files when applied,
+	// rather than only updating their ImageData.
+	WriteBack bool
+}
+
+// GeotagResult is the position matched for a single image.
+type GeotagResult struct {
+	Image *ImageInfo
+
+	// Time is the resolved UTC capture time of the image.
+	Time time.Time
+
+	// Position is the interpolated track position, valid when Matched is true.
+	Position TrackPoint
+	Matched  bool
+
+	// Err is set when the capture time of the image cannot be resolved.
+	Err error
+}
+
+// Geotagger matches images against track logs to locate them.
+type Geotagger struct {
+	geotagging Geotagging
+	track      *Track
+}
+
+// NewGeotagger creates a new Geotagger matching images against the tracks.
+func NewGeotagger(geotagging Geotagging, tracks ...*Track) (*Geotagger, error) {
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("no track to geotag from")
+	}
+	if geotagging.MaxGap < 0 {
+		return nil, fmt.Errorf("invalid maximum gap: %s", geotagging.MaxGap)
+	}
+	if geotagging.MaxGap == 0 {
+		geotagging.MaxGap = DefaultGeotagMaxGap
+	}
+
+	if geotagging.TimeOffset != "" {
+		if _, err := parseTimeOffset(geotagging.TimeOffset); err != nil {
+			return nil, err
+		}
+	}
+	return &Geotagger{geotagging: geotagging, track: MergeTracks(tracks...)}, nil
+}
+
+// Preview matches every image against the tracks without modifying anything.
+// Images already located are skipped unless Overwrite is set.
+func (g *Geotagger) Preview(images []*ImageInfo) []GeotagResult {
+	results := make([]GeotagResult, 0, len(images))
+	for _, image := range images {
+		if !g.geotagging.Overwrite && (image.ImageData.GPSLatitude != 0 || image.ImageData.GPSLongitude != 0) {
+			continue
+		}
+
+		result := GeotagResult{Image: image}
+		result.Time, result.Err = g.captureTime(image)
+		if result.Err == nil {
+			result.Position, result.Matched = g.track.Locate(result.Time, g.geotagging.MaxGap)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Apply sets the position of the matched images in their ImageData, and in
+// their files when WriteBack is set. Unmatched results are skipped.
+func (g *Geotagger) Apply(results []GeotagResult) error {
+	var errs []error
+
+	for _, result := range results {
+		if !result.Matched {
+			continue
+		}
+
+		data := result.Image.ImageData
+		data.GPSLatitude = result.Position.Latitude
+		data.GPSLongitude = result.Position.Longitude
+		data.GPSAltitude = 0
+		if result.Position.HasAltitude {
+			data.GPSAltitude = result.Position.Altitude
+		}
+		data.GPSTimestamp = result.Time
+		setGeotag(&data, result.Position.HasAltitude)
+
+		// Written images are read again, deriving everything from their tags
+		if g.geotagging.WriteBack {
+			if err := result.Image.WriteExif(data); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", result.Image.FileInfo.Name(), err))
+			}
+			continue
+		}
+
+		// The time zone, local times, place and geohash are not stored in the
+		// file but derived from the position, as when reading it
+		data.GPSTimeZone = ""
+		data.GPSTimestampLocal = time.Time{}
+		exifDataParser := result.Image.exifDataParser()
+		if err := exifDataParser.processGPSCoordinates(&data, data.GPS); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Image.FileInfo.Name(), err))
+			continue
+		}
+		exifDataParser.processLocalTime(&data)
+		result.Image.Warnings = append(result.Image.Warnings, exifDataParser.Warnings()...)
+		result.Image.ImageData = data
+	}
+
+	return errors.Join(errs...)
+}
+
+// captureTime resolves the UTC capture time of an image as CaptureInstant
+// does, dates without a zone nor time offset taking the time offset of the
+// geotagging, then applies the clock correction.
+func (g *Geotagger) captureTime(image *ImageInfo) (time.Time, error) {
+	data := image.ImageData
+	if data.TimeOffset == "" {
+		data.TimeOffset = g.geotagging.TimeOffset
+	}
+
+	t, ok := data.CaptureInstant()
+	if !ok {
+		return time.Time{}, fmt.Errorf("no capture time or time offset known for %s", image.FileInfo.Name())
+	}
+	return t.Add(g.geotagging.ClockCorrection).UTC(), nil
+}
+
+// setGeotag updates the typed GPS data to the position, altitude and time of
+// the fix set in the flat fields of the image data.
+func setGeotag(data *ImageData, hasAltitude bool) {
+	var gps GPSData
+	if data.GPS != nil {
+		gps = *data.GPS
+	}
+	gps.Latitude, gps.Longitude, gps.HasPosition = data.GPSLatitude, data.GPSLongitude, true
+	gps.latitudeRef, gps.longitudeRef = "N", "E"
+	if gps.Latitude < 0 {
+		gps.latitudeRef = "S"
+	}
+	if gps.Longitude < 0 {
+		gps.longitudeRef = "W"
+	}
+	gps.Altitude, gps.HasAltitude = data.GPSAltitude, hasAltitude
+	gps.Timestamp = data.GPSTimestamp
+	validateGPSData(&gps, data)
+	data.GPS = &gps
+}
@@ -0,0 +1,137 @@
+package media_image
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Geotagger(t *testing.T) {
+	t.Log("Testing geotagger")
+
+	// The sample was taken on 2000-09-02 at 14:30:10, camera time
+	gpx := `<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk><trkseg>
+    <trkpt lat="48.0" lon="2.0"><ele>100</ele><time>2000-09-02T12:29:00Z</time></trkpt>
+    <trkpt lat="48.2" lon="2.2"><ele>120</ele><time>2000-09-02T12:31:00Z</time></trkpt>
+  </trkseg></trk>
+</gpx>`
+	trackPath := filepath.Join(t.TempDir(), "track.gpx")
+	if err := os.WriteFile(trackPath, []byte(gpx), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	track, err := LoadTrack(trackPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("write back", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		i := loadImage(t, path)
+
+		geotagger, err := NewGeotagger(Geotagging{
+			ClockCorrection: -10 * time.Second,
+			TimeOffset:      "+02:00",
+			WriteBack:       true,
+		}, track)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := geotagger.Preview([]*ImageInfo{i})
+		if assert.Len(t, results, 1) {
+			assert.NoError(t, results[0].Err)
+			assert.True(t, results[0].Matched)
+			assert.Equal(t, time.Date(2000, 9, 2, 12, 30, 0, 0, time.UTC), results[0].Time)
+		}
+		assert.NoError(t, geotagger.Apply(results))
+		assert.InDelta(t, 48.1, i.ImageData.GPSLatitude, 1e-9)
+		assert.Equal(t, "Europe/Paris", i.ImageData.GPSTimeZone)
+
+		reloaded := loadImage(t, path)
+		assert.InDelta(t, 48.1, reloaded.ImageData.GPSLatitude, 1e-6)
+		assert.InDelta(t, 2.1, reloaded.ImageData.GPSLongitude, 1e-6)
+		assert.InDelta(t, 110, reloaded.ImageData.GPSAltitude, 1e-6)
+		assert.Equal(t, time.Date(2000, 9, 2, 12, 30, 0, 0, time.UTC), reloaded.ImageData.GPSTimestamp.UTC())
+
+		// Located images are skipped
+		assert.Empty(t, geotagger.Preview([]*ImageInfo{reloaded}))
+	})
+
+	t.Run("in memory", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		i := loadImage(t, path)
+		i.ImageData.TimeOffset = "+02:00"
+
+		geotagger, _ := NewGeotagger(Geotagging{}, track)
+		results := geotagger.Preview([]*ImageInfo{i})
+		assert.True(t, results[0].Matched)
+		assert.NoError(t, geotagger.Apply(results))
+		assert.InDelta(t, 48.1+0.1/6, i.ImageData.GPSLatitude, 1e-6)
+		if assert.NotNil(t, i.ImageData.GPS) {
+			assert.True(t, i.ImageData.GPS.HasPosition)
+			assert.Equal(t, i.ImageData.GPSLatitude, i.ImageData.GPS.Latitude)
+		}
+		assert.Equal(t, "FR", i.ImageData.GPSCountryCode)
+		assert.Zero(t, loadImage(t, path).ImageData.GPSLatitude)
+	})
+
+	t.Run("same image data", func(t *testing.T) {
+		geotag := func(writeBack bool) ImageData {
+			i := loadImage(t, copySample(t, "samples/jpg/exif-org/exif-org-1.jpg"))
+			geotagger, _ := NewGeotagger(Geotagging{TimeOffset: "+02:00", WriteBack: writeBack}, track)
+			if err := geotagger.Apply(geotagger.Preview([]*ImageInfo{i})); err != nil {
+				t.Fatal(err)
+			}
+			return i.ImageData
+		}
+		written, inMemory := geotag(true), geotag(false)
+
+		assert.Equal(t, "Europe/Paris", inMemory.GPSTimeZone)
+		assert.Equal(t, written.GPSTimeZone, inMemory.GPSTimeZone)
+		assert.Equal(t, written.TimeOffset, inMemory.TimeOffset)
+		assert.Equal(t, written.DateTimeOriginal, inMemory.DateTimeOriginal)
+		assert.Equal(t, written.DateTimeDigitized, inMemory.DateTimeDigitized)
+		assert.False(t, inMemory.GPSTimestampLocal.IsZero())
+		assert.Equal(t, written.GPSTimestampLocal, inMemory.GPSTimestampLocal)
+		assert.Equal(t, written.GPSCity, inMemory.GPSCity)
+	})
+
+	t.Run("unmatched", func(t *testing.T) {
+		i := loadImage(t, copySample(t, "samples/jpg/exif-org/exif-org-1.jpg"))
+
+		// No time offset known
+		geotagger, _ := NewGeotagger(Geotagging{}, track)
+		results := geotagger.Preview([]*ImageInfo{i})
+		assert.Error(t, results[0].Err)
+
+		// Too far from the track
+		geotagger, _ = NewGeotagger(Geotagging{TimeOffset: "+01:00"}, track)
+		results = geotagger.Preview([]*ImageInfo{i})
+		assert.NoError(t, results[0].Err)
+		assert.False(t, results[0].Matched)
+	})
+
+	t.Run("capture time", func(t *testing.T) {
+		i := loadImage(t, copySample(t, "samples/jpg/exif-org/exif-org-1.jpg"))
+		i.ImageData.SubSecOriginal = "5"
+
+		// The time offset of the image comes before the one of the geotagging
+		geotagger, _ := NewGeotagger(Geotagging{TimeOffset: "+01:00", ClockCorrection: -10 * time.Second}, track)
+		i.ImageData.TimeOffset = "+02:00"
+		instant, _ := i.ImageData.CaptureInstant()
+		results := geotagger.Preview([]*ImageInfo{i})
+		assert.Equal(t, instant.Add(-10*time.Second).UTC(), results[0].Time)
+		assert.Equal(t, time.Date(2000, 9, 2, 12, 30, 0, 500_000_000, time.UTC), results[0].Time)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewGeotagger(Geotagging{})
+		assert.Error(t, err)
+		_, err = NewGeotagger(Geotagging{TimeOffset: "CET"}, track)
+		assert.Error(t, err)
+	})
+}
@@ -5,6 +5,7 @@ go 1.23.1
 require (
 	github.com/dsoprea/go-exif/v3 v3.0.1
 	github.com/dsoprea/go-heic-exif-extractor/v2 v2.0.0-20210512044107-62067e44c235
+	github.com/dsoprea/go-iptc v0.0.0-20200610044640-bc9ca208b413
 	github.com/dsoprea/go-jpeg-image-structure/v2 v2.0.0-20221012074422-4f3f7e934102
 	github.com/dsoprea/go-png-image-structure/v2 v2.0.0-20210512210324-29b889a6093d
 	github.com/dsoprea/go-tiff-image-structure/v2 v2.0.0-20221003165014-8ecc4f52edca
@@ -13,12 +14,12 @@ require (
 	github.com/smartmediafiles/media v0.0.0-20241010185111-a82129fb8a71
 	github.com/smartmediafiles/media.fs v0.0.0-20241123203055-a50f0abe24e5
 	github.com/stretchr/testify v1.9.0
+	github.com/tidwall/rtree v1.10.0
 	golang.org/x/image v0.22.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/dsoprea/go-iptc v0.0.0-20200610044640-bc9ca208b413 // indirect
 	github.com/dsoprea/go-logging v0.0.0-20200710184922-b02d349568dd // indirect
 	github.com/dsoprea/go-photoshop-info-format v0.0.0-20200610045659-121dd752914d // indirect
 	github.com/dsoprea/go-utility/v2 v2.0.0-20221003172846-a3e1774ef349 // indirect
@@ -32,7 +33,6 @@ require (
 	github.com/ringsaturn/tzf-rel-lite v0.0.2024-b // indirect
 	github.com/tidwall/geoindex v1.7.0 // indirect
 	github.com/tidwall/geojson v1.4.5 // indirect
-	github.com/tidwall/rtree v1.10.0 // indirect
 	github.com/twpayne/go-polyline v1.1.1 // indirect
 	go.mongodb.org/mongo-driver v1.17.1 // indirect
 	go4.org v0.0.0-20230225012048-214862532bf5 // indirect
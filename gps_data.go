@@ -0,0 +1,260 @@
+package media_image
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Units of the GPSSpeedRef and GPSDestDistanceRef tags.
+const (
+	GPSUnitKilometers = "K" // km/h for speeds
+	GPSUnitMiles      = "M" // mph for speeds
+	GPSUnitKnots      = "N" // knots for speeds, nautical miles for distances
+)
+
+// References of the GPS bearing tags.
+const (
+	GPSBearingTrue     = "T"
+	GPSBearingMagnetic = "M"
+)
+
+// gpsUnitMeters is the length, in meters, of the GPS distance units.
+var gpsUnitMeters = map[string]float64{
+	GPSUnitKilometers: 1000,
+	GPSUnitMiles:      1609.344,
+	GPSUnitKnots:      1852,
+}
+
+// Tags of the GPS IFD.
+const (
+	gpsTagLatitudeRef       = 0x0001
+	gpsTagLatitude          = 0x0002
+	gpsTagLongitudeRef      = 0x0003
+	gpsTagLongitude         = 0x0004
+	gpsTagAltitudeRef       = 0x0005
+	gpsTagAltitude          = 0x0006
+	gpsTagTimeStamp         = 0x0007
+	gpsTagSpeedRef          = 0x000c
+	gpsTagSpeed             = 0x000d
+	gpsTagTrackRef          = 0x000e
+	gpsTagTrack             = 0x000f
+	gpsTagImgDirectionRef   = 0x0010
+	gpsTagImgDirection      = 0x0011
+	gpsTagMapDatum          = 0x0012
+	gpsTagDestLatitudeRef   = 0x0013
+	gpsTagDestLatitude      = 0x0014
+	gpsTagDestLongitudeRef  = 0x0015
+	gpsTagDestLongitude     = 0x0016
+	gpsTagDestBearingRef    = 0x0017
+	gpsTagDestBearing       = 0x0018
+	gpsTagDestDistanceRef   = 0x0019
+	gpsTagDestDistance      = 0x001a
+	gpsTagDateStamp         = 0x001d
+	gpsTagHPositioningError = 0x001f
+)
+
+// GPSBearing is a direction, in degrees clockwise from north.
+type GPSBearing struct {
+	Degrees float64
+	Ref     string // GPSBearingTrue or GPSBearingMagnetic
+	Valid   bool
+}
+
+// Magnetic checks if the bearing is relative to the magnetic north.
+func (b GPSBearing) Magnetic() bool {
+	return b.Ref == GPSBearingMagnetic
+}
+
+// GPSData is the content of the GPS IFD, with every value converted to
+// signed decimal degrees, meters and meters per second using its reference
+// tag. Values missing from the IFD have their Has or Valid flag unset.
+type GPSData struct {
+	Latitude    float64 // Negative in the southern hemisphere
+	Longitude   float64 // Negative in the western hemisphere
+	HasPosition bool
+
+	Altitude    float64 // Meters, negative below sea level
+	HasAltitude bool
+
+	Timestamp time.Time // UTC time of the fix
+	MapDatum  string
+
+	Speed     float64 // Meters per second
+	SpeedUnit string  // Unit the speed was recorded in
+	HasSpeed  bool
+
+	Track        GPSBearing // Direction of movement
+	ImgDirection GPSBearing // Direction the camera was pointing to
+
+	DestLatitude   float64
+	DestLongitude  float64
+	HasDestination bool
+	DestBearing    GPSBearing
+
+	DestDistance     float64 // Meters
+	DestDistanceUnit string  // Unit the distance was recorded in
+	HasDestDistance  bool
+
+	HPositioningError    float64 // Meters
+	HasHPositioningError bool
+
+	// Issues lists the reasons the fix is suspicious, empty when it is sound
+	Issues []GPSIssue
+
+	// References of the coordinates, as recorded
+	latitudeRef  string
+	longitudeRef string
+
+	// swapSuspected is set when the swap was only inferred from the time
+	// offset of the image, which a camera clock set to another zone explains
+	// as well
+	swapSuspected bool
+}
+
+// gpsIfdReader reads the values of a GPS IFD.
+type gpsIfdReader struct {
+	ifdReader
+}
+
+// ref returns the value of a reference tag, upper-cased.
+func (r gpsIfdReader) ref(tagId uint16) string {
+	switch v := r.value(tagId).(type) {
+	case string:
+		return strings.ToUpper(strings.TrimSpace(strings.TrimRight(v, "\x00")))
+	case []byte:
+		return strings.ToUpper(strings.TrimSpace(strings.TrimRight(string(v), "\x00")))
+	}
+	return ""
+}
+
+// coordinate returns a signed coordinate from its degrees, minutes and
+// seconds and its reference. A missing reference is taken as positive.
+func (r gpsIfdReader) coordinate(tagId uint16, refTagId uint16, negativeRef string) (float64, bool) {
+	values, ok := r.rationals(tagId, 1)
+	if !ok {
+		return 0, false
+	}
+
+	// Some writers store the minutes or seconds in the degrees only
+	coordinate := values[0]
+	if len(values) > 1 {
+		coordinate += values[1] / 60
+	}
+	if len(values) > 2 {
+		coordinate += values[2] / 3600
+	}
+	if math.IsNaN(coordinate) || math.IsInf(coordinate, 0) {
+		return 0, false
+	}
+
+	if r.ref(refTagId) == negativeRef {
+		coordinate = -coordinate
+	}
+	return coordinate, true
+}
+
+// bearing returns a bearing and its reference, true north by default.
+func (r gpsIfdReader) bearing(tagId uint16, refTagId uint16) GPSBearing {
+	degrees, ok := r.rational(tagId)
+	if !ok {
+		return GPSBearing{}
+	}
+	ref := r.ref(refTagId)
+	if ref != GPSBearingMagnetic {
+		ref = GPSBearingTrue
+	}
+	return GPSBearing{Degrees: degrees, Ref: ref, Valid: true}
+}
+
+// unit returns the unit of a speed or distance, kilometers by default.
+func (r gpsIfdReader) unit(refTagId uint16) string {
+	ref := r.ref(refTagId)
+	if _, ok := gpsUnitMeters[ref]; !ok {
+		return GPSUnitKilometers
+	}
+	return ref
+}
+
+// timestamp returns the UTC date and time of the fix.
+func (r gpsIfdReader) timestamp() (time.Time, bool) {
+	date, ok := r.value(gpsTagDateStamp).(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	day, err := time.Parse("2006:01:02", strings.TrimRight(strings.TrimSpace(date), "\x00"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	clock, ok := r.rationals(gpsTagTimeStamp, 3)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	seconds := clock[0]*3600 + clock[1]*60 + clock[2]
+	return day.Add(time.Duration(math.Round(seconds*1000)) * time.Millisecond), true
+}
+
+// parseGPSData reads the GPS IFD through reader.
+func parseGPSData(reader ifdReader) *GPSData {
+	r := gpsIfdReader{reader}
+	gps := &GPSData{}
+
+	latitude, hasLatitude := r.coordinate(gpsTagLatitude, gpsTagLatitudeRef, "S")
+	longitude, hasLongitude := r.coordinate(gpsTagLongitude, gpsTagLongitudeRef, "W")
+	if hasLatitude && hasLongitude {
+		gps.Latitude, gps.Longitude, gps.HasPosition = latitude, longitude, true
+	}
+	gps.latitudeRef = r.ref(gpsTagLatitudeRef)
+	gps.longitudeRef = r.ref(gpsTagLongitudeRef)
+
+	if altitude, ok := r.rational(gpsTagAltitude); ok {
+		// The reference is a byte, 1 meaning below sea level
+		if ref, ok := r.value(gpsTagAltitudeRef).([]byte); ok && len(ref) > 0 && ref[0] == 1 {
+			altitude = -altitude
+		}
+		gps.Altitude, gps.HasAltitude = altitude, true
+	}
+
+	gps.Timestamp, _ = r.timestamp()
+	if datum, ok := r.value(gpsTagMapDatum).(string); ok {
+		gps.MapDatum = strings.TrimSpace(strings.TrimRight(datum, "\x00"))
+	}
+
+	if speed, ok := r.rational(gpsTagSpeed); ok {
+		gps.SpeedUnit = r.unit(gpsTagSpeedRef)
+		gps.Speed = speed * gpsUnitMeters[gps.SpeedUnit] / 3600
+		gps.HasSpeed = true
+	}
+
+	gps.Track = r.bearing(gpsTagTrack, gpsTagTrackRef)
+	gps.ImgDirection = r.bearing(gpsTagImgDirection, gpsTagImgDirectionRef)
+	gps.DestBearing = r.bearing(gpsTagDestBearing, gpsTagDestBearingRef)
+
+	destLatitude, hasDestLatitude := r.coordinate(gpsTagDestLatitude, gpsTagDestLatitudeRef, "S")
+	destLongitude, hasDestLongitude := r.coordinate(gpsTagDestLongitude, gpsTagDestLongitudeRef, "W")
+	if hasDestLatitude && hasDestLongitude {
+		gps.DestLatitude, gps.DestLongitude, gps.HasDestination = destLatitude, destLongitude, true
+	}
+
+	if distance, ok := r.rational(gpsTagDestDistance); ok {
+		gps.DestDistanceUnit = r.unit(gpsTagDestDistanceRef)
+		gps.DestDistance = distance * gpsUnitMeters[gps.DestDistanceUnit]
+		gps.HasDestDistance = true
+	}
+
+	if hError, ok := r.rational(gpsTagHPositioningError); ok {
+		gps.HPositioningError, gps.HasHPositioningError = hError, true
+	}
+
+	return gps
+}
+
+// String returns a short description of the GPS data.
+func (g *GPSData) String() string {
+	if !g.HasPosition {
+		return "GPSData<no position>"
+	}
+	return fmt.Sprintf("GPSData<LAT=(%.05f) LON=(%.05f) ALT=(%.1f) TIME=[%s]>", g.Latitude, g.Longitude, g.Altitude, g.Timestamp)
+}
@@ -0,0 +1,97 @@
+package media_image
+
+import (
+	"testing"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GPSData(t *testing.T) {
+	t.Log("Testing GPS data")
+
+	t.Run("sample", func(t *testing.T) {
+		i := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+		gps := i.ImageData.GPS
+		if !assert.NotNil(t, gps) {
+			return
+		}
+		assert.True(t, gps.HasPosition)
+		assert.InDelta(t, 43.467157, gps.Latitude, 1e-6)
+		assert.InDelta(t, 11.885395, gps.Longitude, 1e-6)
+		assert.Equal(t, "WGS-84", gps.MapDatum)
+		assert.Equal(t, gps.Latitude, i.ImageData.GPSLatitude)
+
+		assert.Nil(t, loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg").ImageData.GPS)
+	})
+
+	t.Run("references", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		edit := metadataEdit{exif: func(editor *exifEditor) error {
+			values := []struct {
+				name  string
+				value interface{}
+			}{
+				{"GPSAltitudeRef", []byte{1}},
+				{"GPSAltitude", []exifcommon.Rational{{Numerator: 25, Denominator: 2}}},
+				{"GPSSpeedRef", "N"},
+				{"GPSSpeed", []exifcommon.Rational{{Numerator: 10, Denominator: 1}}},
+				{"GPSTrackRef", "M"},
+				{"GPSTrack", []exifcommon.Rational{{Numerator: 91, Denominator: 2}}},
+				{"GPSImgDirection", []exifcommon.Rational{{Numerator: 270, Denominator: 1}}},
+				{"GPSDestLatitudeRef", "S"},
+				{"GPSDestLatitude", []exifcommon.Rational{{Numerator: 33, Denominator: 1}, {Numerator: 30, Denominator: 1}, {Numerator: 0, Denominator: 1}}},
+				{"GPSDestLongitudeRef", "W"},
+				{"GPSDestLongitude", []exifcommon.Rational{{Numerator: 70, Denominator: 1}, {Numerator: 15, Denominator: 1}, {Numerator: 36, Denominator: 1}}},
+				{"GPSDestDistanceRef", "M"},
+				{"GPSDestDistance", []exifcommon.Rational{{Numerator: 2, Denominator: 1}}},
+				{"GPSHPositioningError", []exifcommon.Rational{{Numerator: 15, Denominator: 4}}},
+			}
+			for _, v := range values {
+				if err := editor.set(ifdPathGps, v.name, v.value); err != nil {
+					return err
+				}
+			}
+			return nil
+		}}
+		if err := NewExifWriter().update(path, ImageJpeg, edit); err != nil {
+			t.Fatal(err)
+		}
+
+		i := loadImage(t, path)
+		gps := i.ImageData.GPS
+		assert.Equal(t, -12.5, gps.Altitude)
+		assert.Equal(t, -12.5, i.ImageData.GPSAltitude)
+		assert.Equal(t, GPSUnitKnots, gps.SpeedUnit)
+		assert.InDelta(t, 5.144444, gps.Speed, 1e-6)
+		assert.InDelta(t, 5.144444, i.ImageData.GPSSpeed, 1e-6)
+		assert.Equal(t, GPSBearing{Degrees: 45.5, Ref: GPSBearingMagnetic, Valid: true}, gps.Track)
+		assert.True(t, gps.Track.Magnetic())
+		assert.Equal(t, GPSBearing{Degrees: 270, Ref: GPSBearingTrue, Valid: true}, gps.ImgDirection)
+		assert.False(t, gps.DestBearing.Valid)
+		assert.True(t, gps.HasDestination)
+		assert.InDelta(t, -33.5, i.ImageData.GPSDestLatitude, 1e-9)
+		assert.InDelta(t, -70.26, i.ImageData.GPSDestLongitude, 1e-9)
+		assert.Equal(t, GPSUnitMiles, gps.DestDistanceUnit)
+		assert.InDelta(t, 3218.688, i.ImageData.GPSDestDistance, 1e-9)
+		assert.Equal(t, 3.75, i.ImageData.GPSHPositioningError)
+	})
+
+	t.Run("write units", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		i := loadImage(t, path)
+		data := i.ImageData
+		data.GPSSpeed = 12.5
+		data.GPSDestDistance = 1500
+		data.GPSAltitude = -3.25
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		gps := loadImage(t, path).ImageData.GPS
+		assert.Equal(t, GPSUnitKilometers, gps.SpeedUnit)
+		assert.InDelta(t, 12.5, gps.Speed, 1e-9)
+		assert.InDelta(t, 1500, gps.DestDistance, 1e-9)
+		assert.Equal(t, -3.25, gps.Altitude)
+	})
+}
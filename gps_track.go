@@ -0,0 +1,464 @@
+package media_image
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrackPoint is a timestamped position recorded by a GPS logger.
+type TrackPoint struct {
+	Time        time.Time
+	Latitude    float64
+	Longitude   float64
+	Altitude    float64
+	HasAltitude bool
+}
+
+// Track is a list of track points sorted by time.
+type Track struct {
+	Points []TrackPoint
+}
+
+// LoadTrack loads a GPX, KML or NMEA track log, selecting the format from the
+// file extension.
+func LoadTrack(path string) (*Track, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var track *Track
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".gpx":
+		track, err = ParseGpx(file)
+	case ".kml":
+		track, err = ParseKml(file)
+	case ".nmea", ".nma":
+		track, err = ParseNmea(file)
+	default:
+		return nil, fmt.Errorf("unsupported track format: %s", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return track, nil
+}
+
+// newTrack creates a track from unsorted points.
+func newTrack(points []TrackPoint) (*Track, error) {
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no timestamped track point found")
+	}
+	sort.SliceStable(points, func(a, b int) bool { return points[a].Time.Before(points[b].Time) })
+	return &Track{Points: points}, nil
+}
+
+// MergeTracks merges several tracks into a single one sorted by time.
+func MergeTracks(tracks ...*Track) *Track {
+	var points []TrackPoint
+	for _, track := range tracks {
+		points = append(points, track.Points...)
+	}
+	sort.SliceStable(points, func(a, b int) bool { return points[a].Time.Before(points[b].Time) })
+	return &Track{Points: points}
+}
+
+// Start returns the time of the first point of the track.
+func (t *Track) Start() time.Time {
+	if len(t.Points) == 0 {
+		return time.Time{}
+	}
+	return t.Points[0].Time
+}
+
+// End returns the time of the last point of the track.
+func (t *Track) End() time.Time {
+	if len(t.Points) == 0 {
+		return time.Time{}
+	}
+	return t.Points[len(t.Points)-1].Time
+}
+
+// Locate returns the position at the given time, interpolated between the
+// surrounding track points. Positions between points more than maxGap apart,
+// or more than maxGap away from the track, are not located.
+func (t *Track) Locate(at time.Time, maxGap time.Duration) (TrackPoint, bool) {
+	p, _, _, ok := t.locate(at, maxGap)
+	return p, ok
+}
+
+// locate returns the position at the given time along with the track points
+// it was computed from. Both points are the same when the time matches a
+// point or lies outside of the track.
+func (t *Track) locate(at time.Time, maxGap time.Duration) (TrackPoint, TrackPoint, TrackPoint, bool) {
+	n := len(t.Points)
+	if n == 0 {
+		return TrackPoint{}, TrackPoint{}, TrackPoint{}, false
+	}
+
+	// Index of the first point after the time
+	next := sort.Search(n, func(k int) bool { return t.Points[k].Time.After(at) })
+	switch {
+	case next == 0:
+		return t.nearest(0, at, maxGap)
+	case next == n:
+		return t.nearest(n-1, at, maxGap)
+	}
+
+	before, after := t.Points[next-1], t.Points[next]
+	if before.Time.Equal(at) {
+		return withTime(before, at), before, before, true
+	}
+	if after.Time.Sub(before.Time) > maxGap {
+		return TrackPoint{}, TrackPoint{}, TrackPoint{}, false
+	}
+	return interpolateTrackPoints(before, after, at), before, after, true
+}
+
+// nearest returns the point at the end of the track if it is close enough.
+func (t *Track) nearest(index int, at time.Time, maxGap time.Duration) (TrackPoint, TrackPoint, TrackPoint, bool) {
+	gap := at.Sub(t.Points[index].Time)
+	if gap < 0 {
+		gap = -gap
+	}
+	if gap > maxGap {
+		return TrackPoint{}, TrackPoint{}, TrackPoint{}, false
+	}
+	return withTime(t.Points[index], at), t.Points[index], t.Points[index], true
+}
+
+// withTime returns the point with its time replaced.
+func withTime(p TrackPoint, at time.Time) TrackPoint {
+	p.Time = at
+	return p
+}
+
+// interpolateTrackPoints linearly interpolates the position between two points.
+func interpolateTrackPoints(before, after TrackPoint, at time.Time) TrackPoint {
+	ratio := float64(at.Sub(before.Time)) / float64(after.Time.Sub(before.Time))
+
+	// Go the short way around when crossing the antimeridian
+	afterLongitude := after.Longitude
+	if afterLongitude-before.Longitude > 180 {
+		afterLongitude -= 360
+	} else if before.Longitude-afterLongitude > 180 {
+		afterLongitude += 360
+	}
+	longitude := before.Longitude + (afterLongitude-before.Longitude)*ratio
+	if longitude > 180 {
+		longitude -= 360
+	} else if longitude < -180 {
+		longitude += 360
+	}
+
+	p := TrackPoint{
+		Time:      at,
+		Latitude:  before.Latitude + (after.Latitude-before.Latitude)*ratio,
+		Longitude: longitude,
+	}
+	if before.HasAltitude && after.HasAltitude {
+		p.Altitude = before.Altitude + (after.Altitude-before.Altitude)*ratio
+		p.HasAltitude = true
+	}
+	return p
+}
+
+// gpxFile holds the track points of a GPX file.
+type gpxFile struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+// gpxPoint is a GPX track or route point.
+type gpxPoint struct {
+	Latitude  float64  `xml:"lat,attr"`
+	Longitude float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele"`
+	Time      string   `xml:"time"`
+}
+
+// ParseGpx parses the track and route points of a GPX document. Points
+// without a time are ignored.
+func ParseGpx(r io.Reader) (*Track, error) {
+	var gpx gpxFile
+	if err := xml.NewDecoder(r).Decode(&gpx); err != nil {
+		return nil, fmt.Errorf("invalid GPX document: %w", err)
+	}
+
+	var points []TrackPoint
+	add := func(p gpxPoint) {
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(p.Time))
+		if err != nil {
+			return
+		}
+		point := TrackPoint{Time: t, Latitude: p.Latitude, Longitude: p.Longitude}
+		if p.Elevation != nil {
+			point.Altitude = *p.Elevation
+			point.HasAltitude = true
+		}
+		points = append(points, point)
+	}
+
+	for _, trk := range gpx.Tracks {
+		for _, seg := range trk.Segments {
+			for _, p := range seg.Points {
+				add(p)
+			}
+		}
+	}
+	for _, rte := range gpx.Routes {
+		for _, p := range rte.Points {
+			add(p)
+		}
+	}
+
+	return newTrack(points)
+}
+
+// ParseKml parses the positions of a KML document, either from gx:Track
+// elements or from placemarks holding a time stamp and a point.
+func ParseKml(r io.Reader) (*Track, error) {
+	var points []TrackPoint
+	var stack []string
+	var whens []time.Time
+	var coords []string
+	var placemarkTime time.Time
+	var placemarkCoords string
+
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid KML document: %w", err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, token.Name.Local)
+			switch token.Name.Local {
+			case "Track":
+				whens, coords = nil, nil
+			case "Placemark":
+				placemarkTime, placemarkCoords = time.Time{}, ""
+			}
+
+		case xml.CharData:
+			if len(stack) < 2 {
+				continue
+			}
+			text := strings.TrimSpace(string(token))
+			switch parent, name := stack[len(stack)-2], stack[len(stack)-1]; {
+			case parent == "Track" && name == "when":
+				t, _ := time.Parse(time.RFC3339Nano, text)
+				whens = append(whens, t)
+			case parent == "Track" && name == "coord":
+				coords = append(coords, text)
+			case parent == "TimeStamp" && name == "when":
+				placemarkTime, _ = time.Parse(time.RFC3339Nano, text)
+			case parent == "Point" && name == "coordinates":
+				placemarkCoords = text
+			}
+
+		case xml.EndElement:
+			switch token.Name.Local {
+			case "Track":
+				for k := 0; k < len(whens) && k < len(coords); k++ {
+					if p, ok := parseKmlCoordinates(whens[k], strings.Fields(coords[k])); ok {
+						points = append(points, p)
+					}
+				}
+			case "Placemark":
+				if p, ok := parseKmlCoordinates(placemarkTime, strings.Split(placemarkCoords, ",")); ok {
+					points = append(points, p)
+				}
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	return newTrack(points)
+}
+
+// parseKmlCoordinates parses a KML position given as longitude, latitude and
+// an optional altitude.
+func parseKmlCoordinates(t time.Time, values []string) (TrackPoint, bool) {
+	if t.IsZero() || len(values) < 2 {
+		return TrackPoint{}, false
+	}
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(values[0]), 64)
+	if err != nil {
+		return TrackPoint{}, false
+	}
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(values[1]), 64)
+	if err != nil {
+		return TrackPoint{}, false
+	}
+
+	p := TrackPoint{Time: t, Latitude: latitude, Longitude: longitude}
+	if len(values) > 2 {
+		if altitude, err := strconv.ParseFloat(strings.TrimSpace(values[2]), 64); err == nil {
+			p.Altitude = altitude
+			p.HasAltitude = true
+		}
+	}
+	return p, true
+}
+
+// ParseNmea parses the RMC and GGA sentences of an NMEA 0183 log. GGA
+// sentences carry no date and are dated by the previous RMC sentence.
+// Sentences with an invalid checksum or fix are ignored.
+func ParseNmea(r io.Reader) (*Track, error) {
+	var points []TrackPoint
+	var date time.Time
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields, ok := splitNmeaSentence(scanner.Text())
+		if !ok || len(fields[0]) != 5 {
+			continue
+		}
+
+		var p TrackPoint
+		switch fields[0][2:] {
+		case "RMC":
+			// $--RMC,hhmmss.ss,A,llll.ll,a,yyyyy.yy,a,speed,course,ddmmyy,...
+			if len(fields) < 10 || fields[2] != "A" {
+				continue
+			}
+			d, err := time.Parse("020106", fields[9])
+			if err != nil {
+				continue
+			}
+			date = d
+			if p, ok = parseNmeaPosition(date, fields[1], fields[3:7]); !ok {
+				continue
+			}
+
+		case "GGA":
+			// $--GGA,hhmmss.ss,llll.ll,a,yyyyy.yy,a,quality,satellites,hdop,altitude,M,...
+			if len(fields) < 10 || date.IsZero() || fields[6] == "" || fields[6] == "0" {
+				continue
+			}
+			if p, ok = parseNmeaPosition(date, fields[1], fields[2:6]); !ok {
+				continue
+			}
+			if altitude, err := strconv.ParseFloat(fields[9], 64); err == nil {
+				p.Altitude = altitude
+				p.HasAltitude = true
+			}
+
+		default:
+			continue
+		}
+
+		// RMC and GGA sentences of the same fix describe a single point
+		if last := len(points) - 1; last >= 0 && points[last].Time.Equal(p.Time) {
+			if p.HasAltitude {
+				points[last].Altitude = p.Altitude
+				points[last].HasAltitude = true
+			}
+			continue
+		}
+		points = append(points, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return newTrack(points)
+}
+
+// splitNmeaSentence verifies the checksum of an NMEA sentence, when present,
+// and returns its fields, the first one being the talker and sentence type.
+func splitNmeaSentence(line string) ([]string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "$") {
+		return nil, false
+	}
+	line = line[1:]
+
+	if body, checksum, found := strings.Cut(line, "*"); found {
+		expected, err := strconv.ParseUint(checksum, 16, 8)
+		if err != nil {
+			return nil, false
+		}
+		var sum byte
+		for k := 0; k < len(body); k++ {
+			sum ^= body[k]
+		}
+		if uint64(sum) != expected {
+			return nil, false
+		}
+		line = body
+	}
+	return strings.Split(line, ","), true
+}
+
+// parseNmeaPosition parses the time of day and the latitude, latitude
+// hemisphere, longitude and longitude hemisphere fields of an NMEA sentence.
+func parseNmeaPosition(date time.Time, clock string, fields []string) (TrackPoint, bool) {
+	if len(clock) < 6 {
+		return TrackPoint{}, false
+	}
+	seconds, err := strconv.ParseFloat(clock[4:], 64)
+	if err != nil {
+		return TrackPoint{}, false
+	}
+	hours, errHours := strconv.Atoi(clock[0:2])
+	minutes, errMinutes := strconv.Atoi(clock[2:4])
+	if errHours != nil || errMinutes != nil {
+		return TrackPoint{}, false
+	}
+
+	latitude, ok := parseNmeaCoordinate(fields[0], fields[1], "N", "S")
+	if !ok {
+		return TrackPoint{}, false
+	}
+	longitude, ok := parseNmeaCoordinate(fields[2], fields[3], "E", "W")
+	if !ok {
+		return TrackPoint{}, false
+	}
+
+	t := date.Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)))
+	return TrackPoint{Time: t, Latitude: latitude, Longitude: longitude}, true
+}
+
+// parseNmeaCoordinate parses a coordinate written as degrees and decimal
+// minutes (e.g. "4807.038") with its hemisphere.
+func parseNmeaCoordinate(value string, hemisphere string, positive string, negative string) (float64, bool) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	degrees := float64(int(v / 100))
+	coordinate := degrees + (v-degrees*100)/60
+
+	switch hemisphere {
+	case positive:
+		return coordinate, true
+	case negative:
+		return -coordinate, true
+	}
+	return 0, false
+}
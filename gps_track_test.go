@@ -0,0 +1,140 @@
+package media_image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nmeaSentence appends the checksum to the body of an NMEA sentence.
+func nmeaSentence(body string) string {
+	var sum byte
+	for k := 0; k < len(body); k++ {
+		sum ^= body[k]
+	}
+	return fmt.Sprintf("$%s*%02X", body, sum)
+}
+
+func Test_Track(t *testing.T) {
+	t.Log("Testing track logs")
+
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	t.Run("gpx", func(t *testing.T) {
+		track, err := ParseGpx(strings.NewReader(`<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk><trkseg>
+    <trkpt lat="48.2" lon="2.2"><ele>120</ele><time>2000-09-02T12:31:00Z</time></trkpt>
+    <trkpt lat="48.0" lon="2.0"><ele>100</ele><time>2000-09-02T12:29:00Z</time></trkpt>
+    <trkpt lat="47.0" lon="1.0"></trkpt>
+  </trkseg></trk>
+</gpx>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, track.Points, 2)
+		assert.Equal(t, at("2000-09-02T12:29:00Z"), track.Start())
+		assert.Equal(t, at("2000-09-02T12:31:00Z"), track.End())
+		assert.True(t, track.Points[0].HasAltitude)
+	})
+
+	t.Run("kml", func(t *testing.T) {
+		track, err := ParseKml(strings.NewReader(`<?xml version="1.0"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+  <Document>
+    <Placemark><gx:Track>
+      <when>2000-09-02T12:29:00Z</when>
+      <when>2000-09-02T12:31:00Z</when>
+      <gx:coord>2.0 48.0 100</gx:coord>
+      <gx:coord>2.2 48.2 120</gx:coord>
+    </gx:Track></Placemark>
+    <Placemark>
+      <TimeStamp><when>2000-09-02T12:35:00Z</when></TimeStamp>
+      <Point><coordinates>2.5,48.5</coordinates></Point>
+    </Placemark>
+  </Document>
+</kml>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, track.Points, 3)
+		assert.Equal(t, TrackPoint{Time: at("2000-09-02T12:31:00Z"), Latitude: 48.2, Longitude: 2.2, Altitude: 120, HasAltitude: true}, track.Points[1])
+		assert.Equal(t, TrackPoint{Time: at("2000-09-02T12:35:00Z"), Latitude: 48.5, Longitude: 2.5}, track.Points[2])
+	})
+
+	t.Run("nmea", func(t *testing.T) {
+		log := strings.Join([]string{
+			nmeaSentence("GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W"),
+			nmeaSentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"),
+			nmeaSentence("GNRMC,123520.5,A,4807.100,S,01131.100,W,022.4,084.4,230394,003.1,W"),
+			nmeaSentence("GPRMC,123521,V,4807.100,N,01131.100,E,022.4,084.4,230394,003.1,W"),
+			"$GPRMC,123522,A,4807.100,N,01131.100,E,022.4,084.4,230394,003.1,W*00",
+		}, "\r\n")
+		track, err := ParseNmea(strings.NewReader(log))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, track.Points, 2) {
+			assert.Equal(t, at("1994-03-23T12:35:19Z"), track.Points[0].Time)
+			assert.InDelta(t, 48.1173, track.Points[0].Latitude, 1e-4)
+			assert.InDelta(t, 11.516667, track.Points[0].Longitude, 1e-6)
+			assert.InDelta(t, 545.4, track.Points[0].Altitude, 1e-9)
+			assert.Equal(t, at("1994-03-23T12:35:20Z").Add(500*time.Millisecond), track.Points[1].Time)
+			assert.InDelta(t, -48.118333, track.Points[1].Latitude, 1e-6)
+			assert.InDelta(t, -11.518333, track.Points[1].Longitude, 1e-6)
+			assert.False(t, track.Points[1].HasAltitude)
+		}
+	})
+
+	t.Run("locate", func(t *testing.T) {
+		track := &Track{Points: []TrackPoint{
+			{Time: at("2000-09-02T12:00:00Z"), Latitude: 10, Longitude: 179, Altitude: 100, HasAltitude: true},
+			{Time: at("2000-09-02T12:02:00Z"), Latitude: 12, Longitude: -179, Altitude: 200, HasAltitude: true},
+			{Time: at("2000-09-02T13:00:00Z"), Latitude: 20, Longitude: -170},
+		}}
+
+		p, ok := track.Locate(at("2000-09-02T12:01:30Z"), time.Minute*5)
+		assert.True(t, ok)
+		assert.InDelta(t, 11.5, p.Latitude, 1e-9)
+		assert.InDelta(t, -179.5, p.Longitude, 1e-9)
+		assert.InDelta(t, 175, p.Altitude, 1e-9)
+
+		// Across a gap longer than allowed
+		_, ok = track.Locate(at("2000-09-02T12:30:00Z"), time.Minute*5)
+		assert.False(t, ok)
+
+		// Close to the end of the track
+		p, ok = track.Locate(at("2000-09-02T13:04:00Z"), time.Minute*5)
+		assert.True(t, ok)
+		assert.Equal(t, 20.0, p.Latitude)
+		assert.False(t, p.HasAltitude)
+		_, ok = track.Locate(at("2000-09-02T11:54:00Z"), time.Minute*5)
+		assert.False(t, ok)
+	})
+
+	t.Run("load", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "track.nmea")
+		log := nmeaSentence("GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W")
+		if err := os.WriteFile(path, []byte(log), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		track, err := LoadTrack(path)
+		assert.NoError(t, err)
+		assert.Len(t, track.Points, 1)
+
+		_, err = LoadTrack(filepath.Join(dir, "track.csv"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,164 @@
+package media_image
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// GPSIssue is a reason a GPS fix is suspicious.
+type GPSIssue string
+
+// Issues found by the GPS validation.
+const (
+	// GPSIssueNullIsland is a position at 0,0, written by devices without a fix
+	GPSIssueNullIsland GPSIssue = "null island"
+
+	// GPSIssueOutOfRange is a latitude beyond ±90 or a longitude beyond ±180
+	GPSIssueOutOfRange GPSIssue = "coordinates out of range"
+
+	// GPSIssueSwapped is a latitude and longitude recorded in each other's tags.
+	// Positions only suspected of it from the time offset of the image still
+	// locate the image, but do not decide its time zone.
+	GPSIssueSwapped GPSIssue = "coordinates swapped"
+
+	// GPSIssueImpossibleAltitude is an altitude no camera is expected to reach
+	GPSIssueImpossibleAltitude GPSIssue = "impossible altitude"
+
+	// GPSIssueTimestampMismatch is a GPS timestamp far away from the capture time,
+	// either a stale fix or a camera clock set wrong
+	GPSIssueTimestampMismatch GPSIssue = "timestamp mismatch"
+)
+
+const (
+	// nullIslandTolerance is the distance, in degrees, to 0,0 a position is
+	// considered to be at Null Island within.
+	nullIslandTolerance = 0.0001
+
+	// minGPSAltitude is a little below the shore of the Dead Sea, the lowest
+	// dry land, and maxGPSAltitude above the flight ceiling of balloons.
+	minGPSAltitude = -500.0
+	maxGPSAltitude = 50_000.0
+
+	// maxGPSTimestampDrift is the largest difference between the GPS timestamp
+	// and the capture time considered sound. Capture times without an offset are
+	// given 14 more hours, the largest offset from UTC.
+	maxGPSTimestampDrift = 24 * time.Hour
+)
+
+// HasIssue checks if the fix was flagged with an issue.
+func (g *GPSData) HasIssue(issue GPSIssue) bool {
+	return slices.Contains(g.Issues, issue)
+}
+
+// Valid checks if the fix can be trusted to locate the image and to decide
+// the time zone of its capture time. An impossible altitude alone does not
+// invalidate the position.
+func (g *GPSData) Valid() bool {
+	return g.HasPosition && g.validPosition() && !g.HasIssue(GPSIssueSwapped) && !g.HasIssue(GPSIssueTimestampMismatch)
+}
+
+// validPosition checks if the position was not ruled out by the validation:
+// at Null Island, out of range or recorded with swapped references. Positions
+// only suspected of being swapped still locate the image.
+func (g *GPSData) validPosition() bool {
+	return !g.HasIssue(GPSIssueNullIsland) && !g.HasIssue(GPSIssueOutOfRange) &&
+		(!g.HasIssue(GPSIssueSwapped) || g.swapSuspected)
+}
+
+// validateGPSData flags the issues of a fix against the capture time and time
+// offset of the image.
+func validateGPSData(gps *GPSData, imageData *ImageData) {
+	gps.Issues = nil
+	gps.swapSuspected = false
+
+	if gps.HasPosition {
+		lat, lon := gps.Latitude, gps.Longitude
+		switch {
+		case math.IsNaN(lat) || math.IsNaN(lon) || math.Abs(lon) > 180:
+			gps.Issues = append(gps.Issues, GPSIssueOutOfRange)
+		case math.Abs(lat) > 90:
+			// A longitude stored as the latitude, the reverse being in range
+			if math.Abs(lon) <= 90 {
+				gps.Issues = append(gps.Issues, GPSIssueSwapped)
+			} else {
+				gps.Issues = append(gps.Issues, GPSIssueOutOfRange)
+			}
+		case math.Abs(lat) < nullIslandTolerance && math.Abs(lon) < nullIslandTolerance:
+			gps.Issues = append(gps.Issues, GPSIssueNullIsland)
+		case isLongitudeRef(gps.latitudeRef) && isLatitudeRef(gps.longitudeRef):
+			gps.Issues = append(gps.Issues, GPSIssueSwapped)
+		case swappedByTimeOffset(lat, lon, imageData.DateTimeOriginal, imageData.TimeOffset):
+			gps.Issues = append(gps.Issues, GPSIssueSwapped)
+			gps.swapSuspected = true
+		}
+	}
+
+	if gps.HasAltitude && (gps.Altitude < minGPSAltitude || gps.Altitude > maxGPSAltitude) {
+		gps.Issues = append(gps.Issues, GPSIssueImpossibleAltitude)
+	}
+
+	if !gps.Timestamp.IsZero() && !imageData.DateTimeOriginal.IsZero() {
+		captureTime, drift := imageData.DateTimeOriginal, maxGPSTimestampDrift
+		if offset, err := parseTimeOffset(imageData.TimeOffset); err == nil && captureTime.Location() == time.UTC {
+			captureTime = captureTime.Add(-time.Duration(offset) * time.Second)
+		} else if captureTime.Location() == time.UTC {
+			drift += 14 * time.Hour
+		}
+		if absDuration(gps.Timestamp.Sub(captureTime)) > drift {
+			gps.Issues = append(gps.Issues, GPSIssueTimestampMismatch)
+		}
+	}
+}
+
+// isLatitudeRef checks if a reference is one of a latitude.
+func isLatitudeRef(ref string) bool {
+	return ref == "N" || ref == "S"
+}
+
+// isLongitudeRef checks if a reference is one of a longitude.
+func isLongitudeRef(ref string) bool {
+	return ref == "E" || ref == "W"
+}
+
+// swappedByTimeOffset checks if the time offset recorded by the camera
+// contradicts the time zone of the position but matches the time zone of the
+// position with its coordinates swapped.
+func swappedByTimeOffset(latitude, longitude float64, captureTime time.Time, timeOffset string) bool {
+	if tzFinder == nil || captureTime.IsZero() || math.Abs(longitude) > 90 {
+		return false
+	}
+	offset, err := parseTimeOffset(timeOffset)
+	if err != nil {
+		return false
+	}
+
+	zoneOffset := func(latitude, longitude float64) (int, bool) {
+		name := tzFinder.GetTimezoneName(longitude, latitude)
+		if name == "" {
+			return 0, false
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return 0, false
+		}
+		t := captureTime
+		_, zoneOffset := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc).Zone()
+		return zoneOffset, true
+	}
+
+	recorded, ok := zoneOffset(latitude, longitude)
+	if !ok || recorded == offset {
+		return false
+	}
+	swapped, ok := zoneOffset(longitude, latitude)
+	return ok && swapped == offset
+}
+
+// GPSIssues returns the reasons the GPS fix of the image is suspicious, if any.
+func (i *ImageInfo) GPSIssues() []GPSIssue {
+	if i.ImageData.GPS == nil {
+		return nil
+	}
+	return i.ImageData.GPS.Issues
+}
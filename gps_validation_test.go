@@ -0,0 +1,168 @@
+package media_image
+
+import (
+	"testing"
+	"time"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GPSValidation(t *testing.T) {
+	t.Log("Testing GPS validation")
+
+	capture := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		gps       GPSData
+		imageData ImageData
+		issues    []GPSIssue
+	}{
+		{
+			name:      "sound",
+			gps:       GPSData{Latitude: 48.8566, Longitude: 2.3522, HasPosition: true, Altitude: 35, HasAltitude: true, Timestamp: capture.Add(-time.Hour), latitudeRef: "N", longitudeRef: "E"},
+			imageData: ImageData{DateTimeOriginal: capture, TimeOffset: "+01:00"},
+		},
+		{
+			name:   "null island",
+			gps:    GPSData{HasPosition: true},
+			issues: []GPSIssue{GPSIssueNullIsland},
+		},
+		{
+			name:   "out of range",
+			gps:    GPSData{Latitude: 120, Longitude: 150, HasPosition: true},
+			issues: []GPSIssue{GPSIssueOutOfRange},
+		},
+		{
+			name:   "latitude out of range",
+			gps:    GPSData{Latitude: 151.2093, Longitude: -33.8688, HasPosition: true},
+			issues: []GPSIssue{GPSIssueSwapped},
+		},
+		{
+			name:   "swapped references",
+			gps:    GPSData{Latitude: 2.3522, Longitude: 48.8566, HasPosition: true, latitudeRef: "E", longitudeRef: "N"},
+			issues: []GPSIssue{GPSIssueSwapped},
+		},
+		{
+			name:      "swapped by time offset",
+			gps:       GPSData{Latitude: 2.3522, Longitude: 48.8566, HasPosition: true, latitudeRef: "N", longitudeRef: "E"},
+			imageData: ImageData{DateTimeOriginal: capture, TimeOffset: "+01:00"},
+			issues:    []GPSIssue{GPSIssueSwapped},
+		},
+		{
+			name:   "impossible altitude",
+			gps:    GPSData{Latitude: 48.8566, Longitude: 2.3522, HasPosition: true, Altitude: -4000, HasAltitude: true},
+			issues: []GPSIssue{GPSIssueImpossibleAltitude},
+		},
+		{
+			name:      "timestamp years away",
+			gps:       GPSData{Latitude: 48.8566, Longitude: 2.3522, HasPosition: true, Timestamp: capture.AddDate(-3, 0, 0)},
+			imageData: ImageData{DateTimeOriginal: capture},
+			issues:    []GPSIssue{GPSIssueTimestampMismatch},
+		},
+		{
+			name:      "timestamp within unknown offset",
+			gps:       GPSData{Latitude: 48.8566, Longitude: 2.3522, HasPosition: true, Timestamp: capture.Add(-30 * time.Hour)},
+			imageData: ImageData{DateTimeOriginal: capture},
+		},
+		{
+			name:      "timestamp beyond known offset",
+			gps:       GPSData{Latitude: 48.8566, Longitude: 2.3522, HasPosition: true, Timestamp: capture.Add(-30 * time.Hour)},
+			imageData: ImageData{DateTimeOriginal: capture, TimeOffset: "+01:00"},
+			issues:    []GPSIssue{GPSIssueTimestampMismatch},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validateGPSData(&tt.gps, &tt.imageData)
+			assert.Equal(t, tt.issues, tt.gps.Issues)
+			// An impossible altitude does not invalidate the position
+			valid := len(tt.issues) == 0 || tt.issues[0] == GPSIssueImpossibleAltitude
+			assert.Equal(t, valid, tt.gps.Valid())
+		})
+	}
+
+	t.Run("suspected swap", func(t *testing.T) {
+		// Swapped references rule the position out, a time offset matching
+		// the swapped position only casts doubt on it
+		byRefs := GPSData{Latitude: 2.3522, Longitude: 48.8566, HasPosition: true, latitudeRef: "E", longitudeRef: "N"}
+		validateGPSData(&byRefs, &ImageData{})
+		assert.False(t, byRefs.validPosition())
+
+		byOffset := GPSData{Latitude: 2.3522, Longitude: 48.8566, HasPosition: true, latitudeRef: "N", longitudeRef: "E"}
+		validateGPSData(&byOffset, &ImageData{DateTimeOriginal: capture, TimeOffset: "+01:00"})
+		assert.True(t, byOffset.validPosition())
+		assert.False(t, byOffset.Valid())
+	})
+
+	t.Run("camera on another zone", func(t *testing.T) {
+		// A camera in Italy left on the time of Djibouti, where the swapped
+		// position lies
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		edit := metadataEdit{exif: func(editor *exifEditor) error {
+			return editor.set(ifdPathExif, "OffsetTimeOriginal", "+03:00")
+		}}
+		if err := NewExifWriter().update(path, ImageJpeg, edit); err != nil {
+			t.Fatal(err)
+		}
+
+		i := loadImage(t, path)
+		assert.Contains(t, i.GPSIssues(), GPSIssueSwapped)
+		assert.InDelta(t, 43.467157, i.ImageData.GPSLatitude, 1e-6)
+		assert.Equal(t, "IT", i.ImageData.GPSCountryCode)
+		assert.Empty(t, i.ImageData.GPSTimeZone)
+		assert.Equal(t, "+03:00", i.ImageData.TimeOffset)
+
+		// The image is located, hence not geotagged again
+		geotagger, _ := NewGeotagger(Geotagging{}, &Track{Points: []TrackPoint{{Time: i.ImageData.GPSTimestamp, Latitude: 48, Longitude: 2}}})
+		assert.Empty(t, geotagger.Preview([]*ImageInfo{i}))
+	})
+
+	t.Run("null island image", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		zero := []exifcommon.Rational{{Numerator: 0, Denominator: 1}, {Numerator: 0, Denominator: 1}, {Numerator: 0, Denominator: 1}}
+		edit := metadataEdit{exif: func(editor *exifEditor) error {
+			if err := editor.set(ifdPathGps, "GPSLatitude", zero); err != nil {
+				return err
+			}
+			return editor.set(ifdPathGps, "GPSLongitude", zero)
+		}}
+		if err := NewExifWriter().update(path, ImageJpeg, edit); err != nil {
+			t.Fatal(err)
+		}
+
+		i := loadImage(t, path)
+		assert.Equal(t, []GPSIssue{GPSIssueNullIsland}, i.GPSIssues())
+		assert.Zero(t, i.ImageData.GPSLatitude)
+		assert.Empty(t, i.ImageData.GPSTimeZone)
+		assert.Empty(t, i.ImageData.GPSCountryCode)
+		assert.Equal(t, time.UTC, i.ImageData.DateTimeOriginal.Location())
+	})
+
+	t.Run("stale fix image", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		edit := metadataEdit{exif: func(editor *exifEditor) error {
+			return editor.set(ifdPathGps, "GPSDateStamp", "2003:10:23")
+		}}
+		if err := NewExifWriter().update(path, ImageJpeg, edit); err != nil {
+			t.Fatal(err)
+		}
+
+		i := loadImage(t, path)
+		assert.Equal(t, []GPSIssue{GPSIssueTimestampMismatch}, i.GPSIssues())
+		assert.InDelta(t, 43.467157, i.ImageData.GPSLatitude, 1e-6)
+		assert.Equal(t, "Europe/Rome", i.ImageData.GPSTimeZone)
+
+		// The capture time keeps the wall clock of the camera
+		assert.Equal(t, time.UTC, i.ImageData.DateTimeOriginal.Location())
+		assert.Empty(t, i.ImageData.TimeOffset)
+	})
+
+	t.Run("sound image", func(t *testing.T) {
+		i := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+		assert.Empty(t, i.GPSIssues())
+		assert.True(t, i.ImageData.GPS.Valid())
+		assert.Nil(t, loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg").GPSIssues())
+	})
+}
@@ -4,23 +4,33 @@ import "time"
 
 type ImageData struct {
 	// GPS information extracted from the EXIF data
+	GPS                  *GPSData  // Typed GPS IFD content, nil without GPS IFD
 	GPSLatitude          float64   `exif:"GPSLatitude"`
 	GPSLongitude         float64   `exif:"GPSLongitude"`
-	GPSAltitude          float64   `exif:"GPSAltitude"`
+	GPSAltitude          float64   `exif:"GPSAltitude"` // Meters, negative below sea level
 	GPSTimeZone          string    // Determined from coordinates
+	GPSCountryCode       string    // Determined from coordinates, ISO 3166-1 alpha-2
+	GPSCountryName       string    // Determined from coordinates
+	GPSRegion            string    // Determined from coordinates
+	GPSCity              string    // Nearest city, determined from coordinates
+	GPSCityDistance      float64   // Distance to the nearest city, in meters
+	GPSGeohash           string    // Computed from coordinates, GeohashPrecision characters
 	GPSTimestamp         time.Time `exif:"GPSDateStamp,GPSTimeStamp"`
 	GPSTimestampLocal    time.Time // Computed from GPSTimestamp and GPSTimeZone
 	GPSProcessingMethod  string    `exif:"GPSProcessingMethod"`
 	GPSStatus            string    `exif:"GPSStatus"`
 	GPSSatellites        string    `exif:"GPSSatellites"`
 	GPSHPositioningError float64   `exif:"GPSHPositioningError"`
-	GPSSpeed             float64   `exif:"GPSSpeed"`
+	GPSSpeed             float64   `exif:"GPSSpeed"` // Meters per second
 	GPSTrack             float64   `exif:"GPSTrack"`
 	GPSImgDirection      float64   `exif:"GPSImgDirection"`
 	GPSDestLatitude      float64   `exif:"GPSDestLatitude"`
 	GPSDestLongitude     float64   `exif:"GPSDestLongitude"`
 	GPSDestBearing       float64   `exif:"GPSDestBearing"`
-	GPSDestDistance      float64   `exif:"GPSDestDistance"`
+	GPSDestDistance      float64   `exif:"GPSDestDistance"` // Meters
+
+	// Drone flight information extracted from the XMP data
+	Drone *DroneData // nil when not taken by a drone
 
 	// Camera information extracted from the EXIF data
 	CameraMake        string    `exif:"Make,CameraMake"`
@@ -32,10 +42,33 @@ type ImageData struct {
 	DateTime          time.Time `exif:"DateTime,CreateDate"`
 	DateTimeOriginal  time.Time `exif:"DateTimeOriginal,OriginalDateTime"`
 	DateTimeDigitized time.Time `exif:"DateTimeDigitized,DigitizedDateTime"`
-	TimeOffset        string    `exif:"OffsetTime,OffsetTimeOriginal,OffsetTimeDigitized"` // Format: "+0200" or "-0700"
+	TimeOffset        string    `exif:"OffsetTime,OffsetTimeOriginal,OffsetTimeDigitized"` // Format: "+02:00" or "-07:00"
 	SubSecOriginal    string    `exif:"SubSecTimeOriginal,SubSecTime"`                     // Subsecond precision
 	HasTimeOffset     bool      // Indicates if time offset was found
 
+	// Exposure values read from the raw EXIF values, the string fields above
+	// and below holding their formatted form for display, which is read-only
+	ExposureTime             Rational `exif:"ExposureTime"`      // Seconds
+	ExposureBias             Rational `exif:"ExposureBiasValue"` // EV, negative for underexposure
+	ShutterSpeedTime         float64  // Seconds, converted from the APEX ShutterSpeedValue
+	FNumber                  float64  `exif:"FNumber"`
+	ApertureFNumber          float64  // Converted from the APEX ApertureValue
+	MaxApertureFNumber       float64  // Converted from the APEX MaxApertureValue
+	FocalLength              float64  `exif:"FocalLength"`           // Millimeters
+	FocalPlaneXResolution    float64  `exif:"FocalPlaneXResolution"` // Pixels per FocalPlaneResolutionUnit
+	FocalPlaneYResolution    float64  `exif:"FocalPlaneYResolution"`
+	FocalPlaneResolutionUnit int      `exif:"FocalPlaneResolutionUnit"` // 2 inch, 3 cm, 4 mm, 5 µm
+
+	// Photographic values computed from the exposure and lens values, zero
+	// when unknown
+	EV100                 float64 // Exposure value at ISO 100
+	HasEV100              bool    // Indicates if EV100 was computed, 0 being a valid value
+	CropFactor            float64 // From the sensor size, the camera database or the 35 mm equivalent
+	FocalLength35mm       float64 // Millimeters, as stored or computed from the crop factor
+	AngleOfViewHorizontal float64 // Degrees, along the width of the image as stored
+	AngleOfViewVertical   float64 // Degrees
+	AngleOfViewDiagonal   float64 // Degrees
+
 	// Lens information extracted from the EXIF data
 	LensMake            string `exif:"LensMake"`
 	LensModel           string `exif:"LensModel,Lens"`
@@ -60,11 +93,29 @@ type ImageData struct {
 	Artist           string  `exif:"Artist,Creator"`
 	Copyright        string  `exif:"Copyright,CopyrightNotice"`
 	Description      string  `exif:"ImageDescription,Description"`
-	WhiteBalance     string  `exif:"WhiteBalance"`
-	Flash            string  `exif:"Flash,FlashFired"`
-	MeteringMode     string  `exif:"MeteringMode"`
-	ExposureProgram  string  `exif:"ExposureProgram"`
-	SceneCaptureType string  `exif:"SceneCaptureType"`
 	SubjectDistance  float64 `exif:"SubjectDistance"`
 	DigitalZoomRatio float64 `exif:"DigitalZoomRatio"`
+
+	// Enumerated EXIF values, decoded from their raw values
+	WhiteBalance     WhiteBalance     `exif:"WhiteBalance"`
+	Flash            Flash            `exif:"Flash,FlashFired"`
+	MeteringMode     MeteringMode     `exif:"MeteringMode"`
+	ExposureProgram  ExposureProgram  `exif:"ExposureProgram"`
+	SceneCaptureType SceneCaptureType `exif:"SceneCaptureType"`
+	LightSource      LightSource      `exif:"LightSource"`
+	SensingMethod    SensingMethod    `exif:"SensingMethod"`
+	CustomRendered   CustomRendered   `exif:"CustomRendered"`
+	SceneType        SceneType        // Read-only, an UNDEFINED byte
+
+	// Indicate if the enumerated values were found, their zero values being
+	// defined, e.g. a flash that did not fire
+	HasWhiteBalance     bool
+	HasFlash            bool
+	HasMeteringMode     bool
+	HasExposureProgram  bool
+	HasSceneCaptureType bool
+	HasLightSource      bool
+	HasSensingMethod    bool
+	HasCustomRendered   bool
+	HasSceneType        bool
 }
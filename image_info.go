@@ -2,11 +2,15 @@ package media_image
 
 import (
 	"errors"
+	"fmt"
 	"image"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
-	"github.com/dsoprea/go-exif/v3"
 	"github.com/smartmediafiles/media.fs/fs"
 	"github.com/smartmediafiles/media/media/types"
 
@@ -29,10 +33,23 @@ type ImageInfo struct {
 
 	// Image information
 	ImageData ImageData
+
+	// Provenance tells where the populated ImageData fields come from, by
+	// field name, as read or written by the methods of ImageInfo
+	Provenance map[string]FieldProvenance
+
+	// Warnings lists the non-fatal problems met while reading the image
+	Warnings []Warning
+
+	options        Options
+	fileData       ImageData                  // Image data of NewImageInfo, completed by Exif
+	fileProvenance map[string]FieldProvenance // Provenance of fileData
+	fileWarnings   []Warning                  // Warnings of NewImageInfo, kept by Exif
 }
 
-// NewImageInfo creates a new ImageInfo struct.
-func NewImageInfo(path string) (*ImageInfo, error) {
+// NewImageInfo creates a new ImageInfo struct, reading the image with
+// DefaultLimits unless the options set others.
+func NewImageInfo(path string, opts ...Option) (*ImageInfo, error) {
 	// Retrieve file information
 	fileInfo, err := fs.NewFileInfo(path)
 	if err != nil {
@@ -47,39 +64,186 @@ func NewImageInfo(path string) (*ImageInfo, error) {
 	i.FileInfo = fileInfo
 	i.FileType = fileType
 	i.FileExt = fileExt
+	i.options = Options{Limits: DefaultLimits}
+	for _, opt := range opts {
+		opt(&i.options)
+	}
 
-	// extract minimal information from the image file
-	_ = i.extractData()
+	// extract minimal information from the image file, which the decoders
+	// of the standard library cannot do for every type
+	if err := i.extractData(); err != nil {
+		i.warn("", fmt.Errorf("failed to decode image: %w", err))
+	}
+	i.fileData, i.fileProvenance = i.ImageData, maps.Clone(i.Provenance)
+	i.fileWarnings = slices.Clone(i.Warnings)
 
 	return i, nil
 }
 
-// Exif extracts the image information from the exif data.
+// SetLogger sets the logger the warnings of Exif are logged to as they are
+// met, nil to only collect them.
+func (i *ImageInfo) SetLogger(logger *slog.Logger) {
+	i.options.Logger = logger
+}
+
+// SetLimits sets the limits Exif checks the file and its metadata against.
+func (i *ImageInfo) SetLimits(limits Limits) {
+	i.options.Limits = limits
+}
+
+// Exif extracts the image information from the metadata sources of the
+// options, completing the information of NewImageInfo when the file has no
+// EXIF data.
 func (i *ImageInfo) Exif() (*ImageInfo, error) {
 
+	i.Warnings = slices.Clone(i.fileWarnings)
+	imageData, provenance := i.fileData, maps.Clone(i.fileProvenance)
+
 	// Parse the file to extract exif data
-	exifParser := NewExifParser()
-	rawExif, err := exifParser.Parse(filepath.Join(i.FileInfo.Abs(), i.FileInfo.Name()), i.FileType)
-	if errors.Is(err, exif.ErrNoExif) {
-		return i, nil
+	if i.options.reads(SourceExif) {
+		exifData, exifProvenance, err := i.parseExif()
+		switch {
+		case errors.Is(err, ErrNoExif):
+			i.warn("", err)
+		case err != nil:
+			return i, err
+		default:
+			imageData, provenance = exifData, exifProvenance
+
+			// Keep the dimensions read by the decoder when EXIF records none
+			if imageData.ImageWidth == 0 && imageData.ImageHeight == 0 {
+				imageData.ImageWidth, imageData.ImageHeight = i.fileData.ImageWidth, i.fileData.ImageHeight
+				for _, name := range []string{"ImageWidth", "ImageHeight"} {
+					if p, ok := i.fileProvenance[name]; ok {
+						provenance[name] = p
+					}
+				}
+			}
+		}
 	}
-	if err != nil {
-		return i, err
+	if provenance == nil {
+		provenance = make(map[string]FieldProvenance)
+	}
+	if i.options.FileTimeFallback == FileTimeWithoutDate && imageData.DateTime.IsZero() &&
+		imageData.DateTimeOriginal.IsZero() && imageData.DateTimeDigitized.IsZero() {
+		i.setFileTime(&imageData, provenance)
 	}
 
-	// Parse the exif data to extract image data
-	exifDataParser := NewExifDataParser()
-	imageData, err := exifDataParser.Parse(rawExif)
-	if err != nil {
-		return i, err
+	// Drones record their flight information in XMP, and editors the
+	// captions and credits in IPTC
+	if i.options.reads(SourceXmp) || i.options.reads(SourceIptc) {
+		if metadata, err := readContainerMetadata(i.path(), i.FileType, i.options.Limits); err != nil {
+			i.warn("", err)
+		} else {
+			if i.options.reads(SourceXmp) && len(metadata.xmp) > 0 {
+				i.setDroneData(&imageData, provenance, metadata.xmp[0], SourceXmp)
+			}
+			if i.options.reads(SourceIptc) && metadata.jpegHeader != nil {
+				if tags, err := parseIptc(metadata.jpegHeader); err != nil {
+					i.warn("", err)
+				} else if tags != nil {
+					setIptcFields(&imageData, provenance, tags)
+				}
+			}
+		}
 	}
+	if imageData.Drone == nil && i.options.reads(SourceSidecar) {
+		if data, err := i.readSidecar(); err != nil {
+			i.warn("Drone", err)
+		} else if data != nil {
+			i.setDroneData(&imageData, provenance, data, SourceSidecar)
+		}
+	}
+
+	// Compute the photographic values once the dimensions are known
+	setPhotographicValues(&imageData)
+	completeProvenance(provenance, imageData, imageData)
 
 	// Assign values
 	i.ImageData = imageData
+	i.Provenance = provenance
 
 	return i, nil
 }
 
+// parseExif parses the EXIF data of the file with the options.
+func (i *ImageInfo) parseExif() (ImageData, map[string]FieldProvenance, error) {
+	exifParser := NewExifParser()
+	exifParser.SetLimits(i.options.Limits)
+	rawExif, err := exifParser.Parse(i.path(), i.FileType)
+	if err != nil {
+		return ImageData{}, nil, err
+	}
+
+	// Parse the exif data to extract image data
+	exifDataParser := i.exifDataParser()
+	if i.FileType == ImageTiff {
+		// The EXIF data of TIFF files is the file itself, of a checked size
+		exifDataParser.limits.MaxExifSize = 0
+	}
+	imageData, provenance, err := exifDataParser.ParseWithProvenance(rawExif)
+	i.Warnings = append(i.Warnings, exifDataParser.Warnings()...)
+	return imageData, provenance, err
+}
+
+// exifDataParser creates an ExifDataParser set with the options of the image.
+func (i *ImageInfo) exifDataParser() *ExifDataParser {
+	exifDataParser := NewExifDataParser()
+	exifDataParser.SetLogger(i.options.Logger)
+	exifDataParser.SetLimits(i.options.Limits)
+	exifDataParser.SetOptions(i.options)
+	return exifDataParser
+}
+
+// readFile reads the whole file, within the limits.
+func (i *ImageInfo) readFile() ([]byte, error) {
+	if err := i.options.Limits.checkFileSize(i.path()); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(i.path())
+}
+
+// readSidecar reads the XMP sidecar file of the image, named after the image
+// with or without its extension, nil when there is none.
+func (i *ImageInfo) readSidecar() ([]byte, error) {
+	path := i.path()
+	for _, sidecar := range []string{strings.TrimSuffix(path, filepath.Ext(path)) + ".xmp", path + ".xmp"} {
+		info, err := os.Stat(sidecar)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if err := checkLimit("XMP size", info.Size(), int64(i.options.Limits.MaxXmpSize)); err != nil {
+			return nil, err
+		}
+		return os.ReadFile(sidecar)
+	}
+	return nil, nil
+}
+
+// setDroneData sets the drone flight information of the XMP packet of data,
+// read from source.
+func (i *ImageInfo) setDroneData(imageData *ImageData, provenance map[string]FieldProvenance, data []byte, source FieldSource) {
+	packet := extractXmpPacket(data)
+	if err := checkLimit("XMP size", int64(len(packet)), int64(i.options.Limits.MaxXmpSize)); err != nil {
+		i.warn("Drone", err)
+		return
+	}
+	if packet == nil {
+		return
+	}
+	if drone := parseDroneData(packet); drone != nil {
+		imageData.Drone = drone
+		provenance["Drone"] = FieldProvenance{Source: source, Tag: drone.Namespace}
+	}
+}
+
+// warn records a warning, logging it.
+func (i *ImageInfo) warn(field string, err error) {
+	w := warnings{logger: i.options.Logger, list: i.Warnings}
+	w.warn(field, err)
+	i.Warnings = w.list
+}
+
 // IsPhoto checks if the image is a photo.
 func (i *ImageInfo) IsPhoto() bool {
 	return IsPhoto(i.FileType)
@@ -90,30 +254,77 @@ func (i *ImageInfo) IsImage() bool {
 	return IsImage(i.FileType)
 }
 
+// path returns the full path of the image file.
+func (i *ImageInfo) path() string {
+	return filepath.Join(i.FileInfo.Abs(), i.FileInfo.Name())
+}
+
 // extractData extracts minimal information from the image file.
 func (i *ImageInfo) extractData() error {
-	file, err := os.Open(filepath.Join(i.FileInfo.Abs(), i.FileInfo.Name()))
+	i.Provenance = make(map[string]FieldProvenance)
+
+	// Use file date as image date, unless left to the metadata
+	if i.options.FileTimeFallback != FileTimeNever {
+		i.setFileTime(&i.ImageData, i.Provenance)
+	}
+	if i.options.SkipDecodeConfig {
+		return nil
+	}
+
+	if err := checkLimit("file size", i.FileInfo.Size(), i.options.Limits.MaxFileSize); err != nil {
+		return err
+	}
+
+	file, err := os.Open(i.path())
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	// Decode the image to get its dimensions
+	// Decode the image to get its dimensions, checked as a crafted header
+	// can claim any size
 	img, _, err := image.DecodeConfig(file)
 	if err != nil {
 		return err
 	}
+	if err := checkLimit("pixel count", int64(img.Width)*int64(img.Height), i.options.Limits.MaxPixels); err != nil {
+		return err
+	}
+	if err := i.options.Limits.checkFrames(i.path(), i.FileType); err != nil {
+		return err
+	}
 
 	// Assign dimensions to ImageData
 	i.ImageData.ImageWidth = img.Width
 	i.ImageData.ImageHeight = img.Height
+	i.Provenance["ImageWidth"] = FieldProvenance{Source: SourceDecoder}
+	i.Provenance["ImageHeight"] = FieldProvenance{Source: SourceDecoder}
 
-	// Use file date as image date
+	return nil
+}
+
+// setFileTime sets the time of the file, its creation time or else its last
+// write time, as the date of the image.
+func (i *ImageInfo) setFileTime(imageData *ImageData, provenance map[string]FieldProvenance) {
 	if i.FileInfo.CreationTime().IsZero() {
-		i.ImageData.DateTime = i.FileInfo.LastWriteTime()
+		imageData.DateTime = i.FileInfo.LastWriteTime()
 	} else {
-		i.ImageData.DateTime = i.FileInfo.CreationTime()
+		imageData.DateTime = i.FileInfo.CreationTime()
+	}
+	provenance["DateTime"] = FieldProvenance{Source: SourceFileSystem}
+}
+
+// WriteExif writes the fields of updated that differ from the current image
+// data into the EXIF data of the file, then reads the image data back from
+// the file.
+func (i *ImageInfo) WriteExif(updated ImageData) error {
+	changes := DiffImageData(i.ImageData, updated)
+	if err := NewExifWriter().Write(i.path(), i.FileType, changes); err != nil {
+		return err
 	}
 
-	return nil
+	// Refresh the image data from the written file, the typed GPS data, the
+	// place, the geohash and the time zone being derived from its tags
+	_, err := i.Exif()
+	return err
 }
@@ -125,6 +125,10 @@ func Test_JpegImages(t *testing.T) {
 		assert.Equal(t, i.FileType, ImageJpeg)
 		assert.Equal(t, i.FileExt, ExtensionJpg)
 		assert.Equal(t, i.ImageData.GPSTimeZone, "Europe/Rome")
+
+		// The offset derived from the time zone has the format of the EXIF tags
+		assert.True(t, i.ImageData.HasTimeOffset)
+		assert.Regexp(t, `^\+0[12]:00$`, i.ImageData.TimeOffset)
 	})
 }
 
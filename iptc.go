@@ -0,0 +1,106 @@
+package media_image
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dsoprea/go-iptc"
+	jpegstructure "github.com/dsoprea/go-jpeg-image-structure/v2"
+)
+
+// iptcRecordApplication is the IPTC record of the editorial datasets.
+const iptcRecordApplication = 2
+
+// Datasets of the application record.
+const (
+	iptcDatasetDateCreated     = 55
+	iptcDatasetTimeCreated     = 60
+	iptcDatasetByline          = 80
+	iptcDatasetCopyright       = 116
+	iptcDatasetCaptionAbstract = 120
+)
+
+// iptcTextFields are the ImageData fields read from the text datasets, with
+// the name of their dataset.
+var iptcTextFields = []struct {
+	field   string
+	dataset uint8
+	name    string
+	value   func(*ImageData) *string
+}{
+	{"Artist", iptcDatasetByline, "By-line", func(d *ImageData) *string { return &d.Artist }},
+	{"Copyright", iptcDatasetCopyright, "CopyrightNotice", func(d *ImageData) *string { return &d.Copyright }},
+	{"Description", iptcDatasetCaptionAbstract, "Caption-Abstract", func(d *ImageData) *string { return &d.Description }},
+}
+
+// parseIptc reads the IPTC datasets of a JPEG file, nil when it has none.
+func parseIptc(data []byte) (tags map[iptc.StreamTagKey][]iptc.TagData, err error) {
+	defer recoverPanic("iptc", &err)
+
+	mediaContext, err := jpegstructure.NewJpegMediaParser().ParseBytes(data)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	segments := mediaContext.(*jpegstructure.SegmentList)
+
+	_, segment, err := segments.FindIptc()
+	if errors.Is(err, jpegstructure.ErrNoIptc) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find IPTC data: %w", classifyError(err))
+	}
+	if tags, err = segment.Iptc(); err != nil {
+		return nil, fmt.Errorf("failed to read IPTC data: %w", classifyError(err))
+	}
+	return tags, nil
+}
+
+// iptcText returns the first value of a text dataset of the application
+// record.
+func iptcText(tags map[iptc.StreamTagKey][]iptc.TagData, dataset uint8) (string, bool) {
+	values := tags[iptc.StreamTagKey{RecordNumber: iptcRecordApplication, DatasetNumber: dataset}]
+	if len(values) == 0 || !values[0].IsPrintable() {
+		return "", false
+	}
+	value := strings.TrimSpace(strings.TrimRight(string(values[0]), "\x00"))
+	return value, value != ""
+}
+
+// iptcDateCreated returns the date the image was created, in the time zone
+// of its TimeCreated dataset, UTC when it is missing.
+func iptcDateCreated(tags map[iptc.StreamTagKey][]iptc.TagData) (time.Time, bool) {
+	date, ok := iptcText(tags, iptcDatasetDateCreated)
+	if !ok {
+		return time.Time{}, false
+	}
+	if clock, ok := iptcText(tags, iptcDatasetTimeCreated); ok {
+		for _, layout := range []string{"20060102150405-0700", "20060102150405"} {
+			if t, err := time.Parse(layout, date+clock); err == nil {
+				return t, true
+			}
+		}
+	}
+	t, err := time.Parse("20060102", date)
+	return t, err == nil
+}
+
+// setIptcFields completes the empty fields of the image data with the IPTC
+// datasets, recording their provenance.
+func setIptcFields(imageData *ImageData, provenance map[string]FieldProvenance, tags map[iptc.StreamTagKey][]iptc.TagData) {
+	for _, f := range iptcTextFields {
+		if value, ok := iptcText(tags, f.dataset); ok && *f.value(imageData) == "" {
+			*f.value(imageData) = value
+			provenance[f.field] = FieldProvenance{Source: SourceIptc, Tag: f.name}
+		}
+	}
+
+	if imageData.DateTimeOriginal.IsZero() {
+		if t, ok := iptcDateCreated(tags); ok {
+			imageData.DateTimeOriginal = t
+			provenance["DateTimeOriginal"] = FieldProvenance{Source: SourceIptc, Tag: "DateCreated"}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package media_image
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dsoprea/go-exif/v3"
+	"github.com/smartmediafiles/media/media/types"
+)
+
+// Limits bounds the resources spent on reading an image, against crafted
+// files such as decompression bombs. A zero field disables its limit.
+// Exceeded limits are reported with errors matching ErrLimitExceeded.
+type Limits struct {
+	MaxPixels   int64 // Width × height of the image
+	MaxFileSize int64 // Size of the file, in bytes, the metadata libraries reading whole files
+	MaxExifSize int   // Size of the raw EXIF data, in bytes, not enforced on TIFF files whose EXIF data is the file
+	MaxXmpSize  int   // Size of the XMP packet, in bytes
+	MaxIfdCount int   // Number of IFDs of the EXIF data, thumbnail and TIFF pages included
+	MaxIfdDepth int   // Nesting depth of the IFDs, 1 for IFD0, 2 for its Exif and GPS IFDs
+	MaxFrames   int   // Number of frames of animated GIF and WebP images
+}
+
+// DefaultLimits are the limits of the parsers, generous enough for the
+// images of any camera.
+var DefaultLimits = Limits{
+	MaxPixels:   1 << 28, // 16384 × 16384
+	MaxFileSize: 1 << 30, // 1 GiB
+	MaxExifSize: 16 << 20,
+	MaxXmpSize:  16 << 20,
+	MaxIfdCount: 256,
+	MaxIfdDepth: 8,
+	MaxFrames:   10000,
+}
+
+// checkLimit returns an error matching ErrLimitExceeded when value exceeds
+// limit, a limit of 0 being no limit.
+func checkLimit(name string, value, limit int64) error {
+	if limit > 0 && value > limit {
+		return fmt.Errorf("%w: %s of %d, the maximum is %d", ErrLimitExceeded, name, value, limit)
+	}
+	return nil
+}
+
+// checkFileSize checks the size of a file against the limit.
+func (l Limits) checkFileSize(path string) error {
+	if l.MaxFileSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return checkLimit("file size", info.Size(), l.MaxFileSize)
+}
+
+// ifdLimiter checks the IFDs met while enumerating EXIF data against the
+// limits.
+type ifdLimiter struct {
+	limits Limits
+	paths  map[string]struct{}
+	err    error // Limit exceeded, kept as the library wraps the errors of the visitors
+}
+
+// newIfdLimiter creates an ifdLimiter enforcing the limits.
+func newIfdLimiter(limits Limits) *ifdLimiter {
+	return &ifdLimiter{limits: limits, paths: make(map[string]struct{})}
+}
+
+// visit checks the IFD of a tag entry, stopping the enumeration with an
+// error once a limit is exceeded.
+func (l *ifdLimiter) visit(ite *exif.IfdTagEntry) error {
+	path := ite.IfdPath()
+	if _, ok := l.paths[path]; ok {
+		return nil
+	}
+	l.paths[path] = struct{}{}
+
+	l.err = checkLimit("IFD count", int64(len(l.paths)), int64(l.limits.MaxIfdCount))
+	if l.err == nil {
+		l.err = checkLimit("IFD depth", int64(strings.Count(path, "/")+1), int64(l.limits.MaxIfdDepth))
+	}
+	return l.err
+}
+
+// checkFrames checks the number of frames of an animated image against the
+// limit. Files it cannot walk through are left to the decoders.
+func (l Limits) checkFrames(path string, fileType types.FileType) error {
+	if l.MaxFrames <= 0 || (fileType != ImageGif && fileType != ImageWebp) {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var frames int
+	if fileType == ImageGif {
+		frames = countGifFrames(file, l.MaxFrames+1)
+	} else {
+		frames = countWebpFrames(file)
+	}
+	return checkLimit("frame count", int64(frames), int64(l.MaxFrames))
+}
+
+// countGifFrames counts the images of a GIF file, up to limit. It stops at
+// the first malformed block.
+func countGifFrames(r io.Reader, limit int) int {
+	g, err := newGifReader(r)
+	if err != nil {
+		return 0
+	}
+
+	frames := 0
+	for frames < limit {
+		introducer, _, err := g.next()
+		if err != nil {
+			return frames
+		}
+		switch introducer {
+		case gifTrailer:
+			return frames
+		case gifImageSeparator:
+			frames++
+			_, err = g.image(false)
+		case gifExtension:
+			_, err = g.subBlocks(false, "", 0)
+		}
+		if err != nil {
+			return frames
+		}
+	}
+	return frames
+}
+
+// countWebpFrames counts the animation frames of a WebP file, 1 for a still
+// image. It stops at the first malformed chunk.
+func countWebpFrames(r io.ReadSeeker) int {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:]) != "WEBP" {
+		return 0
+	}
+
+	frames := 0
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			break
+		}
+		if string(chunk[:4]) == webpChunkAnmf {
+			frames++
+		}
+		// Chunks are padded to an even size
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil {
+			break
+		}
+	}
+	return max(frames, 1)
+}
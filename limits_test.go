@@ -0,0 +1,119 @@
+package media_image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Limits(t *testing.T) {
+	t.Log("Testing resource limits")
+
+	i := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+	rawExif, err := NewExifParser().Parse(i.path(), i.FileType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("pixels", func(t *testing.T) {
+		var data bytes.Buffer
+		if err := png.Encode(&data, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+			t.Fatal(err)
+		}
+
+		// Claim 60000×60000 pixels in the IHDR chunk, following the signature
+		bomb := data.Bytes()
+		binary.BigEndian.PutUint32(bomb[16:], 60000)
+		binary.BigEndian.PutUint32(bomb[20:], 60000)
+		binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+
+		i, err := NewImageInfo(writeFile(t, "bomb.png", bomb))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, i.Warnings, 1) {
+			assert.ErrorIs(t, i.Warnings[0].Err, ErrLimitExceeded)
+		}
+		assert.Zero(t, i.ImageData.ImageWidth)
+	})
+
+	t.Run("frames", func(t *testing.T) {
+		frame := image.NewPaletted(image.Rect(0, 0, 2, 2), []color.Color{color.Black, color.White})
+		var data bytes.Buffer
+		animation := &gif.GIF{Image: []*image.Paletted{frame, frame, frame}, Delay: []int{0, 0, 0}}
+		if err := gif.EncodeAll(&data, animation); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 3, countGifFrames(bytes.NewReader(data.Bytes()), 10))
+		assert.Equal(t, 2, countGifFrames(bytes.NewReader(data.Bytes()), 2))
+
+		path := writeFile(t, "animation.gif", data.Bytes())
+		assert.NoError(t, Limits{MaxFrames: 3}.checkFrames(path, ImageGif))
+		assert.ErrorIs(t, Limits{MaxFrames: 2}.checkFrames(path, ImageGif), ErrLimitExceeded)
+
+		// Still images are a single frame
+		assert.NoError(t, Limits{MaxFrames: 1}.checkFrames("samples/webp/Nærøyfjorden.webp", ImageWebp))
+		assert.ErrorIs(t, Limits{MaxFrames: 20}.checkFrames("samples/webp/giphy.webp", ImageWebp), ErrLimitExceeded)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		p := NewExifParser()
+		p.SetLimits(Limits{MaxFileSize: 1000})
+		_, err := p.Parse(i.path(), i.FileType)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		p.SetLimits(Limits{MaxExifSize: 1000})
+		_, err = p.Parse(i.path(), i.FileType)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		p.SetLimits(Limits{})
+		_, err = p.Parse(i.path(), i.FileType)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ifds", func(t *testing.T) {
+		// IFD0, IFD1, and the Exif, GPS and interoperability IFDs
+		p := NewExifDataParser()
+		p.SetLimits(Limits{MaxIfdCount: 5, MaxIfdDepth: 3})
+		_, err := p.Parse(rawExif)
+		assert.NoError(t, err)
+
+		p.SetLimits(Limits{MaxIfdCount: 2})
+		_, err = p.Parse(rawExif)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+		_, err = p.Tags(rawExif)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		p.SetLimits(Limits{MaxIfdDepth: 1})
+		_, err = p.Parse(rawExif)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+	})
+
+	t.Run("xmp", func(t *testing.T) {
+		i, err := NewImageInfo("samples/jpg/gps/gps-1.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		i.SetLimits(Limits{MaxXmpSize: 1})
+		if _, err := i.Exif(); err != nil {
+			t.Fatal(err)
+		}
+		var exceeded bool
+		for _, w := range i.Warnings {
+			exceeded = exceeded || errors.Is(w.Err, ErrLimitExceeded)
+		}
+		assert.True(t, exceeded)
+
+		i.SetLimits(Limits{MaxExifSize: 1000})
+		_, err = i.Exif()
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+	})
+}
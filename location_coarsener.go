@@ -0,0 +1,114 @@
+package media_image
+
+import (
+	"fmt"
+	"math"
+)
+
+// coarsenedGpsTags lists the GPS tags removed when coarsening a location, as
+// they would reveal the precise position or what the camera was looking at.
+var coarsenedGpsTags = []uint16{
+	gpsTagAltitudeRef, gpsTagAltitude,
+	gpsTagImgDirectionRef, gpsTagImgDirection,
+	gpsTagDestLatitudeRef, gpsTagDestLatitude,
+	gpsTagDestLongitudeRef, gpsTagDestLongitude,
+	gpsTagDestBearingRef, gpsTagDestBearing,
+	gpsTagDestDistanceRef, gpsTagDestDistance,
+	gpsTagHPositioningError,
+}
+
+// coarsenedXmpProperties lists the XMP properties mirroring the precise location.
+var coarsenedXmpProperties = []string{
+	"exif:GPS*",
+	"drone-dji:Gps*",
+}
+
+// LocationCoarsening describes how the location of an image is made approximate.
+type LocationCoarsening struct {
+	// Precision is the number of decimal places the coordinates are rounded to
+	// (1 is about 11 km, 2 about 1.1 km). It is used when GridSize is zero.
+	Precision int
+
+	// GridSize, in degrees, snaps the coordinates to the center of the grid
+	// cell containing them.
+	GridSize float64
+}
+
+// Coarsen returns the approximate coordinates of a location.
+func (c LocationCoarsening) Coarsen(latitude, longitude float64) (float64, float64) {
+	if c.GridSize > 0 {
+		latitude = (math.Floor(latitude/c.GridSize) + 0.5) * c.GridSize
+		longitude = (math.Floor(longitude/c.GridSize) + 0.5) * c.GridSize
+	} else {
+		scale := math.Pow10(c.Precision)
+		latitude = math.Round(latitude*scale) / scale
+		longitude = math.Round(longitude*scale) / scale
+	}
+
+	// Keep the coordinates in range when the grid does not divide it
+	latitude = math.Max(-90, math.Min(90, latitude))
+	if longitude >= 180 {
+		longitude -= 360
+	}
+	return latitude, longitude
+}
+
+// LocationCoarsener rewrites the GPS data of images with an approximate location.
+type LocationCoarsener struct {
+	coarsening LocationCoarsening
+	writer     *ExifWriter
+}
+
+// NewLocationCoarsener creates a new LocationCoarsener.
+func NewLocationCoarsener(coarsening LocationCoarsening) (*LocationCoarsener, error) {
+	if coarsening.GridSize < 0 || coarsening.GridSize > 180 {
+		return nil, fmt.Errorf("invalid grid size: %v", coarsening.GridSize)
+	}
+	if coarsening.GridSize == 0 && (coarsening.Precision < 0 || coarsening.Precision > 6) {
+		return nil, fmt.Errorf("invalid precision: %d", coarsening.Precision)
+	}
+	return &LocationCoarsener{coarsening: coarsening, writer: NewExifWriter()}, nil
+}
+
+// Apply replaces the coordinates of the image with approximate ones and drops
+// the GPS tags revealing the precise position. Images without a position are
+// left untouched, whereas positions flagged by the GPS validation are
+// coarsened too.
+func (c *LocationCoarsener) Apply(i *ImageInfo) error {
+	gps := i.ImageData.GPS
+	if gps == nil || !gps.HasPosition {
+		return nil
+	}
+
+	latitude, longitude := c.coarsening.Coarsen(gps.Latitude, gps.Longitude)
+	edit := metadataEdit{
+		exif: func(editor *exifEditor) error {
+			if err := setGpsCoordinate(editor, "GPSLatitude", "N", "S", latitude); err != nil {
+				return err
+			}
+			if err := setGpsCoordinate(editor, "GPSLongitude", "E", "W", longitude); err != nil {
+				return err
+			}
+			for _, tagId := range coarsenedGpsTags {
+				if err := editor.deleteId(ifdPathGps, tagId); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		xmp: func(packet []byte) ([]byte, error) {
+			for _, property := range coarsenedXmpProperties {
+				packet, _ = removeXmpProperty(packet, property)
+			}
+			return packet, nil
+		},
+	}
+	if err := c.writer.update(i.path(), i.FileType, edit); err != nil {
+		return err
+	}
+
+	// Refresh the image data from the coarsened file, for the fine-grained
+	// values and the place derived from them
+	_, err := i.Exif()
+	return err
+}
@@ -0,0 +1,114 @@
+package media_image
+
+import (
+	"testing"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LocationCoarsener(t *testing.T) {
+	t.Log("Testing location coarsener")
+
+	t.Run("coarsen", func(t *testing.T) {
+		latitude, longitude := LocationCoarsening{Precision: 2}.Coarsen(43.468216, -11.895206)
+		assert.InDelta(t, 43.47, latitude, 1e-9)
+		assert.InDelta(t, -11.90, longitude, 1e-9)
+
+		latitude, longitude = LocationCoarsening{GridSize: 0.5}.Coarsen(43.468216, -11.895206)
+		assert.InDelta(t, 43.25, latitude, 1e-9)
+		assert.InDelta(t, -11.75, longitude, 1e-9)
+
+		latitude, longitude = LocationCoarsening{GridSize: 7}.Coarsen(89.9, 179.9)
+		assert.InDelta(t, 87.5, latitude, 1e-9)
+		assert.InDelta(t, 178.5, longitude, 1e-9)
+
+		latitude, longitude = LocationCoarsening{Precision: 1}.Coarsen(-89.99, 179.99)
+		assert.InDelta(t, -90, latitude, 1e-9)
+		assert.InDelta(t, -180, longitude, 1e-9)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewLocationCoarsener(LocationCoarsening{Precision: 9})
+		assert.Error(t, err)
+		_, err = NewLocationCoarsener(LocationCoarsening{GridSize: -1})
+		assert.Error(t, err)
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		i := loadImage(t, path)
+		data := i.ImageData
+		data.GPSAltitude = 312
+		data.GPSImgDirection = 87.5
+		data.GPSDestLatitude = 43.47
+		data.GPSDestLongitude = 11.9
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		coarsener, err := NewLocationCoarsener(LocationCoarsening{Precision: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := coarsener.Apply(i); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded := loadImage(t, path)
+		assert.InDelta(t, 43.5, reloaded.ImageData.GPSLatitude, 1e-9)
+		assert.InDelta(t, 11.9, reloaded.ImageData.GPSLongitude, 1e-9)
+		assert.Zero(t, reloaded.ImageData.GPSAltitude)
+		assert.Zero(t, reloaded.ImageData.GPSImgDirection)
+		assert.Zero(t, reloaded.ImageData.GPSDestLatitude)
+		assert.Zero(t, reloaded.ImageData.GPSDestLongitude)
+		assert.Equal(t, data.GPSTimestamp, reloaded.ImageData.GPSTimestamp)
+		assert.Equal(t, i.ImageData.GPSLatitude, reloaded.ImageData.GPSLatitude)
+
+		// The image data follows the file
+		assert.Zero(t, i.ImageData.GPSAltitude)
+		assert.Zero(t, i.ImageData.GPSImgDirection)
+		assert.Equal(t, reloaded.ImageData.GPS, i.ImageData.GPS)
+		assert.Equal(t, reloaded.ImageData.GPSCity, i.ImageData.GPSCity)
+		assert.Equal(t, reloaded.ImageData.GPSGeohash, i.ImageData.GPSGeohash)
+	})
+
+	t.Run("flagged", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+
+		// References of a longitude and a latitude, in each other's tags
+		err := NewExifWriter().update(path, ImageJpeg, metadataEdit{
+			exif: func(editor *exifEditor) error {
+				if err := editor.setRaw(ifdPathGps, gpsTagLatitudeRef, exifcommon.TypeAscii, []byte("E\x00")); err != nil {
+					return err
+				}
+				return editor.setRaw(ifdPathGps, gpsTagLongitudeRef, exifcommon.TypeAscii, []byte("N\x00"))
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := loadImage(t, path)
+		assert.True(t, i.ImageData.GPS.HasIssue(GPSIssueSwapped))
+		precise := *i.ImageData.GPS
+
+		coarsener, _ := NewLocationCoarsener(LocationCoarsening{Precision: 1})
+		if err := coarsener.Apply(i); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded := loadImage(t, path)
+		assert.InDelta(t, 43.5, reloaded.ImageData.GPS.Latitude, 1e-9)
+		assert.InDelta(t, 11.9, reloaded.ImageData.GPS.Longitude, 1e-9)
+		assert.NotEqual(t, precise.Latitude, reloaded.ImageData.GPS.Latitude)
+	})
+
+	t.Run("no location", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		i := loadImage(t, path)
+
+		coarsener, _ := NewLocationCoarsener(LocationCoarsening{Precision: 1})
+		assert.NoError(t, coarsener.Apply(i))
+		assert.Zero(t, loadImage(t, path).ImageData.GPSLatitude)
+	})
+}
@@ -0,0 +1,432 @@
+package media_image
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/smartmediafiles/media/media/types"
+)
+
+// containerMetadata is the metadata of a file read from its container,
+// besides the EXIF data.
+type containerMetadata struct {
+	// xmp holds the XMP packets in the order of the file, the first being
+	// the main one, and is empty when the file has none
+	xmp [][]byte
+
+	// jpegHeader holds the segments of a JPEG file preceding its image data,
+	// ended by an EOI marker so that they parse as a file
+	jpegHeader []byte
+}
+
+// readContainerMetadata reads the XMP packets of a file from the segments,
+// chunks, extensions, tag or items holding them, seeking over the image data
+// rather than reading the whole file. The segments of JPEG files preceding
+// the image data are kept for their IPTC data.
+func readContainerMetadata(path string, fileType types.FileType, limits Limits) (metadata containerMetadata, err error) {
+	defer recoverPanic("xmp", &err)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return containerMetadata{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return containerMetadata{}, err
+	}
+	r := &containerReader{file: file, size: info.Size(), limits: limits}
+
+	switch fileType {
+	case ImageJpeg:
+		metadata.jpegHeader, metadata.xmp, err = r.readJpeg()
+	case ImagePng:
+		metadata.xmp, err = r.readPng()
+	case ImageWebp:
+		metadata.xmp, err = r.readWebp()
+	case ImageGif:
+		metadata.xmp, err = r.readGif()
+	case ImageTiff:
+		metadata.xmp, err = r.readTiff()
+	case ImageHeic, ImageHeif:
+		metadata.xmp, err = r.readHeif()
+	}
+	if err != nil {
+		return containerMetadata{}, classifyError(err)
+	}
+	return metadata, nil
+}
+
+// containerReader reads the metadata of a file at the offsets of its
+// container.
+type containerReader struct {
+	file   *os.File
+	size   int64
+	limits Limits
+}
+
+// readAt reads n bytes at the offset, n being checked against the XMP size
+// limit when named.
+func (r *containerReader) readAt(offset int64, n int64, name string) ([]byte, error) {
+	if name != "" {
+		if err := checkLimit(name, n, int64(r.limits.MaxXmpSize)); err != nil {
+			return nil, err
+		}
+	}
+	if offset < 0 || n < 0 || offset+n > r.size {
+		return nil, fmt.Errorf("%w: data out of the file at offset %d", ErrTruncated, offset)
+	}
+	data := make([]byte, n)
+	if _, err := r.file.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// headerLimit bounds the metadata preceding the image data of a JPEG file:
+// the EXIF data and the main and extended XMP packets.
+func (r *containerReader) headerLimit() int64 {
+	if r.limits.MaxExifSize == 0 || r.limits.MaxXmpSize == 0 {
+		return 0
+	}
+	return int64(r.limits.MaxExifSize) + 2*int64(r.limits.MaxXmpSize)
+}
+
+// readJpeg reads the segments of a JPEG file up to its image data, and the
+// XMP packets of its APP1 XMP segments.
+func (r *containerReader) readJpeg() ([]byte, [][]byte, error) {
+	br := bufio.NewReader(io.NewSectionReader(r.file, 0, r.size))
+
+	header := new(bytes.Buffer)
+	marker := make([]byte, 2)
+	if _, err := io.ReadFull(br, marker); err != nil {
+		return nil, nil, err
+	}
+	if marker[0] != 0xff || marker[1] != 0xd8 {
+		return nil, nil, fmt.Errorf("%w: missing JPEG SOI marker", ErrCorrupt)
+	}
+	header.Write(marker)
+
+	var xmp [][]byte
+	for {
+		if _, err := io.ReadFull(br, marker); err != nil {
+			return nil, nil, err
+		}
+		if marker[0] != 0xff {
+			return nil, nil, fmt.Errorf("%w: invalid JPEG marker 0x%02x%02x", ErrCorrupt, marker[0], marker[1])
+		}
+		// Fill bytes may precede a marker
+		for marker[1] == 0xff {
+			b, err := br.ReadByte()
+			if err != nil {
+				return nil, nil, err
+			}
+			marker[1] = b
+		}
+
+		switch {
+		case marker[1] == 0xda || marker[1] == 0xd9:
+			// SOS or EOI: the metadata precedes the image data
+			header.Write([]byte{0xff, 0xd9})
+			return header.Bytes(), xmp, nil
+		case marker[1] == 0x01 || (marker[1] >= 0xd0 && marker[1] <= 0xd7):
+			// TEM and RSTn have no content
+			header.Write(marker)
+			continue
+		}
+
+		length := make([]byte, 2)
+		if _, err := io.ReadFull(br, length); err != nil {
+			return nil, nil, err
+		}
+		size := int64(binary.BigEndian.Uint16(length))
+		if size < 2 {
+			return nil, nil, fmt.Errorf("%w: invalid JPEG segment length %d", ErrCorrupt, size)
+		}
+		if err := checkLimit("JPEG metadata size", int64(header.Len())+size, r.headerLimit()); err != nil {
+			return nil, nil, err
+		}
+		data := make([]byte, size-2)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, nil, err
+		}
+		header.Write(marker)
+		header.Write(length)
+		header.Write(data)
+
+		if marker[1] == 0xe1 && bytes.HasPrefix(data, xmpJpegPrefix) {
+			packet := data[len(xmpJpegPrefix):]
+			if err := checkLimit("XMP size", int64(len(packet)), int64(r.limits.MaxXmpSize)); err != nil {
+				return nil, nil, err
+			}
+			xmp = append(xmp, packet)
+		}
+	}
+}
+
+// readPng reads the XMP packets of the iTXt chunks of a PNG file.
+func (r *containerReader) readPng() ([][]byte, error) {
+	var xmp [][]byte
+	for offset := int64(8); offset+8 <= r.size; {
+		chunk, err := r.readAt(offset, 8, "")
+		if err != nil {
+			return nil, err
+		}
+		length := int64(binary.BigEndian.Uint32(chunk))
+		switch string(chunk[4:]) {
+		case "iTXt":
+			if length < int64(len(xmpPngKeyword)) {
+				break
+			}
+			keyword, err := r.readAt(offset+8, int64(len(xmpPngKeyword)), "")
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(keyword, xmpPngKeyword) {
+				break
+			}
+			data, err := r.readAt(offset+8, length, "XMP size")
+			if err != nil {
+				return nil, err
+			}
+			if _, text, ok := splitPngITXt(data); ok {
+				xmp = append(xmp, text)
+			}
+		case "IEND":
+			return xmp, nil
+		}
+		// Length, type, data and CRC
+		offset += 12 + length
+	}
+	return xmp, nil
+}
+
+// readWebp reads the XMP packets of the XMP chunks of a WebP file.
+func (r *containerReader) readWebp() ([][]byte, error) {
+	header, err := r.readAt(0, 12, "")
+	if err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:]) != "WEBP" {
+		return nil, fmt.Errorf("%w: not a WebP file", ErrCorrupt)
+	}
+
+	var xmp [][]byte
+	for offset := int64(12); offset+8 <= r.size; {
+		chunk, err := r.readAt(offset, 8, "")
+		if err != nil {
+			return nil, err
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if string(chunk[:4]) == webpChunkXmp {
+			packet, err := r.readAt(offset+8, size, "XMP size")
+			if err != nil {
+				return nil, err
+			}
+			xmp = append(xmp, packet)
+		}
+		// Chunks are padded to an even size
+		offset += 8 + size + size%2
+	}
+	return xmp, nil
+}
+
+// readGif reads the XMP packets of the XMP application extensions of a GIF
+// file.
+func (r *containerReader) readGif() ([][]byte, error) {
+	g, err := newGifReader(io.NewSectionReader(r.file, 0, r.size))
+	if err != nil {
+		return nil, err
+	}
+
+	// The magic trailer adds 258 bytes to the packet
+	var limit int64
+	if r.limits.MaxXmpSize > 0 {
+		limit = int64(r.limits.MaxXmpSize) + 258
+	}
+
+	var xmp [][]byte
+	for {
+		introducer, label, err := g.next()
+		if err != nil {
+			return nil, err
+		}
+		switch introducer {
+		case gifTrailer:
+			return xmp, nil
+
+		case gifImageSeparator:
+			if _, err := g.image(false); err != nil {
+				return nil, err
+			}
+
+		case gifExtension:
+			if label == gifLabelApp {
+				identifier, err := g.r.Peek(1 + len(gifXmpIdentifier))
+				if err != nil {
+					return nil, err
+				}
+				if identifier[0] == byte(len(gifXmpIdentifier)) && bytes.Equal(identifier[1:], gifXmpIdentifier) {
+					if _, err := g.r.Discard(len(identifier)); err != nil {
+						return nil, err
+					}
+					// The raw packet reads as a chain of sub-blocks thanks to
+					// its magic trailer
+					packet, err := g.subBlocks(true, "XMP size", limit)
+					if err != nil {
+						return nil, err
+					}
+					xmp = append(xmp, packet)
+					continue
+				}
+			}
+			if _, err := g.subBlocks(false, "", 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readTiff reads the XMP packet of the XMLPacket tag of IFD0 of a TIFF file.
+func (r *containerReader) readTiff() ([][]byte, error) {
+	header, err := r.readAt(0, 8, "")
+	if err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder
+	switch string(header[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		// BigTIFF files have no EXIF data the package could read either
+		return nil, nil
+	}
+
+	ifd := int64(order.Uint32(header[4:]))
+	count, err := r.readAt(ifd, 2, "")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := r.readAt(ifd+2, 12*int64(order.Uint16(count)), "")
+	if err != nil {
+		return nil, err
+	}
+	for entry := 0; entry < len(entries); entry += 12 {
+		if order.Uint16(entries[entry:]) != tiffXmlPacketTagId {
+			continue
+		}
+		// BYTE or UNDEFINED values, stored in the entry up to 4 bytes
+		size := int64(order.Uint32(entries[entry+4:]))
+		if size <= 4 {
+			return [][]byte{entries[entry+8 : entry+8+int(size)]}, nil
+		}
+		packet, err := r.readAt(int64(order.Uint32(entries[entry+8:])), size, "XMP size")
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{packet}, nil
+	}
+	return nil, nil
+}
+
+// readHeif reads the XMP packets of the XMP items of a HEIF file.
+func (r *containerReader) readHeif() ([][]byte, error) {
+	// Find the meta box among the top-level boxes
+	var meta []byte
+	for offset := int64(0); offset+8 <= r.size; {
+		box, err := r.readAt(offset, 8, "")
+		if err != nil {
+			return nil, err
+		}
+		size := int64(binary.BigEndian.Uint32(box))
+		switch size {
+		case 0:
+			size = r.size - offset
+		case 1:
+			large, err := r.readAt(offset+8, 8, "")
+			if err != nil {
+				return nil, err
+			}
+			size = int64(binary.BigEndian.Uint64(large))
+		}
+		if size < 8 {
+			return nil, fmt.Errorf("%w: invalid size for HEIF box %q", ErrCorrupt, box[4:])
+		}
+
+		if string(box[4:]) == "meta" {
+			// The meta box holds the item properties, and the metadata items
+			// stored in its item data box
+			if err := checkLimit("HEIF meta box size", size, r.headerLimit()); err != nil {
+				return nil, err
+			}
+			if meta, err = r.readAt(offset, size, ""); err != nil {
+				return nil, err
+			}
+			break
+		}
+		offset += size
+	}
+	if meta == nil {
+		return nil, nil
+	}
+
+	heif, err := parseHeifFile(meta)
+	if err != nil {
+		return nil, err
+	}
+	var xmp [][]byte
+	for _, item := range heif.items {
+		if item.itemType != heifItemTypeMime || item.contentType != heifContentXmp {
+			continue
+		}
+		packet, err := r.readHeifItem(heif, item.id)
+		if err != nil {
+			return nil, err
+		}
+		xmp = append(xmp, packet)
+	}
+	return xmp, nil
+}
+
+// readHeifItem reads the data of an item of a HEIF file whose meta box is
+// parsed.
+func (r *containerReader) readHeifItem(heif *heifFile, id uint32) ([]byte, error) {
+	location := heif.location(id)
+	if location == nil || location.constructionMethod != 0 {
+		// Items of the item data box are read from the meta box
+		packet, err := heif.itemData(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkLimit("XMP size", int64(len(packet)), int64(r.limits.MaxXmpSize)); err != nil {
+			return nil, err
+		}
+		return packet, nil
+	}
+
+	// Items of the file are read from their extents
+	var packet []byte
+	for _, extent := range location.extents {
+		start := int64(location.baseOffset + extent.offset)
+		length := int64(extent.length)
+		if length == 0 {
+			length = r.size - start
+		}
+		if err := checkLimit("XMP size", int64(len(packet))+length, int64(r.limits.MaxXmpSize)); err != nil {
+			return nil, err
+		}
+		data, err := r.readAt(start, length, "")
+		if err != nil {
+			return nil, err
+		}
+		packet = append(packet, data...)
+	}
+	return packet, nil
+}
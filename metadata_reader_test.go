@@ -0,0 +1,177 @@
+package media_image
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"testing"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	pngstructure "github.com/dsoprea/go-png-image-structure/v2"
+	"github.com/smartmediafiles/media/media/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/tiff"
+)
+
+// addXmpPacket adds the XMP packet to the file at path, in the block of its
+// format holding XMP.
+func addXmpPacket(t *testing.T, path string, fileType types.FileType, packet []byte) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch fileType {
+	case ImagePng:
+		chunk := &pngstructure.Chunk{Type: "iTXt", Data: append(append(append([]byte{}, xmpPngKeyword...), 0, 0, 0, 0), packet...)}
+		chunk.Length = uint32(len(chunk.Data))
+		chunk.UpdateCrc32()
+		chunks := pngChunks(t, path).Chunks()
+		writePngChunks(t, path, append(chunks[:len(chunks)-1:len(chunks)-1], chunk, chunks[len(chunks)-1]))
+		return
+	case ImageWebp:
+		chunks, err := parseWebpChunks(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunks, err = updateWebpFeatures(setWebpChunk(chunks, webpChunkXmp, packet)); err != nil {
+			t.Fatal(err)
+		}
+		data = writeWebpChunks(chunks)
+	case ImageGif:
+		// After the global color table
+		offset := 13
+		if data[10]&0x80 != 0 {
+			offset += 3 << (data[10]&0x07 + 1)
+		}
+		extension := append([]byte{gifExtension, gifLabelApp, 11}, gifXmpIdentifier...)
+		extension = append(append(extension, packet...), gifXmpTrailer()...)
+		data = append(append(append([]byte{}, data[:offset]...), extension...), data[offset:]...)
+	case ImageTiff:
+		err := NewExifWriter().update(path, fileType, metadataEdit{
+			exif: func(editor *exifEditor) error {
+				return editor.setRaw(ifdPathRoot, tiffXmlPacketTagId, exifcommon.TypeByte, packet)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	case ImageHeic:
+		heif, err := parseHeifFile(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := heif.addItem(heifItemTypeMime)
+		infe := new(bytes.Buffer)
+		infe.Write([]byte{2, 0, 0, 0})
+		writeHeifId(infe, id, true)
+		infe.Write([]byte{0, 0})
+		infe.WriteString(heifItemTypeMime + "\x00" + heifContentXmp + "\x00")
+		item := &heif.items[len(heif.items)-1]
+		item.raw = isoBoxBytes("infe", infe.Bytes())
+		item.contentType = heifContentXmp
+		if err := heif.setItemData(id, packet); err != nil {
+			t.Fatal(err)
+		}
+		if data, err = heif.encode(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ContainerMetadata(t *testing.T) {
+	t.Log("Testing container metadata reader")
+
+	packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"/></x:xmpmeta>`)
+
+	t.Run("jpeg", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+
+		metadata, err := readContainerMetadata(path, ImageJpeg, DefaultLimits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, metadata.xmp, 1) {
+			assert.NotNil(t, extractXmpPacket(metadata.xmp[0]))
+		}
+		assert.Equal(t, []byte{0xff, 0xd9}, metadata.jpegHeader[len(metadata.jpegHeader)-2:])
+		_, err = parseIptc(metadata.jpegHeader)
+		assert.NoError(t, err)
+	})
+
+	for _, test := range []struct {
+		name     string
+		path     func(t *testing.T) string
+		fileType types.FileType
+	}{
+		{"png", func(t *testing.T) string {
+			return writeTestImage(t, "test.png", func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) })
+		}, ImagePng},
+		{"tiff", func(t *testing.T) string {
+			return writeTestImage(t, "test.tiff", func(b *bytes.Buffer, img image.Image) error { return tiff.Encode(b, img, nil) })
+		}, ImageTiff},
+		{"webp", func(t *testing.T) string { return copySample(t, "samples/webp/giphy.webp") }, ImageWebp},
+		{"gif", func(t *testing.T) string { return copySample(t, "samples/gif/sunflower-plants.gif") }, ImageGif},
+		{"heic", func(t *testing.T) string { return copySample(t, "samples/heic/netherlands.heic") }, ImageHeic},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := test.path(t)
+			addXmpPacket(t, path, test.fileType, packet)
+
+			metadata, err := readContainerMetadata(path, test.fileType, DefaultLimits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assert.Len(t, metadata.xmp, 1) {
+				assert.Equal(t, string(packet), string(extractXmpPacket(metadata.xmp[0])))
+			}
+			assert.Nil(t, metadata.jpegHeader)
+		})
+	}
+
+	t.Run("several packets", func(t *testing.T) {
+		path := writeTestImage(t, "test.png", func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) })
+		addXmpPacket(t, path, ImagePng, packet)
+		second := bytes.Replace(packet, []byte("<rdf:RDF"), []byte("<rdf:RDF xml:lang=\"fr\""), 1)
+		addXmpPacket(t, path, ImagePng, second)
+
+		metadata, err := readContainerMetadata(path, ImagePng, DefaultLimits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, metadata.xmp, 2) {
+			assert.Equal(t, string(packet), string(metadata.xmp[0]))
+			assert.Equal(t, string(second), string(metadata.xmp[1]))
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+
+		limits := DefaultLimits
+		limits.MaxXmpSize = 16
+		_, err := readContainerMetadata(path, ImageJpeg, limits)
+		assert.True(t, errors.Is(err, ErrLimitExceeded))
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		path := writeTestImage(t, "test.png", func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) })
+		addXmpPacket(t, path, ImagePng, packet)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = readContainerMetadata(writeFile(t, "truncated.png", data[:len(data)-40]), ImagePng, DefaultLimits)
+		assert.Error(t, err)
+	})
+}
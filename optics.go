@@ -0,0 +1,219 @@
+package media_image
+
+import (
+	"math"
+	"strings"
+)
+
+// fullFrameDiagonal is the diagonal, in millimeters, of a 35 mm film frame,
+// used to derive the field of view from the 35 mm equivalent focal length.
+const fullFrameDiagonal = 43.2666
+
+// Focal plane tags of the EXIF IFD.
+const (
+	exifTagFocalPlaneXResolution    = 0xa20e
+	exifTagFocalPlaneYResolution    = 0xa20f
+	exifTagFocalPlaneResolutionUnit = 0xa210
+)
+
+// Limits of a plausible crop factor, from medium format to the smallest
+// phone sensors. Focal plane resolutions are often computed for another image
+// size than the one stored, giving values out of these.
+const (
+	minCropFactor = 0.4
+	maxCropFactor = 12
+)
+
+// focalPlaneUnitMillimeters is the length, in millimeters, of the units of
+// the FocalPlaneResolutionUnit tag.
+var focalPlaneUnitMillimeters = map[int]float64{
+	2: 25.4,  // Inch, the default
+	3: 10,    // Centimeter
+	4: 1,     // Millimeter
+	5: 0.001, // Micrometer
+}
+
+// cameraCropFactors is the crop factor of common cameras, for those not
+// recording their focal plane resolution, by key as returned by cameraKey.
+var cameraCropFactors = map[string]float64{
+	"canon eos 5d mark iii":         1,
+	"canon eos 5d mark iv":          1,
+	"canon eos 6d":                  1,
+	"canon eos 7d mark ii":          1.6,
+	"canon eos 7d":                  1.6,
+	"canon eos 80d":                 1.6,
+	"canon eos 90d":                 1.6,
+	"canon eos r10":                 1.6,
+	"canon eos r5":                  1,
+	"canon eos r6":                  1,
+	"canon eos r7":                  1.6,
+	"canon powershot g7 x mark iii": 2.73,
+	"canon powershot g7 x":          2.73,
+	"dji fc220":                     5.6,
+	"dji fc6310":                    2.73,
+	"fujifilm x-t3":                 1.5,
+	"fujifilm x-t4":                 1.5,
+	"fujifilm x100v":                1.5,
+	"gopro hero8 black":             5.6,
+	"hasselblad l1d-20c":            2.73,
+	"hasselblad x1d ii 50c":         0.79,
+	"nikon coolpix p6000":           4.55,
+	"nikon d3500":                   1.5,
+	"nikon d5600":                   1.5,
+	"nikon d750":                    1,
+	"nikon d7500":                   1.5,
+	"nikon d850":                    1,
+	"nikon z 50":                    1.5,
+	"nikon z 6":                     1,
+	"nikon z 7":                     1,
+	"olympus e-m10markiii":          2,
+	"olympus e-m1markii":            2,
+	"olympus tg-6":                  5.6,
+	"panasonic dc-g9":               2,
+	"panasonic dc-gh5":              2,
+	"panasonic dmc-lx100":           2.2,
+	"ricoh gr iii":                  1.5,
+	"samsung nx1":                   1.5,
+	"sigma fp":                      1,
+	"sony dsc-rx100m7":              2.73,
+	"sony ilce-6400":                1.5,
+	"sony ilce-6600":                1.5,
+	"sony ilce-7m3":                 1,
+	"sony ilce-7rm4":                1,
+}
+
+// cameraKey returns the key of a camera in cameraCropFactors: the first word
+// of the make, e.g. "nikon" for "NIKON CORPORATION", and the model without
+// that word when repeated there, as in "Canon EOS 80D".
+func cameraKey(cameraMake, cameraModel string) string {
+	brand, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(cameraMake)), " ")
+	model := strings.ToLower(strings.TrimSpace(cameraModel))
+	model = strings.TrimPrefix(model, brand+" ")
+	return strings.TrimSpace(brand + " " + model)
+}
+
+// parseFocalPlane sets the focal plane fields of the image data from the
+// EXIF IFD read by r.
+func parseFocalPlane(r ifdReader, imageData *ImageData) {
+	if resolution, ok := r.rational(exifTagFocalPlaneXResolution); ok && resolution > 0 {
+		imageData.FocalPlaneXResolution = resolution
+	}
+	if resolution, ok := r.rational(exifTagFocalPlaneYResolution); ok && resolution > 0 {
+		imageData.FocalPlaneYResolution = resolution
+	}
+	if unit, ok := r.short(exifTagFocalPlaneResolutionUnit); ok {
+		imageData.FocalPlaneResolutionUnit = int(unit)
+	}
+}
+
+// sensorCropFactor returns the crop factor of the sensor from its size, as
+// given by the focal plane resolution.
+func (d ImageData) sensorCropFactor() (float64, bool) {
+	if d.FocalPlaneXResolution <= 0 || d.FocalPlaneYResolution <= 0 || d.ImageWidth <= 0 || d.ImageHeight <= 0 {
+		return 0, false
+	}
+	unit := d.FocalPlaneResolutionUnit
+	if unit == 0 {
+		unit = 2
+	}
+	millimeters, ok := focalPlaneUnitMillimeters[unit]
+	if !ok {
+		return 0, false
+	}
+
+	width := float64(d.ImageWidth) / d.FocalPlaneXResolution * millimeters
+	height := float64(d.ImageHeight) / d.FocalPlaneYResolution * millimeters
+	cropFactor := fullFrameDiagonal / math.Hypot(width, height)
+	if cropFactor < minCropFactor || cropFactor > maxCropFactor {
+		return 0, false
+	}
+	return cropFactor, true
+}
+
+// cropFactor returns the crop factor of the camera from the size of its
+// sensor, else from the camera database, else from the stored 35 mm
+// equivalent focal length.
+func (d ImageData) cropFactor() (float64, bool) {
+	if cropFactor, ok := d.sensorCropFactor(); ok {
+		return cropFactor, true
+	}
+	if cropFactor, ok := cameraCropFactors[cameraKey(d.CameraMake, d.CameraModel)]; ok {
+		return cropFactor, true
+	}
+
+	// The stored equivalent includes any digital zoom, hence the last resort
+	focalLength35mm, ok := parseExifNumber(d.LensFocalLength35mm)
+	if ok && focalLength35mm > 0 && d.FocalLength > 0 {
+		cropFactor := focalLength35mm / d.FocalLength
+		if cropFactor >= minCropFactor && cropFactor <= maxCropFactor {
+			return cropFactor, true
+		}
+	}
+	return 0, false
+}
+
+// focalLength35mm returns the 35 mm equivalent focal length, as stored or
+// else computed from the focal length and the crop factor.
+func (d ImageData) focalLength35mm() (float64, bool) {
+	if d.FocalLength35mm > 0 {
+		return d.FocalLength35mm, true
+	}
+	if focalLength35mm, ok := parseExifNumber(d.LensFocalLength35mm); ok && focalLength35mm > 0 {
+		return focalLength35mm, true
+	}
+	if cropFactor, ok := d.cropFactor(); ok && d.FocalLength > 0 {
+		return d.FocalLength * cropFactor, true
+	}
+	return 0, false
+}
+
+// angleOfView returns the horizontal, vertical and diagonal angles of view,
+// in radians, of a 35 mm equivalent focal length. The equivalence preserves
+// the diagonal angle, the others depending on the aspect ratio of the image,
+// taken as 3:2 when unknown.
+func angleOfView(focalLength35mm float64, width, height int) (horizontal, vertical, diagonal float64) {
+	w, h := float64(width), float64(height)
+	if w <= 0 || h <= 0 {
+		w, h = 36, 24
+	}
+	scale := fullFrameDiagonal / math.Hypot(w, h)
+	horizontal = 2 * math.Atan(w*scale/(2*focalLength35mm))
+	vertical = 2 * math.Atan(h*scale/(2*focalLength35mm))
+	diagonal = 2 * math.Atan(fullFrameDiagonal/(2*focalLength35mm))
+	return horizontal, vertical, diagonal
+}
+
+// exposureValue returns the exposure value at ISO 100 of the exposure
+// settings, from the raw exposure time and f-number or else from their APEX
+// values.
+func (d ImageData) exposureValue() (float64, bool) {
+	fNumber := d.FNumber
+	if fNumber <= 0 {
+		fNumber = d.ApertureFNumber
+	}
+	exposureTime := d.ExposureTime.Float()
+	if exposureTime <= 0 {
+		exposureTime = d.ShutterSpeedTime
+	}
+	if fNumber <= 0 || exposureTime <= 0 || d.ISOSpeed <= 0 {
+		return 0, false
+	}
+	return math.Log2(fNumber*fNumber/exposureTime) - math.Log2(float64(d.ISOSpeed)/100), true
+}
+
+// setPhotographicValues sets the photographic values of the image data
+// computed from its exposure and lens values.
+func setPhotographicValues(imageData *ImageData) {
+	imageData.EV100, imageData.HasEV100 = imageData.exposureValue()
+	imageData.CropFactor, _ = imageData.cropFactor()
+
+	imageData.FocalLength35mm = 0
+	imageData.AngleOfViewHorizontal, imageData.AngleOfViewVertical, imageData.AngleOfViewDiagonal = 0, 0, 0
+	if focalLength35mm, ok := imageData.focalLength35mm(); ok {
+		imageData.FocalLength35mm = focalLength35mm
+		horizontal, vertical, diagonal := angleOfView(focalLength35mm, imageData.ImageWidth, imageData.ImageHeight)
+		imageData.AngleOfViewHorizontal = degrees(horizontal)
+		imageData.AngleOfViewVertical = degrees(vertical)
+		imageData.AngleOfViewDiagonal = degrees(diagonal)
+	}
+}
@@ -0,0 +1,122 @@
+package media_image
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Optics(t *testing.T) {
+	t.Log("Testing photographic values")
+
+	t.Run("exposure value", func(t *testing.T) {
+		data := ImageData{FNumber: 16, ExposureTime: Rational{1, 100}, ISOSpeed: 100}
+		ev, ok := data.exposureValue()
+		assert.True(t, ok)
+		assert.InDelta(t, 14.64, ev, 0.01) // Sunny 16 rule
+
+		// Doubling the sensitivity for the same settings means half the light
+		data.ISOSpeed = 200
+		ev, _ = data.exposureValue()
+		assert.InDelta(t, 13.64, ev, 0.01)
+
+		// APEX values are used when the raw ones are missing
+		data = ImageData{ApertureFNumber: 1, ShutterSpeedTime: 1, ISOSpeed: 100}
+		ev, ok = data.exposureValue()
+		assert.True(t, ok)
+		assert.Zero(t, ev)
+
+		_, ok = ImageData{FNumber: 2.8, ExposureTime: Rational{1, 60}}.exposureValue()
+		assert.False(t, ok)
+	})
+
+	t.Run("crop factor", func(t *testing.T) {
+		// A 23.5 x 15.6 mm APS-C sensor of 6000 x 4000 pixels
+		data := ImageData{
+			ImageWidth:               6000,
+			ImageHeight:              4000,
+			FocalPlaneXResolution:    6000 / 2.35,
+			FocalPlaneYResolution:    4000 / 1.56,
+			FocalPlaneResolutionUnit: 3,
+			FocalLength:              35,
+		}
+		cropFactor, ok := data.cropFactor()
+		assert.True(t, ok)
+		assert.InDelta(t, 1.53, cropFactor, 0.01)
+		focalLength35mm, _ := data.focalLength35mm()
+		assert.InDelta(t, 53.7, focalLength35mm, 0.1)
+
+		// A resolution computed for another image size is ignored
+		data.ImageWidth, data.ImageHeight = 60, 40
+		_, ok = data.sensorCropFactor()
+		assert.False(t, ok)
+
+		data = ImageData{CameraMake: "NIKON CORPORATION", CameraModel: "NIKON D7500", FocalLength: 50}
+		cropFactor, ok = data.cropFactor()
+		assert.True(t, ok)
+		assert.Equal(t, 1.5, cropFactor)
+		assert.Equal(t, "canon eos 80d", cameraKey("Canon", "Canon EOS 80D"))
+		assert.Equal(t, "sony ilce-7m3", cameraKey("SONY", "ILCE-7M3"))
+
+		data = ImageData{FocalLength: 4, LensFocalLength35mm: "26"}
+		cropFactor, ok = data.cropFactor()
+		assert.True(t, ok)
+		assert.Equal(t, 6.5, cropFactor)
+
+		_, ok = ImageData{FocalLength: 4}.cropFactor()
+		assert.False(t, ok)
+	})
+
+	t.Run("angle of view", func(t *testing.T) {
+		horizontal, vertical, diagonal := angleOfView(50, 0, 0)
+		assert.InDelta(t, 39.60, degrees(horizontal), 0.01)
+		assert.InDelta(t, 26.99, degrees(vertical), 0.01)
+		assert.InDelta(t, 46.79, degrees(diagonal), 0.01)
+
+		// A square image has the diagonal angle of the full frame
+		horizontal, vertical, diagonal = angleOfView(50, 1000, 1000)
+		assert.InDelta(t, horizontal, vertical, 1e-9)
+		assert.InDelta(t, 2*math.Atan(math.Tan(diagonal/2)/math.Sqrt2), horizontal, 1e-9)
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		data := loadImage(t, "samples/jpg/gps/gps-1.jpg").ImageData
+		assert.True(t, data.HasEV100)
+		assert.InDelta(t, 12.46, data.EV100, 0.01)
+		assert.Equal(t, 28.0, data.FocalLength35mm)
+		assert.InDelta(t, 75.38, data.AngleOfViewDiagonal, 0.01)
+		assert.InDelta(t, 63.44, data.AngleOfViewHorizontal, 0.01)
+		assert.InDelta(t, 49.74, data.AngleOfViewVertical, 0.01)
+
+		// Without a stored equivalent, it is computed from the sensor size
+		data = loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg").ImageData
+		assert.Empty(t, data.LensFocalLength35mm)
+		assert.Greater(t, data.FocalPlaneXResolution, 0.0)
+		assert.InDelta(t, 5.88, data.CropFactor, 0.01)
+		assert.InDelta(t, 58.2, data.FocalLength35mm, 0.1)
+	})
+
+	t.Run("decoder dimensions", func(t *testing.T) {
+		path := writeTestImage(t, "optics.jpg", func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) })
+		i := loadImage(t, path)
+		data := i.ImageData
+		data.LensFocalLength35mm = "50"
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		// The angles of view follow the 2:1 aspect ratio of the pixels, not
+		// recorded in EXIF
+		reloaded := loadImage(t, path)
+		assert.Equal(t, 16, reloaded.ImageData.ImageWidth)
+		assert.Equal(t, FieldProvenance{Source: SourceDecoder}, reloaded.Provenance["ImageWidth"])
+		horizontal, vertical, _ := angleOfView(50, 16, 8)
+		assert.InDelta(t, degrees(horizontal), reloaded.ImageData.AngleOfViewHorizontal, 1e-9)
+		assert.InDelta(t, degrees(vertical), reloaded.ImageData.AngleOfViewVertical, 1e-9)
+		assert.Equal(t, derivedProvenance, reloaded.Provenance["AngleOfViewHorizontal"])
+	})
+}
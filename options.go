@@ -0,0 +1,107 @@
+package media_image
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// FileTimeFallback tells when the time of the file stands in for the date of
+// the image.
+type FileTimeFallback int
+
+// Fallbacks to the time of the file, its creation time or else its last
+// write time.
+const (
+	FileTimeWithoutExif FileTimeFallback = iota // When the file has no EXIF data, or it is not read
+	FileTimeWithoutDate                         // Also when the EXIF data holds no date
+	FileTimeNever                               // DateTime is only read from the metadata
+)
+
+// Options configures how an image and its metadata are read. The zero value
+// reads everything; NewImageInfo starts from DefaultLimits.
+type Options struct {
+	SkipDecodeConfig       bool // Leave the dimensions to the metadata, without decoding the image header
+	SkipTimeZoneLookup     bool // Leave GPSTimeZone empty, which also leaves the dates as recorded
+	SkipTimeZoneAdjustment bool // Look the time zone up, but keep the dates as recorded
+
+	// Sources lists the metadata read among SourceExif, SourceXmp, SourceIptc
+	// and SourceSidecar, nil for all of them
+	Sources []FieldSource
+
+	// Tags lists the names of the EXIF tags read, e.g. "DateTimeOriginal",
+	// nil for all of them. The reference tags of the GPS values, such as
+	// GPSLatitudeRef, come along with them. Values derived from tags left out
+	// are not computed.
+	Tags []string
+
+	FileTimeFallback FileTimeFallback
+	Limits           Limits
+	Logger           *slog.Logger // Logger of the warnings, nil to only collect them
+}
+
+// Option is a functional option of NewImageInfo.
+type Option func(*Options)
+
+// WithoutDecodeConfig skips decoding the image header for its dimensions.
+func WithoutDecodeConfig() Option {
+	return func(o *Options) { o.SkipDecodeConfig = true }
+}
+
+// WithoutTimeZoneLookup skips looking the time zone of the GPS position up,
+// and so adjusting the dates to it.
+func WithoutTimeZoneLookup() Option {
+	return func(o *Options) { o.SkipTimeZoneLookup = true }
+}
+
+// WithoutTimeZoneAdjustment keeps the dates as recorded instead of moving
+// them to the time zone of the GPS position.
+func WithoutTimeZoneAdjustment() Option {
+	return func(o *Options) { o.SkipTimeZoneAdjustment = true }
+}
+
+// WithSources sets the metadata read, among SourceExif, SourceXmp,
+// SourceIptc and SourceSidecar.
+func WithSources(sources ...FieldSource) Option {
+	return func(o *Options) { o.Sources = sources }
+}
+
+// WithTags restricts the EXIF tags read to those named.
+func WithTags(names ...string) Option {
+	return func(o *Options) { o.Tags = names }
+}
+
+// WithFileTimeFallback sets when the time of the file stands in for the date
+// of the image.
+func WithFileTimeFallback(fallback FileTimeFallback) Option {
+	return func(o *Options) { o.FileTimeFallback = fallback }
+}
+
+// WithLimits sets the limits the file and its metadata are checked against.
+func WithLimits(limits Limits) Option {
+	return func(o *Options) { o.Limits = limits }
+}
+
+// WithLogger sets the logger the warnings are logged to as they are met.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *Options) { o.Logger = logger }
+}
+
+// reads checks if the metadata of a source are read.
+func (o Options) reads(source FieldSource) bool {
+	return o.Sources == nil || slices.Contains(o.Sources, source)
+}
+
+// allowedTags returns the set of the EXIF tags read, nil for all of them.
+// Every tag brings its reference tag, without which a GPS value would lose
+// its hemisphere, sign or unit.
+func (o Options) allowedTags() map[string]bool {
+	if o.Tags == nil {
+		return nil
+	}
+	allowed := make(map[string]bool, 2*len(o.Tags))
+	for _, name := range o.Tags {
+		allowed[name] = true
+		allowed[name+"Ref"] = true
+	}
+	return allowed
+}
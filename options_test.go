@@ -0,0 +1,179 @@
+package media_image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// loadImageWithOptions loads an image and its metadata with the options.
+func loadImageWithOptions(t *testing.T, path string, opts ...Option) *ImageInfo {
+	t.Helper()
+
+	imgInfo, err := NewImageInfo(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := imgInfo.Exif()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// iptcSegment returns a JPEG APP13 segment holding the IPTC datasets of the
+// application record.
+func iptcSegment(datasets map[uint8]string) []byte {
+	var stream bytes.Buffer
+	for _, dataset := range []uint8{iptcDatasetDateCreated, iptcDatasetTimeCreated, iptcDatasetByline, iptcDatasetCaptionAbstract} {
+		if value, ok := datasets[dataset]; ok {
+			stream.Write([]byte{0x1c, iptcRecordApplication, dataset})
+			_ = binary.Write(&stream, binary.BigEndian, uint16(len(value)))
+			stream.WriteString(value)
+		}
+	}
+	if stream.Len()%2 == 1 {
+		stream.WriteByte(0)
+	}
+
+	// Photoshop image resource of the IPTC-NAA record, with an empty name
+	var payload bytes.Buffer
+	payload.WriteString("Photoshop 3.0\x00")
+	payload.WriteString("8BIM")
+	payload.Write([]byte{0x04, 0x04, 0x00, 0x00})
+	_ = binary.Write(&payload, binary.BigEndian, uint32(stream.Len()))
+	payload.Write(stream.Bytes())
+
+	segment := []byte{0xff, 0xed, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(payload.Len()+2))
+	return append(segment, payload.Bytes()...)
+}
+
+func Test_Options(t *testing.T) {
+	t.Log("Testing extraction options")
+
+	t.Run("decode config", func(t *testing.T) {
+		i := loadImageWithOptions(t, "samples/gif/sunflower-plants.gif", WithoutDecodeConfig())
+		assert.Zero(t, i.ImageData.ImageWidth)
+		assert.False(t, i.ImageData.DateTime.IsZero())
+		assert.Equal(t, SourceFileSystem, i.Provenance["DateTime"].Source)
+	})
+
+	t.Run("file time", func(t *testing.T) {
+		i := loadImageWithOptions(t, "samples/gif/sunflower-plants.gif", WithFileTimeFallback(FileTimeNever))
+		assert.True(t, i.ImageData.DateTime.IsZero())
+		_, ok := i.Provenance["DateTime"]
+		assert.False(t, ok)
+
+		// Images with EXIF data only fall back to the file time without date
+		path := "samples/jpg/gps/gps-1.jpg"
+		i = loadImageWithOptions(t, path, WithTags("Make"))
+		assert.True(t, i.ImageData.DateTime.IsZero())
+		i = loadImageWithOptions(t, path, WithTags("Make"), WithFileTimeFallback(FileTimeWithoutDate))
+		assert.Equal(t, SourceFileSystem, i.Provenance["DateTime"].Source)
+		i = loadImageWithOptions(t, path, WithFileTimeFallback(FileTimeWithoutDate))
+		assert.Equal(t, SourceExif, i.Provenance["DateTime"].Source)
+	})
+
+	t.Run("time zone", func(t *testing.T) {
+		path := "samples/heic/netherlands.heic"
+		adjusted := loadImageWithOptions(t, path)
+		assert.True(t, adjusted.Provenance["DateTimeOriginal"].Adjusted)
+
+		recorded := loadImageWithOptions(t, path, WithoutTimeZoneLookup())
+		assert.Empty(t, recorded.ImageData.GPSTimeZone)
+		assert.False(t, recorded.Provenance["DateTimeOriginal"].Adjusted)
+
+		i := loadImageWithOptions(t, path, WithoutTimeZoneAdjustment())
+		assert.Equal(t, "Europe/Amsterdam", i.ImageData.GPSTimeZone)
+		assert.False(t, i.Provenance["DateTimeOriginal"].Adjusted)
+		assert.Equal(t, recorded.ImageData.DateTimeOriginal, i.ImageData.DateTimeOriginal)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		i := loadImageWithOptions(t, "samples/jpg/gps/gps-1.jpg", WithTags("Make", "ExposureTime"))
+		assert.NotEmpty(t, i.ImageData.CameraMake)
+		assert.Empty(t, i.ImageData.CameraModel)
+		assert.NotZero(t, i.ImageData.ExposureTime)
+		assert.Zero(t, i.ImageData.FNumber)
+		assert.Zero(t, i.ImageData.GPSLatitude)
+
+		// The reference tags come along with the GPS values
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		i = loadImage(t, path)
+		data := i.ImageData
+		data.GPSLatitude = -33.86
+		data.GPSLongitude = -70.5
+		data.GPSAltitude = -12
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		i = loadImageWithOptions(t, path, WithTags("GPSLatitude", "GPSLongitude", "GPSAltitude"))
+		assert.InDelta(t, -33.86, i.ImageData.GPSLatitude, 1e-6)
+		assert.InDelta(t, -70.5, i.ImageData.GPSLongitude, 1e-6)
+		assert.Equal(t, -12.0, i.ImageData.GPSAltitude)
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		i := loadImageWithOptions(t, "samples/jpg/gps/gps-1.jpg", WithSources(SourceXmp))
+		assert.Empty(t, i.ImageData.CameraMake)
+		assert.Equal(t, SourceFileSystem, i.Provenance["DateTime"].Source)
+		for _, w := range i.Warnings {
+			assert.NotErrorIs(t, w.Err, ErrNoExif)
+		}
+	})
+
+	t.Run("iptc", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		jpeg, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		segment := iptcSegment(map[uint8]string{
+			iptcDatasetDateCreated:     "20200102",
+			iptcDatasetTimeCreated:     "103000+0100",
+			iptcDatasetByline:          "Jane Doe",
+			iptcDatasetCaptionAbstract: "Fjord",
+		})
+		updated := append(append(append([]byte{}, jpeg[:2]...), segment...), jpeg[2:]...)
+		if err := os.WriteFile(path, updated, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		i := loadImageWithOptions(t, path)
+		assert.Equal(t, "Jane Doe", i.ImageData.Artist)
+		assert.Equal(t, FieldProvenance{Source: SourceIptc, Tag: "By-line"}, i.Provenance["Artist"])
+		assert.Equal(t, "Fjord", i.ImageData.Description)
+
+		// EXIF values come first
+		assert.Equal(t, SourceExif, i.Provenance["DateTimeOriginal"].Source)
+
+		i = loadImageWithOptions(t, path, WithSources(SourceIptc))
+		assert.Equal(t, "Jane Doe", i.ImageData.Artist)
+		expected := time.Date(2020, 1, 2, 10, 30, 0, 0, time.FixedZone("", 3600))
+		assert.True(t, expected.Equal(i.ImageData.DateTimeOriginal), i.ImageData.DateTimeOriginal)
+
+		i = loadImageWithOptions(t, path, WithSources(SourceExif))
+		assert.NotEqual(t, "Jane Doe", i.ImageData.Artist)
+	})
+
+	t.Run("sidecar", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		sidecar := strings.TrimSuffix(path, ".jpg") + ".xmp"
+		if err := os.WriteFile(sidecar, []byte(djiXmpPacket), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		i := loadImageWithOptions(t, path)
+		if assert.NotNil(t, i.ImageData.Drone) {
+			assert.Equal(t, SourceSidecar, i.Provenance["Drone"].Source)
+		}
+		i = loadImageWithOptions(t, path, WithSources(SourceExif, SourceXmp))
+		assert.Nil(t, i.ImageData.Drone)
+	})
+}
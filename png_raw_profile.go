@@ -0,0 +1,115 @@
+package media_image
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	pngstructure "github.com/dsoprea/go-png-image-structure/v2"
+)
+
+// pngRawProfileKeywords are the keywords of the PNG text chunks in which
+// ImageMagick and older tools store a hex-encoded copy of the EXIF data.
+var pngRawProfileKeywords = []string{"Raw profile type exif", "Raw profile type APP1"}
+
+// maxPngRawProfileSize is the largest text of a raw profile inflated, the
+// hex digits of the largest EXIF data and their line breaks.
+var maxPngRawProfileSize = 3 * DefaultLimits.MaxExifSize
+
+// isPngRawExifProfile checks if a PNG chunk is a text chunk holding a raw
+// EXIF profile.
+func isPngRawExifProfile(chunk *pngstructure.Chunk) bool {
+	switch chunk.Type {
+	case "tEXt", "zTXt", "iTXt":
+		keyword, _, _ := bytes.Cut(chunk.Data, []byte{0})
+		return slices.Contains(pngRawProfileKeywords, string(keyword))
+	}
+	return false
+}
+
+// decodePngRawProfile returns the EXIF data of a raw profile chunk, and the
+// "Exif\0\0" identifier preceding it, nil when the profile has none.
+func decodePngRawProfile(chunk *pngstructure.Chunk) ([]byte, []byte, error) {
+	keywordEnd := bytes.IndexByte(chunk.Data, 0)
+
+	var text []byte
+	switch chunk.Type {
+	case "tEXt":
+		text = chunk.Data[keywordEnd+1:]
+	case "zTXt":
+		// Compression method, then the zlib stream
+		if len(chunk.Data) < keywordEnd+2 {
+			return nil, nil, fmt.Errorf("%w: truncated raw profile", ErrCorrupt)
+		}
+		r, err := zlib.NewReader(bytes.NewReader(chunk.Data[keywordEnd+2:]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: raw profile: %v", ErrCorrupt, err)
+		}
+		if text, err = io.ReadAll(io.LimitReader(r, int64(maxPngRawProfileSize)+1)); err != nil {
+			return nil, nil, fmt.Errorf("%w: raw profile: %v", ErrCorrupt, err)
+		}
+		if len(text) > maxPngRawProfileSize {
+			return nil, nil, fmt.Errorf("%w: raw profile larger than %d bytes", ErrLimitExceeded, maxPngRawProfileSize)
+		}
+	case "iTXt":
+		_, body, ok := splitPngITXt(chunk.Data)
+		if !ok {
+			return nil, nil, fmt.Errorf("compressed or malformed raw profile iTXt chunk")
+		}
+		text = body
+	}
+
+	// Name of the profile, length of the data and its hex digits
+	fields := bytes.Fields(text)
+	if len(fields) < 2 {
+		return nil, nil, fmt.Errorf("%w: malformed raw profile", ErrCorrupt)
+	}
+	length, err := strconv.Atoi(string(fields[1]))
+	digits := bytes.Join(fields[2:], nil)
+	if err != nil || length < 0 || len(digits) != 2*length {
+		return nil, nil, fmt.Errorf("%w: malformed raw profile length", ErrCorrupt)
+	}
+	data := make([]byte, length)
+	if _, err := hex.Decode(data, digits); err != nil {
+		return nil, nil, fmt.Errorf("%w: raw profile: %v", ErrCorrupt, err)
+	}
+
+	if bytes.HasPrefix(data, webpExifPrefix) {
+		return data[len(webpExifPrefix):], webpExifPrefix, nil
+	}
+	return data, nil, nil
+}
+
+// encodePngRawProfile encodes EXIF data, preceded by the prefix, into a zTXt
+// raw profile chunk, in the layout of ImageMagick.
+func encodePngRawProfile(keyword string, prefix []byte, rawExif []byte) (*pngstructure.Chunk, error) {
+	data := append(append([]byte{}, prefix...), rawExif...)
+
+	text := new(bytes.Buffer)
+	fmt.Fprintf(text, "\n%s\n%8d", strings.TrimPrefix(keyword, "Raw profile type "), len(data))
+	for offset := 0; offset < len(data); offset += 36 {
+		text.WriteByte('\n')
+		text.WriteString(hex.EncodeToString(data[offset:min(offset+36, len(data))]))
+	}
+	text.WriteByte('\n')
+
+	b := new(bytes.Buffer)
+	b.WriteString(keyword)
+	b.Write([]byte{0, 0}) // Keyword terminator, deflate compression method
+	w := zlib.NewWriter(b)
+	if _, err := w.Write(text.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	chunk := &pngstructure.Chunk{Type: "zTXt", Data: b.Bytes(), Length: uint32(b.Len())}
+	chunk.UpdateCrc32()
+	return chunk, nil
+}
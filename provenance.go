@@ -0,0 +1,146 @@
+package media_image
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// FieldSource is where the value of an ImageData field comes from.
+type FieldSource string
+
+// Sources of the ImageData fields.
+const (
+	SourceExif       FieldSource = "exif"
+	SourceXmp        FieldSource = "xmp"
+	SourceIptc       FieldSource = "iptc"
+	SourceSidecar    FieldSource = "sidecar"    // XMP sidecar file
+	SourceFileSystem FieldSource = "filesystem" // File times
+	SourceDecoder    FieldSource = "decoder"    // Image header read by the image decoder
+	SourceDerived    FieldSource = "derived"    // Computed from other fields
+)
+
+// FieldProvenance describes where the value of an ImageData field comes from.
+type FieldProvenance struct {
+	Source  FieldSource
+	Tag     string // EXIF tag or XMP namespace the value was read from, empty when derived
+	IfdPath string // Fully-qualified path of the IFD of an EXIF tag, e.g. "IFD/Exif"
+
+	// Adjusted is set when the value read was changed, e.g. a date moved to
+	// the time zone of the GPS position
+	Adjusted bool
+}
+
+// String returns a short description of the provenance, e.g.
+// "exif:IFD/Exif/PixelXDimension".
+func (p FieldProvenance) String() string {
+	s := string(p.Source)
+	switch {
+	case p.IfdPath != "" && p.Tag != "":
+		s += fmt.Sprintf(":%s/%s", p.IfdPath, p.Tag)
+	case p.IfdPath != "":
+		s += ":" + p.IfdPath
+	case p.Tag != "":
+		s += ":" + p.Tag
+	}
+	if p.Adjusted {
+		s += " (adjusted)"
+	}
+	return s
+}
+
+// exifProvenance returns the provenance of a value read from an EXIF tag.
+func exifProvenance(ifdPath string, tag string) FieldProvenance {
+	return FieldProvenance{Source: SourceExif, Tag: tag, IfdPath: ifdPath}
+}
+
+// derivedProvenance is the provenance of the values computed from others.
+var derivedProvenance = FieldProvenance{Source: SourceDerived}
+
+// specialFieldProvenances is the provenance of the fields the parser sets
+// apart from the exif struct tags, by field name.
+var specialFieldProvenances = map[string]FieldProvenance{
+	"GPS":                  exifProvenance(ifdPathGps, ""),
+	"GPSLatitude":          exifProvenance(ifdPathGps, "GPSLatitude"),
+	"GPSLongitude":         exifProvenance(ifdPathGps, "GPSLongitude"),
+	"GPSAltitude":          exifProvenance(ifdPathGps, "GPSAltitude"),
+	"GPSTimestamp":         exifProvenance(ifdPathGps, "GPSTimeStamp"),
+	"GPSProcessingMethod":  exifProvenance(ifdPathGps, "GPSProcessingMethod"),
+	"GPSStatus":            exifProvenance(ifdPathGps, "GPSStatus"),
+	"GPSSatellites":        exifProvenance(ifdPathGps, "GPSSatellites"),
+	"GPSHPositioningError": exifProvenance(ifdPathGps, "GPSHPositioningError"),
+	"GPSSpeed":             exifProvenance(ifdPathGps, "GPSSpeed"),
+	"GPSTrack":             exifProvenance(ifdPathGps, "GPSTrack"),
+	"GPSImgDirection":      exifProvenance(ifdPathGps, "GPSImgDirection"),
+	"GPSDestLatitude":      exifProvenance(ifdPathGps, "GPSDestLatitude"),
+	"GPSDestLongitude":     exifProvenance(ifdPathGps, "GPSDestLongitude"),
+	"GPSDestBearing":       exifProvenance(ifdPathGps, "GPSDestBearing"),
+	"GPSDestDistance":      exifProvenance(ifdPathGps, "GPSDestDistance"),
+	"GPSTimeZone":          derivedProvenance,
+	"GPSCountryCode":       derivedProvenance,
+	"GPSCountryName":       derivedProvenance,
+	"GPSRegion":            derivedProvenance,
+	"GPSCity":              derivedProvenance,
+	"GPSCityDistance":      derivedProvenance,
+	"GPSGeohash":           derivedProvenance,
+	"GPSTimestampLocal":    derivedProvenance,
+
+	// Set from the GPS time zone when the offset was not recorded
+	"TimeOffset":    derivedProvenance,
+	"HasTimeOffset": derivedProvenance,
+
+	"ExposureTime":             exifProvenance(ifdPathExif, "ExposureTime"),
+	"ExposureBias":             exifProvenance(ifdPathExif, "ExposureBiasValue"),
+	"ShutterSpeedTime":         exifProvenance(ifdPathExif, "ShutterSpeedValue"),
+	"FNumber":                  exifProvenance(ifdPathExif, "FNumber"),
+	"ApertureFNumber":          exifProvenance(ifdPathExif, "ApertureValue"),
+	"MaxApertureFNumber":       exifProvenance(ifdPathExif, "MaxApertureValue"),
+	"FocalLength":              exifProvenance(ifdPathExif, "FocalLength"),
+	"FocalPlaneXResolution":    exifProvenance(ifdPathExif, "FocalPlaneXResolution"),
+	"FocalPlaneYResolution":    exifProvenance(ifdPathExif, "FocalPlaneYResolution"),
+	"FocalPlaneResolutionUnit": exifProvenance(ifdPathExif, "FocalPlaneResolutionUnit"),
+
+	"WhiteBalance":     exifProvenance(ifdPathExif, "WhiteBalance"),
+	"Flash":            exifProvenance(ifdPathExif, "Flash"),
+	"MeteringMode":     exifProvenance(ifdPathExif, "MeteringMode"),
+	"ExposureProgram":  exifProvenance(ifdPathExif, "ExposureProgram"),
+	"SceneCaptureType": exifProvenance(ifdPathExif, "SceneCaptureType"),
+	"LightSource":      exifProvenance(ifdPathExif, "LightSource"),
+	"SensingMethod":    exifProvenance(ifdPathExif, "SensingMethod"),
+	"CustomRendered":   exifProvenance(ifdPathExif, "CustomRendered"),
+	"SceneType":        exifProvenance(ifdPathExif, "SceneType"),
+
+	"EV100":                 derivedProvenance,
+	"HasEV100":              derivedProvenance,
+	"CropFactor":            derivedProvenance,
+	"FocalLength35mm":       derivedProvenance,
+	"AngleOfViewHorizontal": derivedProvenance,
+	"AngleOfViewVertical":   derivedProvenance,
+	"AngleOfViewDiagonal":   derivedProvenance,
+}
+
+// completeProvenance completes the provenance of the fields read through
+// their exif struct tags with the fields set apart, flagging the values
+// changed since they were read. Fields left empty have no provenance.
+func completeProvenance(provenance map[string]FieldProvenance, read, final ImageData) {
+	r := reflect.ValueOf(read)
+	f := reflect.ValueOf(final)
+	t := f.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		if !fieldPresent(final, name) {
+			delete(provenance, name)
+			continue
+		}
+
+		if p, ok := provenance[name]; ok {
+			if !reflect.DeepEqual(r.Field(i).Interface(), f.Field(i).Interface()) {
+				p.Adjusted = true
+				provenance[name] = p
+			}
+			continue
+		}
+		if p, ok := specialFieldProvenances[name]; ok {
+			provenance[name] = p
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package media_image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Provenance(t *testing.T) {
+	t.Log("Testing the provenance of the image data fields")
+
+	t.Run("exif", func(t *testing.T) {
+		i := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+
+		// The first tag found of the exif struct tag is reported
+		assert.Equal(t, FieldProvenance{Source: SourceExif, Tag: "Model", IfdPath: ifdPathRoot}, i.Provenance["CameraModel"])
+		assert.Equal(t, FieldProvenance{Source: SourceExif, Tag: "PixelXDimension", IfdPath: ifdPathExif}, i.Provenance["ImageWidth"])
+		assert.Equal(t, "exif:IFD/Exif/PixelXDimension", i.Provenance["ImageWidth"].String())
+
+		// Fields set apart from the struct tags
+		assert.Equal(t, FieldProvenance{Source: SourceExif, Tag: "GPSLatitude", IfdPath: ifdPathGps}, i.Provenance["GPSLatitude"])
+		assert.Equal(t, FieldProvenance{Source: SourceExif, Tag: "Flash", IfdPath: ifdPathExif}, i.Provenance["Flash"])
+		assert.Equal(t, SourceDerived, i.Provenance["GPSTimeZone"].Source)
+		assert.Equal(t, SourceDerived, i.Provenance["FocalLength35mm"].Source)
+
+		// Dates are moved to the time zone of the GPS position
+		assert.True(t, i.Provenance["DateTimeOriginal"].Adjusted)
+		assert.Equal(t, "exif:IFD/Exif/DateTimeOriginal (adjusted)", i.Provenance["DateTimeOriginal"].String())
+
+		// Empty fields have no provenance
+		_, ok := i.Provenance["Artist"]
+		assert.False(t, ok)
+		_, ok = i.Provenance["Drone"]
+		assert.False(t, ok)
+	})
+
+	t.Run("without exif", func(t *testing.T) {
+		i, err := NewImageInfo("samples/gif/sunflower-plants.gif")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, SourceDecoder, i.Provenance["ImageWidth"].Source)
+		assert.Equal(t, SourceFileSystem, i.Provenance["DateTime"].Source)
+	})
+
+	t.Run("write", func(t *testing.T) {
+		i := loadImage(t, copySample(t, "samples/jpg/gps/gps-1.jpg"))
+		data := i.ImageData
+		data.Artist = "Jane Doe"
+		data.Software = ""
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, FieldProvenance{Source: SourceExif, Tag: "Artist", IfdPath: "IFD"}, i.Provenance["Artist"])
+		_, ok := i.Provenance["Software"]
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,238 @@
+package media_image
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/rtree"
+)
+
+// maxPlaceDistance is the distance, in meters, beyond which a location is
+// considered too far from any city to be placed. Locations within the borders
+// of a country are placed in it anyway, without city.
+const maxPlaceDistance = 100_000.0
+
+//go:generate go run geodata/generate.go ${CITIES_JSON_DIR} /usr/share/zoneinfo/iso3166.tab /usr/share/zoneinfo/zone.tab geodata/places.tsv.gz
+
+// placesData is the embedded places dataset, see geodata/README.md.
+//
+//go:embed geodata/places.tsv.gz
+var placesData []byte
+
+// Place describes the surroundings of a location.
+type Place struct {
+	CountryCode string // ISO 3166-1 alpha-2 code, e.g. "FR"
+	CountryName string
+	Region      string // First-level administrative division, e.g. "Île-de-France"
+	City        string // Nearest city of the country
+
+	// CityDistance is the distance, in meters, to the center of the city.
+	CityDistance float64
+}
+
+// geoCity is a city of the places dataset.
+type geoCity struct {
+	name      string
+	latitude  float64
+	longitude float64
+	country   string
+	region    string
+}
+
+// ReverseGeocoder finds the place of a location from the embedded dataset,
+// without any network access. The country is the one whose borders hold the
+// location, from the boundaries of the time zones, each of a single country.
+type ReverseGeocoder struct {
+	cities    rtree.RTreeG[*geoCity]
+	countries map[string]string
+	regions   map[string]string
+	zones     map[string]string // Country code by time zone
+}
+
+var (
+	// reverseGeocoder is shared by the parser, loaded on first use
+	reverseGeocoder     *ReverseGeocoder
+	reverseGeocoderErr  error
+	reverseGeocoderOnce sync.Once
+)
+
+// NewReverseGeocoder returns the reverse geocoder. The dataset is loaded once
+// and shared by every caller.
+func NewReverseGeocoder() (*ReverseGeocoder, error) {
+	reverseGeocoderOnce.Do(func() {
+		reverseGeocoder, reverseGeocoderErr = loadReverseGeocoder(placesData)
+	})
+	return reverseGeocoder, reverseGeocoderErr
+}
+
+// loadReverseGeocoder parses a places dataset.
+func loadReverseGeocoder(data []byte) (*ReverseGeocoder, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid places dataset: %w", err)
+	}
+	defer zr.Close()
+
+	g := &ReverseGeocoder{
+		countries: make(map[string]string),
+		regions:   make(map[string]string),
+		zones:     make(map[string]string),
+	}
+
+	scanner := bufio.NewScanner(zr)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		switch {
+		case fields[0] == "n" && len(fields) == 3:
+			g.countries[fields[1]] = fields[2]
+		case fields[0] == "r" && len(fields) == 3:
+			g.regions[fields[1]] = fields[2]
+		case fields[0] == "z" && len(fields) == 3:
+			g.zones[fields[1]] = fields[2]
+		case fields[0] == "c" && len(fields) == 6:
+			latitude, errLat := strconv.ParseFloat(fields[2], 64)
+			longitude, errLon := strconv.ParseFloat(fields[3], 64)
+			if errLat != nil || errLon != nil {
+				return nil, fmt.Errorf("invalid places dataset: bad coordinates at line %d", line)
+			}
+			city := &geoCity{name: fields[1], latitude: latitude, longitude: longitude, country: fields[4], region: fields[5]}
+			point := [2]float64{longitude, latitude}
+			g.cities.Insert(point, point, city)
+		default:
+			return nil, fmt.Errorf("invalid places dataset: bad record at line %d", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid places dataset: %w", err)
+	}
+
+	return g, nil
+}
+
+// Lookup returns the place of a location: the country whose borders hold it,
+// and the nearest city of that country along with its region. Locations more
+// than 100 km away from any city of the country are placed in the country
+// only. Locations outside any country, e.g. at sea, are placed by the nearest
+// city within 100 km, or not placed.
+func (g *ReverseGeocoder) Lookup(latitude, longitude float64) (Place, bool) {
+	if math.IsNaN(latitude) || math.IsNaN(longitude) || math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return Place{}, false
+	}
+
+	country := g.country(latitude, longitude)
+
+	var nearest *geoCity
+	var distance float64
+	g.cities.Nearby(
+		func(min, max [2]float64, _ *geoCity, _ bool) float64 {
+			return boxDistance(latitude, longitude, min, max)
+		},
+		func(_, _ [2]float64, city *geoCity, dist float64) bool {
+			if dist > maxPlaceDistance {
+				return false
+			}
+			if country != "" && city.country != country {
+				return true
+			}
+			nearest, distance = city, dist
+			return false
+		},
+	)
+
+	switch {
+	case nearest != nil:
+		return Place{
+			CountryCode:  nearest.country,
+			CountryName:  g.countries[nearest.country],
+			Region:       g.regions[nearest.country+"."+nearest.region],
+			City:         nearest.name,
+			CityDistance: distance,
+		}, true
+	case country != "":
+		return Place{CountryCode: country, CountryName: g.countries[country]}, true
+	}
+	return Place{}, false
+}
+
+// country returns the code of the country whose borders hold a location, from
+// its time zone, empty outside any country or without time zone finder.
+func (g *ReverseGeocoder) country(latitude, longitude float64) string {
+	if tzFinder == nil {
+		return ""
+	}
+	return g.zones[tzFinder.GetTimezoneName(longitude, latitude)]
+}
+
+// boxDistance returns the great-circle distance, in meters, from a location
+// to the closest point of a longitude/latitude box.
+func boxDistance(latitude, longitude float64, min, max [2]float64) float64 {
+	// Longitude difference to the closest edge, zero when inside the box
+	var dLon float64
+	if longitude < min[0] || longitude > max[0] {
+		dLon = math.Min(longitudeDelta(longitude, min[0]), longitudeDelta(longitude, max[0]))
+	}
+	closestLon := longitude
+	if dLon != 0 {
+		closestLon = min[0]
+		if longitudeDelta(longitude, max[0]) < longitudeDelta(longitude, min[0]) {
+			closestLon = max[0]
+		}
+	}
+
+	// Along a meridian, the distance only decreases then increases with the
+	// latitude, so clamping the closest latitude of the whole meridian gives
+	// the closest latitude of the edge
+	closestLat := latitude
+	if dLon >= 90 {
+		closestLat = math.Copysign(90, latitude)
+	} else if dLon != 0 {
+		phi := latitude * math.Pi / 180
+		closestLat = math.Atan(math.Tan(phi)/math.Cos(dLon*math.Pi/180)) * 180 / math.Pi
+	}
+	closestLat = math.Max(min[1], math.Min(max[1], closestLat))
+
+	return haversineDistance(latitude, longitude, closestLat, closestLon)
+}
+
+// longitudeDelta returns the absolute difference between two longitudes,
+// going the short way around.
+func longitudeDelta(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// setGPSPlace sets the place fields of the image data from its coordinates.
+// It fails when the reverse geocoder cannot be loaded.
+func setGPSPlace(imageData *ImageData) error {
+	imageData.GPSCountryCode = ""
+	imageData.GPSCountryName = ""
+	imageData.GPSRegion = ""
+	imageData.GPSCity = ""
+	imageData.GPSCityDistance = 0
+	if imageData.GPSLatitude == 0 && imageData.GPSLongitude == 0 {
+		return nil
+	}
+
+	geocoder, err := NewReverseGeocoder()
+	if err != nil {
+		return fmt.Errorf("failed to initialize reverse geocoder: %w", err)
+	}
+	if place, ok := geocoder.Lookup(imageData.GPSLatitude, imageData.GPSLongitude); ok {
+		imageData.GPSCountryCode = place.CountryCode
+		imageData.GPSCountryName = place.CountryName
+		imageData.GPSRegion = place.Region
+		imageData.GPSCity = place.City
+		imageData.GPSCityDistance = place.CityDistance
+	}
+	return nil
+}
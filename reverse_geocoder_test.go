@@ -0,0 +1,100 @@
+package media_image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ReverseGeocoder(t *testing.T) {
+	t.Log("Testing reverse geocoder")
+
+	geocoder, err := NewReverseGeocoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("lookup", func(t *testing.T) {
+		place, ok := geocoder.Lookup(-33.8568, 151.2153)
+		assert.True(t, ok)
+		assert.Equal(t, "AU", place.CountryCode)
+		assert.Equal(t, "Australia", place.CountryName)
+		assert.Equal(t, "New South Wales", place.Region)
+		assert.Equal(t, "The Rocks", place.City)
+		assert.InDelta(t, 716, place.CityDistance, 1)
+
+		place, ok = geocoder.Lookup(51.4779, -0.0015)
+		assert.True(t, ok)
+		assert.Equal(t, "United Kingdom", place.CountryName)
+		assert.Equal(t, "England", place.Region)
+	})
+
+	t.Run("antimeridian", func(t *testing.T) {
+		place, ok := geocoder.Lookup(-16.78, 179.99)
+		assert.True(t, ok)
+		assert.Equal(t, "FJ", place.CountryCode)
+	})
+
+	t.Run("borders", func(t *testing.T) {
+		// Ristolas, in France, is closer to Italian cities than to French ones
+		place, ok := geocoder.Lookup(44.7722, 6.9436)
+		assert.True(t, ok)
+		assert.Equal(t, "FR", place.CountryCode)
+		assert.Equal(t, "Provence-Alpes-Côte d'Azur", place.Region)
+		assert.Equal(t, "Guillestre", place.City)
+
+		// Far from any city, the location is placed in its country only
+		place, ok = geocoder.Lookup(72, -40)
+		assert.True(t, ok)
+		assert.Equal(t, Place{CountryCode: "GL", CountryName: "Greenland"}, place)
+	})
+
+	t.Run("too far", func(t *testing.T) {
+		_, ok := geocoder.Lookup(0, -30)
+		assert.False(t, ok)
+		_, ok = geocoder.Lookup(91, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("image", func(t *testing.T) {
+		i := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+		assert.Equal(t, "IT", i.ImageData.GPSCountryCode)
+		assert.Equal(t, "Italy", i.ImageData.GPSCountryName)
+		assert.Equal(t, "Tuscany", i.ImageData.GPSRegion)
+		assert.Equal(t, "Arezzo", i.ImageData.GPSCity)
+		assert.InDelta(t, 615, i.ImageData.GPSCityDistance, 1)
+
+		i = loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		assert.Empty(t, i.ImageData.GPSCountryCode)
+	})
+
+	t.Run("write", func(t *testing.T) {
+		i := loadImage(t, copySample(t, "samples/jpg/gps/gps-1.jpg"))
+		data := i.ImageData
+		data.GPSLatitude = 48.858222
+		data.GPSLongitude = 2.2945
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		// The values derived from the position follow it
+		assert.Equal(t, "FR", i.ImageData.GPSCountryCode)
+		assert.NotEqual(t, "Arezzo", i.ImageData.GPSCity)
+		assert.Equal(t, "Europe/Paris", i.ImageData.GPSTimeZone)
+		assert.Equal(t, EncodeGeohash(48.858222, 2.2945, GeohashPrecision), i.ImageData.GPSGeohash)
+		if assert.NotNil(t, i.ImageData.GPS) {
+			assert.InDelta(t, 48.858222, i.ImageData.GPS.Latitude, 1e-6)
+			assert.InDelta(t, 2.2945, i.ImageData.GPS.Longitude, 1e-6)
+		}
+	})
+
+	t.Run("box distance", func(t *testing.T) {
+		// Inside the box
+		assert.Zero(t, boxDistance(10, 20, [2]float64{19, 9}, [2]float64{21, 11}))
+
+		// Closest point of an edge at high latitude lies poleward
+		d := boxDistance(60, 0, [2]float64{10, -80}, [2]float64{20, 80})
+		assert.Less(t, d, haversineDistance(60, 0, 60, 10))
+		assert.InDelta(t, haversineDistance(60, 0, 60.6, 10), d, 1000)
+	})
+}
@@ -0,0 +1,438 @@
+package media_image
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/dsoprea/go-exif/v3"
+	jpegstructure "github.com/dsoprea/go-jpeg-image-structure/v2"
+	pngstructure "github.com/dsoprea/go-png-image-structure/v2"
+)
+
+// ScrubPolicy selects the metadata removed by a Scrubber.
+type ScrubPolicy string
+
+// List of scrub policies.
+const (
+	// ScrubRemoveAll removes every metadata but the orientation and the
+	// colour information needed to render the image.
+	ScrubRemoveAll ScrubPolicy = "remove-all"
+
+	// ScrubRemoveLocation removes the GPS data and the location properties
+	// of XMP.
+	ScrubRemoveLocation ScrubPolicy = "remove-location"
+
+	// ScrubRemovePersonal removes the names, serial numbers, comments and
+	// maker notes identifying the owner or the device, but for the IPTC data.
+	ScrubRemovePersonal ScrubPolicy = "remove-personal"
+
+	// ScrubKeepCopyright removes everything ScrubRemoveAll does but the
+	// copyright notice and its author, in EXIF and XMP.
+	ScrubKeepCopyright ScrubPolicy = "keep-copyright"
+)
+
+// ScrubPolicies is the list of supported scrub policies.
+var ScrubPolicies = []ScrubPolicy{
+	ScrubRemoveAll,
+	ScrubRemoveLocation,
+	ScrubRemovePersonal,
+	ScrubKeepCopyright,
+}
+
+// Tags kept by ScrubRemoveAll, needed to display the image correctly.
+var scrubKeptTags = map[string][]uint16{
+	ifdPathRoot: {
+		0x0112, // Orientation
+		0x8773, // InterColorProfile
+	},
+	ifdPathExif: {
+		0xa001, // ColorSpace
+		0xa500, // Gamma
+	},
+	ifdPathIop: {
+		0x0001, // InteroperabilityIndex
+		0x0002, // InteroperabilityVersion
+	},
+}
+
+// Tags kept by ScrubKeepCopyright on top of scrubKeptTags.
+var scrubCopyrightTags = map[string][]uint16{
+	ifdPathRoot: {
+		0x013b, // Artist
+		0x8298, // Copyright
+	},
+}
+
+// Tags describing the image data of a TIFF file, which cannot be removed.
+var scrubTiffStructureTags = map[string][]uint16{
+	ifdPathRoot: {
+		0x00fe, 0x00ff, // NewSubfileType, SubfileType
+		0x0100, 0x0101, 0x0102, 0x0103, // ImageWidth, ImageLength, BitsPerSample, Compression
+		0x0106, 0x010a, // PhotometricInterpretation, FillOrder
+		0x0111, 0x0115, 0x0116, 0x0117, // StripOffsets, SamplesPerPixel, RowsPerStrip, StripByteCounts
+		0x011a, 0x011b, 0x011c, // XResolution, YResolution, PlanarConfiguration
+		0x0120, 0x0121, 0x0128, 0x012d, // FreeOffsets, FreeByteCounts, ResolutionUnit, TransferFunction
+		0x013d, 0x013e, 0x013f, 0x0140, // Predictor, WhitePoint, PrimaryChromaticities, ColorMap
+		0x0142, 0x0143, 0x0144, 0x0145, // TileWidth, TileLength, TileOffsets, TileByteCounts
+		0x0152, 0x0153, 0x015b, // ExtraSamples, SampleFormat, JPEGTables
+		0x0211, 0x0212, 0x0213, 0x0214, // YCbCrCoefficients, YCbCrSubSampling, YCbCrPositioning, ReferenceBlackWhite
+	},
+}
+
+// Tags removed by ScrubRemovePersonal.
+var scrubPersonalTags = map[string][]uint16{
+	ifdPathRoot: {
+		0x013b, // Artist
+		0x013c, // HostComputer
+		0x9c9c, // XPComment
+		0x9c9d, // XPAuthor
+		0xc62f, // CameraSerialNumber
+	},
+	ifdPathExif: {
+		0x927c, // MakerNote
+		0x9286, // UserComment
+		0xa420, // ImageUniqueID
+		0xa430, // CameraOwnerName
+		0xa431, // BodySerialNumber
+		0xa435, // LensSerialNumber
+	},
+}
+
+// XMP properties removed by ScrubRemoveLocation.
+var scrubLocationXmpProperties = []string{
+	"exif:GPS*",
+	"drone-dji:Gps*",
+	"photoshop:City",
+	"photoshop:State",
+	"photoshop:Country",
+	"Iptc4xmpCore:Location",
+	"Iptc4xmpCore:CountryCode",
+	"Iptc4xmpExt:LocationCreated",
+	"Iptc4xmpExt:LocationShown",
+}
+
+// XMP properties kept by ScrubKeepCopyright.
+var scrubCopyrightXmpProperties = []string{
+	"dc:rights",
+	"dc:creator",
+	"xmpRights:*",
+}
+
+// XMP properties removed by ScrubRemovePersonal.
+var scrubPersonalXmpProperties = []string{
+	"dc:creator",
+	"tiff:Artist",
+	"exif:UserComment",
+	"aux:SerialNumber",
+	"aux:LensSerialNumber",
+	"aux:OwnerName",
+	"exifEX:BodySerialNumber",
+	"exifEX:LensSerialNumber",
+	"exifEX:CameraOwnerName",
+	"exif:ImageUniqueID",
+	"photoshop:AuthorsPosition",
+	"photoshop:CaptionWriter",
+	"Iptc4xmpCore:CreatorContactInfo",
+}
+
+// ScrubReport describes the metadata removed from an image.
+type ScrubReport struct {
+	Image  *ImageInfo
+	Policy ScrubPolicy
+
+	// Removed lists the removed tags (e.g. "IFD/GPSInfo/GPSLatitude"), XMP
+	// properties (e.g. "XMP exif:GPSLatitude") and blocks (e.g. "IPTC").
+	Removed []string
+
+	// Remaining lists the sensitive tags and XMP properties still found in
+	// the file after scrubbing. It is empty when the scrubbing succeeded.
+	Remaining []string
+}
+
+// Scrubber removes sensitive metadata from images before publishing them.
+type Scrubber struct {
+	policy ScrubPolicy
+	writer *ExifWriter
+}
+
+// NewScrubber creates a new Scrubber applying the policy.
+func NewScrubber(policy ScrubPolicy) (*Scrubber, error) {
+	for _, p := range ScrubPolicies {
+		if p == policy {
+			return &Scrubber{policy: policy, writer: NewExifWriter()}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown scrub policy: %s", policy)
+}
+
+// Scrub removes the metadata selected by the policy from the image file,
+// then parses the file again to check that no sensitive tag remains.
+func (s *Scrubber) Scrub(i *ImageInfo) (*ScrubReport, error) {
+	report := &ScrubReport{Image: i, Policy: s.policy}
+
+	// BMP files have no metadata block to scrub, they are only verified
+	if i.FileType != ImageBmp {
+		edit := metadataEdit{
+			xmp:       func(packet []byte) ([]byte, error) { return s.scrubXmp(packet, report) },
+			stripText: s.policy == ScrubRemoveAll || s.policy == ScrubKeepCopyright,
+			stripIptc: s.policy == ScrubRemoveAll || s.policy == ScrubKeepCopyright,
+			removed:   func(block string) { report.Removed = append(report.Removed, block) },
+		}
+		if i.FileType != ImageGif {
+			edit.exif = func(editor *exifEditor) error { return s.scrubExif(editor, i.FileType == ImageTiff, report) }
+		}
+
+		if err := s.writer.update(i.path(), i.FileType, edit); err != nil {
+			return report, err
+		}
+	}
+
+	if err := s.verify(i, report); err != nil {
+		return report, err
+	}
+
+	// Refresh the image data from the scrubbed file
+	if _, err := i.Exif(); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// scrubExif removes the tags selected by the policy.
+func (s *Scrubber) scrubExif(editor *exifEditor, tiff bool, report *ScrubReport) error {
+	// Thumbnails carry their own copy of the metadata
+	if !tiff && (s.policy == ScrubRemoveAll || s.policy == ScrubKeepCopyright) {
+		dropped, err := editor.dropThumbnail()
+		if err != nil {
+			return err
+		}
+		if dropped {
+			report.Removed = append(report.Removed, "IFD1 (thumbnail)")
+		}
+	}
+
+	removed, err := editor.deleteWhere(func(ifdPath string, tagId uint16) bool {
+		return s.removes(ifdPath, tagId, tiff)
+	})
+	report.Removed = append(report.Removed, removed...)
+	return err
+}
+
+// scrubXmp removes the XMP properties selected by the policy, or the whole
+// packet when the policy removes all metadata. The copyright properties are
+// moved to a new packet when the policy keeps them.
+func (s *Scrubber) scrubXmp(packet []byte, report *ScrubReport) ([]byte, error) {
+	var properties []string
+	switch s.policy {
+	case ScrubRemoveAll:
+		report.Removed = append(report.Removed, "XMP packet")
+		return nil, nil
+	case ScrubKeepCopyright:
+		kept := copyrightXmpPacket(packet)
+		if kept == nil {
+			report.Removed = append(report.Removed, "XMP packet")
+		} else if !bytes.Equal(kept, packet) {
+			report.Removed = append(report.Removed, "XMP properties other than the copyright")
+		}
+		return kept, nil
+	case ScrubRemoveLocation:
+		properties = scrubLocationXmpProperties
+	case ScrubRemovePersonal:
+		properties = scrubPersonalXmpProperties
+	}
+
+	for _, property := range properties {
+		var removed []string
+		packet, removed = removeXmpProperty(packet, property)
+		for _, name := range removed {
+			report.Removed = append(report.Removed, "XMP "+name)
+		}
+	}
+	return packet, nil
+}
+
+// copyrightXmpPacket returns a packet holding only the copyright properties
+// of an XMP packet, or nil when it has none.
+func copyrightXmpPacket(packet []byte) []byte {
+	var attributes, elements []byte
+	for _, property := range scrubCopyrightXmpProperties {
+		_, removed := removeXmpProperty(packet, property)
+		if len(removed) == 0 {
+			continue
+		}
+		for _, pattern := range getXmpRemovalPatterns(property) {
+			for _, match := range pattern.FindAll(packet, -1) {
+				// Attributes are matched along with the space before them
+				if match[0] == '<' {
+					elements = append(elements, match...)
+				} else {
+					attributes = append(attributes, match...)
+				}
+			}
+		}
+	}
+	if attributes == nil && elements == nil {
+		return nil
+	}
+
+	b := new(bytes.Buffer)
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">`)
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xmpRights="http://ns.adobe.com/xap/1.0/rights/"`)
+	b.Write(attributes)
+	b.WriteString(">")
+	b.Write(elements)
+	b.WriteString(`</rdf:Description></rdf:RDF></x:xmpmeta>`)
+	return b.Bytes()
+}
+
+// removes checks if the policy removes the tag.
+func (s *Scrubber) removes(ifdPath string, tagId uint16, tiff bool) bool {
+	switch s.policy {
+	case ScrubRemoveLocation:
+		return ifdPath == ifdPathGps
+
+	case ScrubRemovePersonal:
+		return hasScrubTag(scrubPersonalTags, ifdPath, tagId)
+
+	case ScrubKeepCopyright:
+		if hasScrubTag(scrubCopyrightTags, ifdPath, tagId) {
+			return false
+		}
+	}
+
+	if tiff && hasScrubTag(scrubTiffStructureTags, ifdPath, tagId) {
+		return false
+	}
+	return !hasScrubTag(scrubKeptTags, ifdPath, tagId)
+}
+
+// verify parses the metadata of the scrubbed file again, the EXIF data, its
+// copies and the XMP packets, and reports the sensitive tags and properties
+// still present.
+func (s *Scrubber) verify(i *ImageInfo, report *ScrubReport) error {
+	tiff := i.FileType == ImageTiff
+	rawExif, err := NewExifParser().Parse(i.path(), i.FileType)
+	switch {
+	case errors.Is(err, exif.ErrNoExif):
+	case err != nil:
+		return fmt.Errorf("failed to verify scrubbed file: %w", err)
+	default:
+		if err := s.verifyExif(rawExif, "", tiff, report); err != nil {
+			return fmt.Errorf("failed to verify scrubbed file: %w", err)
+		}
+	}
+
+	metadata, err := readContainerMetadata(i.path(), i.FileType, i.options.Limits)
+	if err != nil {
+		return fmt.Errorf("failed to verify scrubbed file: %w", err)
+	}
+	for _, packet := range metadata.xmp {
+		s.verifyXmp(packet, "XMP", report)
+	}
+
+	switch i.FileType {
+	case ImageJpeg:
+		mediaContext, err := jpegstructure.NewJpegMediaParser().ParseFile(i.path())
+		if err != nil {
+			return fmt.Errorf("failed to verify scrubbed file: %w", err)
+		}
+		extensions, err := jpegXmpExtensions(mediaContext.(*jpegstructure.SegmentList).Segments())
+		if err != nil {
+			return fmt.Errorf("failed to verify scrubbed file: %w", err)
+		}
+		for _, extension := range extensions {
+			s.verifyXmp(extension.packet, "Extended XMP", report)
+		}
+
+	case ImagePng:
+		mediaContext, err := pngstructure.NewPngMediaParser().ParseFile(i.path())
+		if err != nil {
+			return fmt.Errorf("failed to verify scrubbed file: %w", err)
+		}
+		for _, chunk := range mediaContext.(*pngstructure.ChunkSlice).Chunks() {
+			if !isPngRawExifProfile(chunk) {
+				continue
+			}
+			rawExif, _, err := decodePngRawProfile(chunk)
+			if err == nil {
+				err = s.verifyExif(rawExif, "PNG raw profile ", false, report)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to verify scrubbed file: %w", err)
+			}
+		}
+	}
+
+	if len(report.Remaining) > 0 {
+		return fmt.Errorf("sensitive metadata remains after scrubbing: %v", report.Remaining)
+	}
+	return nil
+}
+
+// verifyExif reports the sensitive tags of EXIF data, prefixing their path
+// with the label of the block.
+func (s *Scrubber) verifyExif(rawExif []byte, label string, tiff bool, report *ScrubReport) error {
+	_, index, err := exif.Collect(exifIfdMapping, exifTagIndex, rawExif)
+	if err != nil {
+		return err
+	}
+
+	dropsThumbnail := !tiff && (s.policy == ScrubRemoveAll || s.policy == ScrubKeepCopyright)
+	for _, ifd := range index.Ifds {
+		ii := ifd.IfdIdentity()
+
+		// Fully-qualified paths differ from unindexed ones past IFD0
+		thumbnail := ii.String() != ii.UnindexedString()
+		for _, ite := range ifd.Entries() {
+			if ite.ChildIfdPath() != "" {
+				continue
+			}
+			if s.removes(ii.UnindexedString(), ite.TagId(), tiff) || (thumbnail && dropsThumbnail) {
+				report.Remaining = append(report.Remaining, label+ii.String()+"/"+exifTagName(ii, ite.TagId()))
+			}
+		}
+	}
+	return nil
+}
+
+// verifyXmp reports the sensitive properties of an XMP packet, or the packet
+// itself when the policy removes all metadata or it holds more than the
+// copyright properties kept by the policy.
+func (s *Scrubber) verifyXmp(packet []byte, label string, report *ScrubReport) {
+	var properties []string
+	switch s.policy {
+	case ScrubRemoveAll:
+		report.Remaining = append(report.Remaining, label+" packet")
+		return
+	case ScrubKeepCopyright:
+		// The packet read from the container may be wrapped or padded
+		kept := copyrightXmpPacket(packet)
+		if kept == nil || !bytes.Contains(packet, kept) || bytes.Count(packet, []byte("<x:xmpmeta")) != 1 {
+			report.Remaining = append(report.Remaining, label+" packet")
+		}
+		return
+	case ScrubRemoveLocation:
+		properties = scrubLocationXmpProperties
+	case ScrubRemovePersonal:
+		properties = scrubPersonalXmpProperties
+	}
+
+	for _, property := range properties {
+		_, found := removeXmpProperty(packet, property)
+		for _, name := range found {
+			report.Remaining = append(report.Remaining, label+" "+name)
+		}
+	}
+}
+
+// hasScrubTag checks if the tag is listed for the IFD.
+func hasScrubTag(list map[string][]uint16, ifdPath string, tagId uint16) bool {
+	for _, id := range list[ifdPath] {
+		if id == tagId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,405 @@
+package media_image
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jpegstructure "github.com/dsoprea/go-jpeg-image-structure/v2"
+	pngstructure "github.com/dsoprea/go-png-image-structure/v2"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// jpegSegments parses the segments of a JPEG file.
+func jpegSegments(t *testing.T, path string) *jpegstructure.SegmentList {
+	t.Helper()
+
+	mediaContext, err := jpegstructure.NewJpegMediaParser().ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mediaContext.(*jpegstructure.SegmentList)
+}
+
+// writeJpegSegments writes the segments to a JPEG file.
+func writeJpegSegments(t *testing.T, path string, segments []*jpegstructure.Segment) {
+	t.Helper()
+
+	b := new(bytes.Buffer)
+	if err := jpegstructure.NewSegmentList(segments).Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pngChunks parses the chunks of a PNG file.
+func pngChunks(t *testing.T, path string) *pngstructure.ChunkSlice {
+	t.Helper()
+
+	mediaContext, err := pngstructure.NewPngMediaParser().ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mediaContext.(*pngstructure.ChunkSlice)
+}
+
+// writePngChunks writes the chunks to a PNG file.
+func writePngChunks(t *testing.T, path string, chunks []*pngstructure.Chunk) {
+	t.Helper()
+
+	b := new(bytes.Buffer)
+	if err := pngstructure.NewChunkSlice(chunks).WriteTo(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Scrubber(t *testing.T) {
+	t.Log("Testing scrubber")
+
+	scrub := func(t *testing.T, policy ScrubPolicy, i *ImageInfo) *ScrubReport {
+		scrubber, err := NewScrubber(policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report, err := scrubber.Scrub(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Empty(t, report.Remaining)
+		return report
+	}
+
+	t.Run("remove-location", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		i := loadImage(t, path)
+		cameraModel := i.ImageData.CameraModel
+		assert.NotZero(t, i.ImageData.GPSLatitude)
+
+		report := scrub(t, ScrubRemoveLocation, i)
+		assert.Contains(t, report.Removed, "IFD/GPSInfo/GPSLatitude")
+
+		reloaded := loadImage(t, path)
+		assert.Zero(t, reloaded.ImageData.GPSLatitude)
+		assert.Zero(t, reloaded.ImageData.GPSLongitude)
+		assert.Equal(t, cameraModel, reloaded.ImageData.CameraModel)
+	})
+
+	t.Run("remove-personal", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		i := loadImage(t, path)
+		data := i.ImageData
+		data.Artist = "Jane Doe"
+		data.Copyright = "(c) Jane Doe"
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		report := scrub(t, ScrubRemovePersonal, i)
+		assert.Contains(t, report.Removed, "IFD/Artist")
+
+		reloaded := loadImage(t, path)
+		assert.Empty(t, reloaded.ImageData.Artist)
+		assert.Equal(t, "(c) Jane Doe", reloaded.ImageData.Copyright)
+		assert.Equal(t, data.CameraModel, reloaded.ImageData.CameraModel)
+	})
+
+	t.Run("remove-all", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		pixels := decodeImage(t, path)
+		i := loadImage(t, path)
+		data := i.ImageData
+		data.ImageOrientation = 6
+		data.Copyright = "(c) Jane Doe"
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		scrub(t, ScrubRemoveAll, i)
+
+		reloaded := loadImage(t, path)
+		assert.Equal(t, 6, reloaded.ImageData.ImageOrientation)
+		assert.Empty(t, reloaded.ImageData.CameraMake)
+		assert.Empty(t, reloaded.ImageData.Copyright)
+		assert.True(t, reloaded.ImageData.DateTimeOriginal.IsZero())
+		assert.Equal(t, pixels, decodeImage(t, path))
+	})
+
+	t.Run("keep-copyright", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		i := loadImage(t, path)
+		data := i.ImageData
+		data.Copyright = "(c) Jane Doe"
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		scrub(t, ScrubKeepCopyright, i)
+
+		reloaded := loadImage(t, path)
+		assert.Equal(t, "(c) Jane Doe", reloaded.ImageData.Copyright)
+		assert.Empty(t, reloaded.ImageData.CameraModel)
+	})
+
+	t.Run("keep-copyright xmp", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		segments := jpegSegments(t, path)
+		_, segment, err := segments.FindXmp()
+		if err != nil {
+			t.Fatal(err)
+		}
+		segment.Data = append(append([]byte{}, xmpJpegPrefix...), `<x:xmpmeta><rdf:RDF><rdf:Description exif:GPSLatitude="48,51.49N" xmpRights:Marked="True">`+
+			`<dc:rights><rdf:Alt><rdf:li xml:lang="x-default">(c) Jane Doe</rdf:li></rdf:Alt></dc:rights>`+
+			`<photoshop:City>Paris</photoshop:City></rdf:Description></rdf:RDF></x:xmpmeta>`...)
+		writeJpegSegments(t, path, segments.Segments())
+
+		report := scrub(t, ScrubKeepCopyright, loadImage(t, path))
+		assert.Contains(t, report.Removed, "XMP properties other than the copyright")
+
+		metadata, err := readContainerMetadata(path, ImageJpeg, DefaultLimits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, metadata.xmp, 1) {
+			packet := string(metadata.xmp[0])
+			assert.Contains(t, packet, `<rdf:li xml:lang="x-default">(c) Jane Doe</rdf:li>`)
+			assert.Contains(t, packet, `xmpRights:Marked="True"`)
+			assert.NotContains(t, packet, "GPSLatitude")
+			assert.NotContains(t, packet, "Paris")
+		}
+	})
+
+	t.Run("iptc", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		segment := iptcSegment(map[uint8]string{iptcDatasetCaptionAbstract: "Fjord"})
+		if err := os.WriteFile(path, append(append(append([]byte{}, data[:2]...), segment...), data[2:]...), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the policies removing all metadata remove the captions
+		for _, policy := range []ScrubPolicy{ScrubRemoveLocation, ScrubRemovePersonal} {
+			report := scrub(t, policy, loadImage(t, path))
+			assert.NotContains(t, report.Removed, "IPTC")
+			_, found, err := jpegSegments(t, path).FindIptc()
+			if assert.NoError(t, err) {
+				tags, err := found.Iptc()
+				if assert.NoError(t, err) {
+					caption, _ := iptcText(tags, iptcDatasetCaptionAbstract)
+					assert.Equal(t, "Fjord", caption)
+				}
+			}
+		}
+
+		report := scrub(t, ScrubKeepCopyright, loadImage(t, path))
+		assert.Contains(t, report.Removed, "IPTC")
+		_, _, err = jpegSegments(t, path).FindIptc()
+		assert.Error(t, err)
+	})
+
+	t.Run("file types", func(t *testing.T) {
+		paths := []string{
+			copySample(t, "samples/gif/sunflower-plants.gif"),
+			copySample(t, "samples/heic/netherlands.heic"),
+			copySample(t, "samples/heif/madrid.heif"),
+			copySample(t, "samples/jpg/gps/gps-1.jpg"),
+			copySample(t, "samples/webp/Nærøyfjorden.webp"),
+			copySample(t, "samples/webp/giphy.webp"),
+			writeTestImage(t, "test.bmp", func(b *bytes.Buffer, img image.Image) error { return bmp.Encode(b, img) }),
+			writeTestImage(t, "test.png", func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) }),
+			writeTestImage(t, "test.tiff", func(b *bytes.Buffer, img image.Image) error { return tiff.Encode(b, img, nil) }),
+		}
+
+		for _, path := range paths {
+			t.Run(filepath.Base(path), func(t *testing.T) {
+				i := loadImage(t, path)
+				if i.FileType != ImageBmp && i.FileType != ImageGif {
+					data := i.ImageData
+					data.Artist = "Jane Doe"
+					data.GPSLatitude = 48.858222
+					data.GPSLongitude = 2.2945
+					if err := i.WriteExif(data); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				scrub(t, ScrubRemoveAll, i)
+
+				reloaded := loadImage(t, path)
+				assert.Empty(t, reloaded.ImageData.Artist)
+				assert.Zero(t, reloaded.ImageData.GPSLatitude)
+
+				// The image must still decode
+				switch i.FileType {
+				case ImageBmp, ImageGif, ImageJpeg, ImagePng, ImageTiff:
+					decodeImage(t, path)
+				}
+			})
+		}
+	})
+
+	t.Run("extended xmp", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/gps/gps-1.jpg")
+		segments := jpegSegments(t, path)
+
+		// An extended packet over two segments, named by the main packet
+		extended := []byte(`<x:xmpmeta><rdf:Description exif:GPSLatitude="43,28.2N" xmp:Label="Red"/>` +
+			`<!--` + strings.Repeat("-", 70000) + `--></x:xmpmeta>`)
+		guid, extension := encodeXmpExtension(extended)
+		main := []byte(`<x:xmpmeta><rdf:Description xmpNote:HasExtendedXMP="` + guid + `"/></x:xmpmeta>`)
+		index, segment, err := segments.FindXmp()
+		if err != nil {
+			t.Fatal(err)
+		}
+		segment.Data = append(append([]byte{}, xmpJpegPrefix...), main...)
+		all := segments.Segments()
+		all = append(all[:index+1:index+1], append(extension, all[index+1:]...)...)
+		writeJpegSegments(t, path, all)
+
+		scrubber, _ := NewScrubber(ScrubRemoveLocation)
+		report := &ScrubReport{}
+		assert.Error(t, scrubber.verify(loadImage(t, path), report))
+		assert.Contains(t, report.Remaining, "Extended XMP exif:GPSLatitude")
+
+		report = scrub(t, ScrubRemoveLocation, loadImage(t, path))
+		assert.Contains(t, report.Removed, "XMP exif:GPSLatitude")
+		extensions, err := jpegXmpExtensions(jpegSegments(t, path).Segments())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, extensions, 1) {
+			assert.NotContains(t, string(extensions[0].packet), "GPSLatitude")
+			assert.Contains(t, string(extensions[0].packet), `xmp:Label="Red"`)
+			assert.Len(t, extensions[0].segments, 2)
+
+			_, segment, _ := jpegSegments(t, path).FindXmp()
+			value, _ := xmpPropertyValue(segment.Data, xmpExtensionProperty)
+			assert.Equal(t, fmt.Sprintf("%X", md5.Sum(extensions[0].packet)), value)
+		}
+
+		// Removing all metadata removes the extended packet and its segments
+		scrub(t, ScrubRemoveAll, loadImage(t, path))
+		extensions, _ = jpegXmpExtensions(jpegSegments(t, path).Segments())
+		assert.Empty(t, extensions)
+	})
+
+	t.Run("several xmp packets", func(t *testing.T) {
+		path := writeTestImage(t, "test.png", func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) })
+		addXmpPacket(t, path, ImagePng, []byte(`<x:xmpmeta><rdf:Description xmp:Rating="3"/></x:xmpmeta>`))
+		addXmpPacket(t, path, ImagePng, []byte(`<x:xmpmeta><rdf:Description exif:GPSLatitude="48,51.49N"/></x:xmpmeta>`))
+
+		scrubber, _ := NewScrubber(ScrubRemoveLocation)
+		report := &ScrubReport{}
+		assert.Error(t, scrubber.verify(loadImage(t, path), report))
+		assert.Equal(t, []string{"XMP exif:GPSLatitude"}, report.Remaining)
+	})
+
+	t.Run("png raw profile", func(t *testing.T) {
+		path := writeTestImage(t, "test.png", func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) })
+		i := loadImage(t, path)
+		data := i.ImageData
+		data.Artist = "Jane Doe"
+		data.GPSLatitude = 48.858222
+		data.GPSLongitude = 2.2945
+		if err := i.WriteExif(data); err != nil {
+			t.Fatal(err)
+		}
+
+		// ImageMagick keeps a copy of the EXIF data in a zTXt chunk
+		rawExif, err := NewExifParser().Parse(path, ImagePng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		profile, err := encodePngRawProfile("Raw profile type exif", webpExifPrefix, rawExif)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks := pngChunks(t, path)
+		all := chunks.Chunks()
+		all = append(all[:len(all)-1:len(all)-1], profile, all[len(all)-1])
+		writePngChunks(t, path, all)
+
+		scrubber, _ := NewScrubber(ScrubRemoveLocation)
+		report := &ScrubReport{}
+		assert.Error(t, scrubber.verify(loadImage(t, path), report))
+		assert.Contains(t, report.Remaining, "PNG raw profile IFD/GPSInfo/GPSLatitude")
+
+		scrub(t, ScrubRemoveLocation, loadImage(t, path))
+		var found bool
+		for _, chunk := range pngChunks(t, path).Chunks() {
+			if !isPngRawExifProfile(chunk) {
+				continue
+			}
+			found = true
+			rawExif, prefix, err := decodePngRawProfile(chunk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, webpExifPrefix, prefix)
+			entries, err := NewExifDataParser().Tags(rawExif)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.TagName)
+			}
+			assert.Contains(t, names, "Artist")
+			assert.NotContains(t, names, "GPSLatitude")
+		}
+		assert.True(t, found)
+	})
+
+	t.Run("xmp", func(t *testing.T) {
+		packet := []byte(`<rdf:Description exif:GPSLatitude="48,51.49N" exif:GPSLongitude='2,17.67E' xmp:Rating="3">` +
+			`<dc:creator><rdf:Seq><rdf:li>Jane Doe</rdf:li></rdf:Seq></dc:creator>` +
+			`<photoshop:City>Paris</photoshop:City></rdf:Description>`)
+
+		scrubber, err := NewScrubber(ScrubRemoveLocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := &ScrubReport{}
+		packet, err = scrubber.scrubXmp(packet, report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `<rdf:Description xmp:Rating="3">`+
+			`<dc:creator><rdf:Seq><rdf:li>Jane Doe</rdf:li></rdf:Seq></dc:creator></rdf:Description>`, string(packet))
+		assert.Equal(t, []string{"XMP exif:GPSLatitude", "XMP exif:GPSLongitude", "XMP photoshop:City"}, report.Removed)
+
+		kept, _ := NewScrubber(ScrubKeepCopyright)
+		copyright, _ := kept.scrubXmp(packet, report)
+		assert.Equal(t, `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">`+
+			`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xmpRights="http://ns.adobe.com/xap/1.0/rights/">`+
+			`<dc:creator><rdf:Seq><rdf:li>Jane Doe</rdf:li></rdf:Seq></dc:creator></rdf:Description></rdf:RDF></x:xmpmeta>`, string(copyright))
+		unchanged, _ := kept.scrubXmp(copyright, report)
+		assert.Equal(t, copyright, unchanged)
+
+		scrubber, _ = NewScrubber(ScrubRemovePersonal)
+		packet, _ = scrubber.scrubXmp(packet, report)
+		assert.Equal(t, `<rdf:Description xmp:Rating="3"></rdf:Description>`, string(packet))
+		packet, _ = kept.scrubXmp(packet, report)
+		assert.Nil(t, packet)
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		_, err := NewScrubber("remove-nothing")
+		assert.Error(t, err)
+	})
+}
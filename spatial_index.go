@@ -0,0 +1,126 @@
+package media_image
+
+import (
+	"math"
+
+	"github.com/tidwall/rtree"
+)
+
+// SpatialMatch is an image found by a spatial query.
+type SpatialMatch struct {
+	Image *ImageInfo
+
+	// Distance is the great-circle distance, in meters, from the queried location.
+	Distance float64
+}
+
+// SpatialIndex is an in-memory R-tree of located images, answering radius,
+// bounding box and nearest neighbour queries without scanning the collection.
+// Images are indexed at their position when inserted, and must be deleted
+// before their position changes.
+type SpatialIndex struct {
+	tree   rtree.RTreeG[*ImageInfo]
+	points map[*ImageInfo][2]float64
+}
+
+// NewSpatialIndex creates a new SpatialIndex holding the located images.
+func NewSpatialIndex(images []*ImageInfo) *SpatialIndex {
+	s := &SpatialIndex{points: make(map[*ImageInfo][2]float64)}
+	for _, image := range images {
+		s.Insert(image)
+	}
+	return s
+}
+
+// Insert adds an image to the index. Images without position, and images
+// already indexed, are ignored.
+func (s *SpatialIndex) Insert(image *ImageInfo) bool {
+	data := image.ImageData
+	if data.GPSLatitude == 0 && data.GPSLongitude == 0 {
+		return false
+	}
+	if _, ok := s.points[image]; ok {
+		return false
+	}
+
+	point := [2]float64{data.GPSLongitude, data.GPSLatitude}
+	s.tree.Insert(point, point, image)
+	s.points[image] = point
+	return true
+}
+
+// Delete removes an image from the index.
+func (s *SpatialIndex) Delete(image *ImageInfo) bool {
+	point, ok := s.points[image]
+	if !ok {
+		return false
+	}
+	s.tree.Delete(point, point, image)
+	delete(s.points, image)
+	return true
+}
+
+// Len returns the number of indexed images.
+func (s *SpatialIndex) Len() int {
+	return len(s.points)
+}
+
+// Within returns the images within radius meters of a location, nearest first.
+func (s *SpatialIndex) Within(latitude, longitude, radius float64) []SpatialMatch {
+	var matches []SpatialMatch
+	s.nearby(latitude, longitude, func(match SpatialMatch) bool {
+		if match.Distance > radius {
+			return false
+		}
+		matches = append(matches, match)
+		return true
+	})
+	return matches
+}
+
+// Nearest returns the n images nearest to a location, nearest first.
+func (s *SpatialIndex) Nearest(latitude, longitude float64, n int) []SpatialMatch {
+	var matches []SpatialMatch
+	if n <= 0 {
+		return matches
+	}
+	s.nearby(latitude, longitude, func(match SpatialMatch) bool {
+		matches = append(matches, match)
+		return len(matches) < n
+	})
+	return matches
+}
+
+// InBounds returns the images within a bounding box, west being greater than
+// east when the box crosses the antimeridian.
+func (s *SpatialIndex) InBounds(south, west, north, east float64) []*ImageInfo {
+	var images []*ImageInfo
+	iter := func(_, _ [2]float64, image *ImageInfo) bool {
+		images = append(images, image)
+		return true
+	}
+
+	if west <= east {
+		s.tree.Search([2]float64{west, south}, [2]float64{east, north}, iter)
+	} else {
+		s.tree.Search([2]float64{west, south}, [2]float64{180, north}, iter)
+		s.tree.Search([2]float64{-180, south}, [2]float64{east, north}, iter)
+	}
+	return images
+}
+
+// nearby walks the images by increasing distance from a location until iter
+// returns false.
+func (s *SpatialIndex) nearby(latitude, longitude float64, iter func(match SpatialMatch) bool) {
+	if math.IsNaN(latitude) || math.IsNaN(longitude) {
+		return
+	}
+	s.tree.Nearby(
+		func(min, max [2]float64, _ *ImageInfo, _ bool) float64 {
+			return boxDistance(latitude, longitude, min, max)
+		},
+		func(_, _ [2]float64, image *ImageInfo, distance float64) bool {
+			return iter(SpatialMatch{Image: image, Distance: distance})
+		},
+	)
+}
@@ -0,0 +1,64 @@
+package media_image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SpatialIndex(t *testing.T) {
+	t.Log("Testing spatial index")
+
+	image := func(latitude, longitude float64) *ImageInfo {
+		return &ImageInfo{ImageData: ImageData{GPSLatitude: latitude, GPSLongitude: longitude}}
+	}
+	eiffel := image(48.8584, 2.2945)
+	louvre := image(48.8606, 2.3376)
+	versailles := image(48.8049, 2.1204)
+	fiji := image(-16.8, 179.9)
+	samoa := image(-13.8, -171.8)
+	unlocated := image(0, 0)
+
+	index := NewSpatialIndex([]*ImageInfo{eiffel, louvre, versailles, fiji, samoa, unlocated})
+	assert.Equal(t, 5, index.Len())
+
+	images := func(matches []SpatialMatch) []*ImageInfo {
+		var images []*ImageInfo
+		for _, match := range matches {
+			images = append(images, match.Image)
+		}
+		return images
+	}
+
+	t.Run("within", func(t *testing.T) {
+		// From the Arc de Triomphe
+		matches := index.Within(48.8738, 2.2950, 5000)
+		assert.Equal(t, []*ImageInfo{eiffel, louvre}, images(matches))
+		assert.InDelta(t, 1716, matches[0].Distance, 5)
+
+		assert.Len(t, index.Within(48.8738, 2.2950, 20_000), 3)
+		assert.Empty(t, index.Within(0, 0, 20_000))
+	})
+
+	t.Run("nearest", func(t *testing.T) {
+		assert.Equal(t, []*ImageInfo{versailles, eiffel}, images(index.Nearest(48.80, 2.13, 2)))
+		assert.Empty(t, index.Nearest(48.80, 2.13, 0))
+
+		// Across the antimeridian
+		assert.Equal(t, []*ImageInfo{fiji}, images(index.Nearest(-16.8, -179.9, 1)))
+		assert.Len(t, index.Nearest(0, 0, 10), 5)
+	})
+
+	t.Run("bounds", func(t *testing.T) {
+		assert.ElementsMatch(t, []*ImageInfo{eiffel, louvre}, index.InBounds(48.85, 2.2, 48.9, 2.4))
+		assert.ElementsMatch(t, []*ImageInfo{fiji, samoa}, index.InBounds(-20, 170, -10, -170))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		index := NewSpatialIndex([]*ImageInfo{eiffel, louvre})
+		assert.False(t, index.Insert(eiffel))
+		assert.True(t, index.Delete(eiffel))
+		assert.False(t, index.Delete(eiffel))
+		assert.Equal(t, []*ImageInfo{louvre}, images(index.Nearest(48.8584, 2.2945, 5)))
+	})
+}
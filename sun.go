@@ -0,0 +1,220 @@
+package media_image
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// DaylightPhase classifies the light of a shot by the elevation of the sun.
+type DaylightPhase string
+
+// Daylight phases, from the darkest to the brightest.
+const (
+	DaylightNight      DaylightPhase = "night"       // Sun more than 6° below the horizon
+	DaylightBlueHour   DaylightPhase = "blue hour"   // Sun between 6° and 4° below the horizon
+	DaylightGoldenHour DaylightPhase = "golden hour" // Sun between 4° below and 6° above the horizon
+	DaylightDaytime    DaylightPhase = "daytime"     // Sun more than 6° above the horizon
+)
+
+// sunriseElevation is the elevation of the center of the sun at sunrise and
+// sunset, accounting for the refraction and the radius of the solar disc.
+const sunriseElevation = -0.833
+
+// SunInfo is the position of the sun at the capture of an image.
+type SunInfo struct {
+	Time time.Time // Capture instant
+
+	Elevation float64 // Degrees above the horizon, corrected for refraction
+	Azimuth   float64 // Degrees clockwise from north
+	Phase     DaylightPhase
+
+	// Sunrise and Sunset of the local day of the capture, zero when the sun
+	// does not rise or set that day, see PolarDay
+	Sunrise time.Time
+	Sunset  time.Time
+
+	// PolarDay is set when the sun stays above the horizon all day, and
+	// PolarNight when it stays below.
+	PolarDay   bool
+	PolarNight bool
+}
+
+// Sun computes the position of the sun at the capture of the image, from its
+// GPS position and its capture instant, along with the sunrise and sunset of
+// the day. Sunrise and sunset are given in the time zone of the position when
+// known.
+func (d ImageData) Sun() (SunInfo, error) {
+	if d.GPSLatitude == 0 && d.GPSLongitude == 0 {
+		return SunInfo{}, fmt.Errorf("no GPS position")
+	}
+	t, ok := d.CaptureInstant()
+	if !ok {
+		return SunInfo{}, fmt.Errorf("no capture instant known")
+	}
+
+	if d.GPSTimeZone != "" {
+		if loc, err := time.LoadLocation(d.GPSTimeZone); err == nil {
+			t = t.In(loc)
+		}
+	}
+	return SunAt(d.GPSLatitude, d.GPSLongitude, t), nil
+}
+
+// CaptureInstant resolves the instant the image was captured at from its
+// original date, sub-second precision and time offset. Without a time offset,
+// the GPS timestamp is used unless the validation flagged it.
+func (d ImageData) CaptureInstant() (time.Time, bool) {
+	t := d.DateTimeOriginal
+	if !t.IsZero() && t.Nanosecond() == 0 && d.SubSecOriginal != "" {
+		if fraction, err := strconv.ParseFloat("0."+d.SubSecOriginal, 64); err == nil {
+			t = t.Add(time.Duration(fraction * float64(time.Second)))
+		}
+	}
+	if t.IsZero() {
+		t = d.DateTimeDigitized
+	}
+
+	// Dates without a zone hold the wall clock of the camera
+	if !t.IsZero() && t.Location() != time.UTC {
+		return t, true
+	}
+	if offset, err := parseTimeOffset(d.TimeOffset); err == nil && !t.IsZero() {
+		return t.Add(-time.Duration(offset) * time.Second), true
+	}
+	if !d.GPSTimestamp.IsZero() && (d.GPS == nil || !d.GPS.HasIssue(GPSIssueTimestampMismatch)) {
+		return d.GPSTimestamp, true
+	}
+	return time.Time{}, false
+}
+
+// SunAt computes the position of the sun seen from a location at an instant,
+// along with the sunrise and sunset of the day of the instant in its location.
+func SunAt(latitude, longitude float64, t time.Time) SunInfo {
+	info := SunInfo{Time: t}
+	info.Elevation, info.Azimuth = sunPosition(latitude, longitude, t)
+
+	switch {
+	case info.Elevation < -6:
+		info.Phase = DaylightNight
+	case info.Elevation < -4:
+		info.Phase = DaylightBlueHour
+	case info.Elevation < 6:
+		info.Phase = DaylightGoldenHour
+	default:
+		info.Phase = DaylightDaytime
+	}
+
+	// Events of the local day, computed from the UTC midnight of its date
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	var rises, sets bool
+	info.Sunrise, rises = sunEvent(latitude, longitude, midnight, true)
+	info.Sunset, sets = sunEvent(latitude, longitude, midnight, false)
+	if !rises || !sets {
+		info.Sunrise, info.Sunset = time.Time{}, time.Time{}
+		noonElevation, _ := sunPosition(latitude, longitude, midnight.Add(12*time.Hour-time.Duration(longitude*4)*time.Minute))
+		info.PolarDay = noonElevation > sunriseElevation
+		info.PolarNight = !info.PolarDay
+	} else {
+		info.Sunrise, info.Sunset = info.Sunrise.In(t.Location()), info.Sunset.In(t.Location())
+	}
+
+	return info
+}
+
+// solarCoordinates returns the declination of the sun, in degrees, and the
+// equation of time, in minutes, at an instant, following the NOAA solar
+// calculator.
+func solarCoordinates(t time.Time) (declination, equationOfTime float64) {
+	julianDay := float64(t.UTC().UnixNano())/float64(24*time.Hour) + 2440587.5
+	c := (julianDay - 2451545) / 36525
+
+	meanLongitude := math.Mod(280.46646+c*(36000.76983+c*0.0003032), 360)
+	meanAnomaly := 357.52911 + c*(35999.05029-0.0001537*c)
+	eccentricity := 0.016708634 - c*(0.000042037+0.0000001267*c)
+
+	m := radians(meanAnomaly)
+	center := math.Sin(m)*(1.914602-c*(0.004817+0.000014*c)) + math.Sin(2*m)*(0.019993-0.000101*c) + math.Sin(3*m)*0.000289
+	omega := radians(125.04 - 1934.136*c)
+	apparentLongitude := meanLongitude + center - 0.00569 - 0.00478*math.Sin(omega)
+
+	meanObliquity := 23 + (26+(21.448-c*(46.815+c*(0.00059-c*0.001813)))/60)/60
+	obliquity := radians(meanObliquity + 0.00256*math.Cos(omega))
+	declination = degrees(math.Asin(math.Sin(obliquity) * math.Sin(radians(apparentLongitude))))
+
+	y := math.Pow(math.Tan(obliquity/2), 2)
+	l := radians(meanLongitude)
+	equationOfTime = 4 * degrees(y*math.Sin(2*l)-2*eccentricity*math.Sin(m)+
+		4*eccentricity*y*math.Sin(m)*math.Cos(2*l)-0.5*y*y*math.Sin(4*l)-1.25*eccentricity*eccentricity*math.Sin(2*m))
+	return declination, equationOfTime
+}
+
+// sunPosition returns the elevation, corrected for the atmospheric
+// refraction, and the azimuth of the sun, in degrees.
+func sunPosition(latitude, longitude float64, t time.Time) (elevation, azimuth float64) {
+	declination, equationOfTime := solarCoordinates(t)
+
+	utc := t.UTC()
+	minutes := float64(utc.Hour()*60+utc.Minute()) + (float64(utc.Second())+float64(utc.Nanosecond())/1e9)/60
+	hourAngle := radians(math.Mod(minutes+equationOfTime+4*longitude, 1440)/4 - 180)
+
+	lat, dec := radians(latitude), radians(declination)
+	cosZenith := math.Sin(lat)*math.Sin(dec) + math.Cos(lat)*math.Cos(dec)*math.Cos(hourAngle)
+	zenith := math.Acos(math.Max(-1, math.Min(1, cosZenith)))
+	elevation = 90 - degrees(zenith)
+	elevation += atmosphericRefraction(elevation)
+
+	azimuth = degrees(math.Atan2(math.Sin(hourAngle), math.Cos(hourAngle)*math.Sin(lat)-math.Tan(dec)*math.Cos(lat))) + 180
+	return elevation, math.Mod(azimuth, 360)
+}
+
+// atmosphericRefraction returns the apparent raise, in degrees, of a body at
+// an elevation due to the refraction of the atmosphere.
+func atmosphericRefraction(elevation float64) float64 {
+	if elevation > 85 {
+		return 0
+	}
+	tanE := math.Tan(radians(elevation))
+	var arcSeconds float64
+	switch {
+	case elevation > 5:
+		arcSeconds = 58.1/tanE - 0.07/math.Pow(tanE, 3) + 0.000086/math.Pow(tanE, 5)
+	case elevation > -0.575:
+		arcSeconds = 1735 + elevation*(-518.2+elevation*(103.4+elevation*(-12.79+elevation*0.711)))
+	default:
+		arcSeconds = -20.772 / tanE
+	}
+	return arcSeconds / 3600
+}
+
+// sunEvent returns the sunrise or the sunset of the day starting at midnight
+// UTC, refined from the solar noon. It fails when the sun does not cross the
+// horizon that day.
+func sunEvent(latitude, longitude float64, midnight time.Time, rise bool) (time.Time, bool) {
+	minutes := 720 - 4*longitude
+	for range 3 {
+		declination, equationOfTime := solarCoordinates(midnight.Add(time.Duration(minutes * float64(time.Minute))))
+
+		lat, dec := radians(latitude), radians(declination)
+		cosHourAngle := (math.Cos(radians(90-sunriseElevation)) - math.Sin(lat)*math.Sin(dec)) / (math.Cos(lat) * math.Cos(dec))
+		if cosHourAngle < -1 || cosHourAngle > 1 {
+			return time.Time{}, false
+		}
+		hourAngle := degrees(math.Acos(cosHourAngle))
+		if rise {
+			hourAngle = -hourAngle
+		}
+		minutes = 720 - 4*(longitude-hourAngle) - equationOfTime
+	}
+	return midnight.Add(time.Duration(minutes * float64(time.Minute))).Truncate(time.Second), true
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
@@ -0,0 +1,90 @@
+package media_image
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sun(t *testing.T) {
+	t.Log("Testing sun position")
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("solstice noon", func(t *testing.T) {
+		sun := SunAt(48.8566, 2.3522, time.Date(2024, 6, 21, 14, 0, 0, 0, paris))
+		assert.InDelta(t, 64.5, sun.Elevation, 0.1)
+		assert.InDelta(t, 184, sun.Azimuth, 0.5)
+		assert.Equal(t, DaylightDaytime, sun.Phase)
+		assert.WithinDuration(t, time.Date(2024, 6, 21, 5, 47, 0, 0, paris), sun.Sunrise, time.Minute)
+		assert.WithinDuration(t, time.Date(2024, 6, 21, 21, 58, 0, 0, paris), sun.Sunset, time.Minute)
+		assert.Equal(t, paris, sun.Sunrise.Location())
+	})
+
+	t.Run("phases", func(t *testing.T) {
+		tests := []struct {
+			time  time.Time
+			phase DaylightPhase
+		}{
+			{time.Date(2024, 6, 21, 2, 0, 0, 0, paris), DaylightNight},
+			{time.Date(2024, 6, 21, 22, 35, 0, 0, paris), DaylightBlueHour},
+			{time.Date(2024, 6, 21, 21, 30, 0, 0, paris), DaylightGoldenHour},
+			{time.Date(2024, 6, 21, 9, 0, 0, 0, paris), DaylightDaytime},
+		}
+		for _, tt := range tests {
+			assert.Equal(t, tt.phase, SunAt(48.8566, 2.3522, tt.time).Phase, tt.time.String())
+		}
+	})
+
+	t.Run("polar", func(t *testing.T) {
+		summer := SunAt(69.6492, 18.9553, time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC))
+		assert.True(t, summer.PolarDay)
+		assert.False(t, summer.PolarNight)
+		assert.True(t, summer.Sunrise.IsZero())
+
+		winter := SunAt(69.6492, 18.9553, time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC))
+		assert.True(t, winter.PolarNight)
+		assert.True(t, winter.Sunset.IsZero())
+	})
+
+	t.Run("image", func(t *testing.T) {
+		i := loadImage(t, "samples/jpg/gps/gps-1.jpg")
+		sun, err := i.ImageData.Sun()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, sun.Time.Equal(time.Date(2008, 10, 22, 14, 29, 49, 0, time.UTC)))
+		assert.InDelta(t, 17, sun.Elevation, 0.5)
+		assert.Equal(t, DaylightDaytime, sun.Phase)
+		assert.Equal(t, "Europe/Rome", sun.Sunset.Location().String())
+		assert.True(t, sun.Sunrise.Before(sun.Time) && sun.Sunset.After(sun.Time))
+
+		_, err = loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg").ImageData.Sun()
+		assert.Error(t, err)
+	})
+
+	t.Run("capture instant", func(t *testing.T) {
+		wall := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		gpsTime := time.Date(2024, 5, 1, 7, 59, 0, 0, time.UTC)
+
+		instant, ok := ImageData{DateTimeOriginal: wall, TimeOffset: "+02:00"}.CaptureInstant()
+		assert.True(t, ok)
+		assert.Equal(t, wall.Add(-2*time.Hour), instant)
+
+		instant, ok = ImageData{DateTimeOriginal: wall, GPSTimestamp: gpsTime}.CaptureInstant()
+		assert.True(t, ok)
+		assert.Equal(t, gpsTime, instant)
+
+		instant, ok = ImageData{DateTimeOriginal: wall, SubSecOriginal: "25", TimeOffset: "+02:00"}.CaptureInstant()
+		assert.True(t, ok)
+		assert.Equal(t, wall.Add(-2*time.Hour+250*time.Millisecond), instant)
+
+		stale := &GPSData{Issues: []GPSIssue{GPSIssueTimestampMismatch}}
+		_, ok = ImageData{DateTimeOriginal: wall, GPSTimestamp: gpsTime, GPS: stale}.CaptureInstant()
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,314 @@
+package media_image
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeShift describes the correction applied to the capture times of a set of
+// images, e.g. when the camera clock was left on the home timezone during a trip.
+type TimeShift struct {
+	// Delta is a fixed duration added to every date.
+	Delta time.Duration
+
+	// FromOffset is the offset the camera clock was set to (e.g. "+01:00").
+	// When empty, the offset recorded in the image is used.
+	FromOffset string
+
+	// ToOffset is the offset the dates must be expressed in (e.g. "+09:00").
+	// When empty, the dates are only moved by Delta.
+	ToOffset string
+}
+
+// TimeChange holds the value of a date before and after a time shift.
+type TimeChange struct {
+	Old time.Time
+	New time.Time
+}
+
+// Changed checks if the time shift modifies the date.
+func (c TimeChange) Changed() bool {
+	return !c.Old.IsZero() && !c.Old.Equal(c.New)
+}
+
+// TimeShiftResult is the preview of a time shift for a single image.
+type TimeShiftResult struct {
+	Image *ImageInfo
+
+	DateTime          TimeChange
+	DateTimeOriginal  TimeChange
+	DateTimeDigitized TimeChange
+
+	// TimeOffset is the new offset of the dates, empty when unchanged.
+	TimeOffset string
+
+	// Err is set when the time shift cannot be computed for the image.
+	Err error
+
+	// fromOffset is the offset, in seconds, the original dates are expressed in.
+	fromOffset int
+}
+
+// Changed checks if the time shift modifies any date of the image.
+func (r TimeShiftResult) Changed() bool {
+	return r.Err == nil && (r.DateTime.Changed() || r.DateTimeOriginal.Changed() || r.DateTimeDigitized.Changed() ||
+		r.TimeOffset != "")
+}
+
+// TimeShifter computes and applies a time shift over a set of images.
+type TimeShifter struct {
+	shift  TimeShift
+	writer *ExifWriter
+}
+
+// NewTimeShifter creates a new TimeShifter struct.
+func NewTimeShifter(shift TimeShift) *TimeShifter {
+	return &TimeShifter{
+		shift:  shift,
+		writer: NewExifWriter(),
+	}
+}
+
+// Preview computes the new dates of every image without modifying anything.
+// Images for which the shift cannot be computed have their Err field set.
+func (s *TimeShifter) Preview(images []*ImageInfo) ([]TimeShiftResult, error) {
+	var fromOffset, toOffset int
+	var err error
+
+	if s.shift.FromOffset != "" {
+		if fromOffset, err = parseTimeOffset(s.shift.FromOffset); err != nil {
+			return nil, err
+		}
+	}
+	if s.shift.ToOffset != "" {
+		if toOffset, err = parseTimeOffset(s.shift.ToOffset); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]TimeShiftResult, 0, len(images))
+	for _, image := range images {
+		result := TimeShiftResult{Image: image, fromOffset: fromOffset}
+
+		// Resolve the offset the camera clock was set to
+		if s.shift.FromOffset == "" && image.ImageData.TimeOffset != "" {
+			if result.fromOffset, err = parseTimeOffset(image.ImageData.TimeOffset); err != nil {
+				result.Err = err
+			}
+		} else if s.shift.FromOffset == "" && s.shift.ToOffset != "" {
+			result.Err = fmt.Errorf("no time offset known for %s", image.FileInfo.Name())
+		}
+
+		if result.Err == nil {
+			var loc *time.Location
+			if s.shift.ToOffset != "" {
+				loc = time.FixedZone("", toOffset)
+				result.TimeOffset = formatTimeOffset(toOffset)
+			}
+
+			result.DateTime = s.change(image.ImageData.DateTime, result.fromOffset, toOffset, loc)
+			result.DateTimeOriginal = s.change(image.ImageData.DateTimeOriginal, result.fromOffset, toOffset, loc)
+			result.DateTimeDigitized = s.change(image.ImageData.DateTimeDigitized, result.fromOffset, toOffset, loc)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Apply writes the new dates of the previewed images into their EXIF and XMP
+// data, without re-encoding the pixel data, and updates their ImageData.
+// Results with an error or without changes are skipped.
+func (s *TimeShifter) Apply(results []TimeShiftResult) error {
+	var errs []error
+
+	for _, result := range results {
+		if !result.Changed() {
+			continue
+		}
+
+		edit := metadataEdit{
+			exif: func(editor *exifEditor) error {
+				return s.editExif(editor, result)
+			},
+			xmp: func(packet []byte) ([]byte, error) {
+				return s.editXmp(packet, result.fromOffset), nil
+			},
+		}
+		if err := s.writer.update(result.Image.path(), result.Image.FileType, edit); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Image.FileInfo.Name(), err))
+			continue
+		}
+
+		// Keep the in-memory data in sync with the file
+		imageData := &result.Image.ImageData
+		if result.DateTime.Changed() {
+			imageData.DateTime = result.DateTime.New
+		}
+		if result.DateTimeOriginal.Changed() {
+			imageData.DateTimeOriginal = result.DateTimeOriginal.New
+		}
+		if result.DateTimeDigitized.Changed() {
+			imageData.DateTimeDigitized = result.DateTimeDigitized.New
+		}
+		if result.TimeOffset != "" {
+			imageData.TimeOffset = result.TimeOffset
+			imageData.HasTimeOffset = true
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// change computes the new value of a date. The date keeps its location unless
+// a target location is given.
+func (s *TimeShifter) change(t time.Time, fromOffset int, toOffset int, loc *time.Location) TimeChange {
+	if t.IsZero() {
+		return TimeChange{}
+	}
+	if loc == nil {
+		loc = t.Location()
+	}
+
+	wall := s.shiftWallClock(wallClock(t), fromOffset, toOffset)
+	return TimeChange{Old: t, New: inLocation(wall, loc)}
+}
+
+// shiftWallClock moves a wall clock time by the delta and from one offset to the other.
+func (s *TimeShifter) shiftWallClock(wall time.Time, fromOffset int, toOffset int) time.Time {
+	wall = wall.Add(s.shift.Delta)
+	if s.shift.ToOffset != "" {
+		wall = wall.Add(time.Duration(toOffset-fromOffset) * time.Second)
+	}
+	return wall
+}
+
+// editExif replaces the dates, and the offsets if needed, in the EXIF data.
+func (s *TimeShifter) editExif(editor *exifEditor, result TimeShiftResult) error {
+	tags := []struct {
+		ifdPath   string
+		name      string
+		offsetTag string
+		change    TimeChange
+	}{
+		{ifdPathRoot, "DateTime", "OffsetTime", result.DateTime},
+		{ifdPathExif, "DateTimeOriginal", "OffsetTimeOriginal", result.DateTimeOriginal},
+		{ifdPathExif, "DateTimeDigitized", "OffsetTimeDigitized", result.DateTimeDigitized},
+	}
+
+	for _, tag := range tags {
+		if !editor.has(tag.ifdPath, tag.name) {
+			continue
+		}
+		if tag.change.Changed() {
+			if err := editor.set(tag.ifdPath, tag.name, tag.change.New.Format(exifTimeLayout)); err != nil {
+				return err
+			}
+		}
+		if result.TimeOffset != "" {
+			if err := editor.set(ifdPathExif, tag.offsetTag, result.TimeOffset); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// editXmp shifts the XMP dates mirroring the EXIF dates. Dates carrying their
+// own offset are shifted from that offset rather than from fromOffset.
+func (s *TimeShifter) editXmp(packet []byte, fromOffset int) []byte {
+	var toOffset int
+	if s.shift.ToOffset != "" {
+		toOffset, _ = parseTimeOffset(s.shift.ToOffset)
+	}
+
+	shiftValue := func(value string) (string, bool) {
+		t, layout, ok := parseXmpDate(value)
+		if !ok {
+			return value, false
+		}
+
+		offset := fromOffset
+		if xmpLayoutHasZone(layout) {
+			_, offset = t.Zone()
+		}
+		zone := offset
+		if s.shift.ToOffset != "" {
+			zone = toOffset
+		}
+
+		wall := s.shiftWallClock(wallClock(t), offset, toOffset)
+		return inLocation(wall, time.FixedZone("", zone)).Format(layout), true
+	}
+
+	var properties []string
+	properties = append(properties, xmpDateTimeOriginalProperties...)
+	properties = append(properties, xmpDateTimeDigitizedProperties...)
+	properties = append(properties, xmpDateTimeProperties...)
+	for _, property := range properties {
+		packet = mapXmpProperty(packet, property, shiftValue)
+	}
+	return packet
+}
+
+// wallClock returns the wall clock of the time as a UTC time.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// inLocation returns the time with the wall clock of wall in the given location.
+func inLocation(wall time.Time, loc *time.Location) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+}
+
+// parseTimeOffset parses a time offset such as "+02:00", "+0200", "-07" or "Z"
+// and returns it in seconds east of UTC.
+func parseTimeOffset(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "Z" {
+		return 0, nil
+	}
+	if len(s) < 3 || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("invalid time offset: %s", s)
+	}
+
+	digits := strings.ReplaceAll(s[1:], ":", "")
+	if len(digits) != 2 && len(digits) != 4 {
+		return 0, fmt.Errorf("invalid time offset: %s", s)
+	}
+	hours, err := strconv.Atoi(digits[:2])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time offset: %s", s)
+	}
+	minutes := 0
+	if len(digits) == 4 {
+		if minutes, err = strconv.Atoi(digits[2:]); err != nil {
+			return 0, fmt.Errorf("invalid time offset: %s", s)
+		}
+	}
+	if hours > 14 || minutes > 59 {
+		return 0, fmt.Errorf("invalid time offset: %s", s)
+	}
+
+	offset := hours*3600 + minutes*60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// formatTimeOffset formats an offset in seconds as defined by the EXIF
+// OffsetTime tags, e.g. "+02:00".
+func formatTimeOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%02d:%02d", sign, offset/3600, (offset%3600)/60)
+}
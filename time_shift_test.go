@@ -0,0 +1,109 @@
+package media_image
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// copySample copies a sample file into a temporary directory and returns its path.
+func copySample(t *testing.T, sample string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), filepath.Base(sample))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadImage creates an ImageInfo for the file and extracts its exif data.
+func loadImage(t *testing.T, path string) *ImageInfo {
+	t.Helper()
+
+	imgInfo, err := NewImageInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := imgInfo.Exif()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+func Test_TimeShift(t *testing.T) {
+	t.Log("Testing time shift")
+
+	t.Run("delta", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		i := loadImage(t, path)
+		original := i.ImageData.DateTimeOriginal
+
+		shifter := NewTimeShifter(TimeShift{Delta: 90 * time.Minute})
+		results, err := shifter.Preview([]*ImageInfo{i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, results, 1)
+		assert.True(t, results[0].Changed())
+		assert.Equal(t, original.Add(90*time.Minute), results[0].DateTimeOriginal.New)
+
+		if err := shifter.Apply(results); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, original.Add(90*time.Minute), i.ImageData.DateTimeOriginal)
+
+		reloaded := loadImage(t, path)
+		assert.Equal(t, original.Add(90*time.Minute), reloaded.ImageData.DateTimeOriginal)
+		assert.Equal(t, i.ImageData.DateTimeDigitized, reloaded.ImageData.DateTimeDigitized)
+		assert.Equal(t, i.ImageData.CameraModel, reloaded.ImageData.CameraModel)
+	})
+
+	t.Run("offset", func(t *testing.T) {
+		path := copySample(t, "samples/jpg/exif-org/exif-org-1.jpg")
+		i := loadImage(t, path)
+		original := i.ImageData.DateTimeOriginal
+
+		shifter := NewTimeShifter(TimeShift{FromOffset: "+01:00", ToOffset: "+09:00"})
+		results, err := shifter.Preview([]*ImageInfo{i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := shifter.Apply(results); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded := loadImage(t, path)
+		assert.Equal(t, wallClock(original).Add(8*time.Hour), wallClock(reloaded.ImageData.DateTimeOriginal))
+		assert.Equal(t, "+09:00", reloaded.ImageData.TimeOffset)
+	})
+
+	t.Run("missing offset", func(t *testing.T) {
+		i := loadImage(t, "samples/jpg/exif-org/exif-org-1.jpg")
+
+		results, err := NewTimeShifter(TimeShift{ToOffset: "+09:00"}).Preview([]*ImageInfo{i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Error(t, results[0].Err)
+		assert.False(t, results[0].Changed())
+	})
+
+	t.Run("xmp", func(t *testing.T) {
+		packet := []byte(`<rdf:Description xmp:CreateDate="2024-05-03T10:00:00+01:00">` +
+			`<exif:DateTimeOriginal>2024-05-03T10:00:00</exif:DateTimeOriginal></rdf:Description>`)
+
+		shifter := NewTimeShifter(TimeShift{ToOffset: "+09:00"})
+		packet = shifter.editXmp(packet, 3600)
+		assert.Equal(t, `<rdf:Description xmp:CreateDate="2024-05-03T18:00:00+09:00">`+
+			`<exif:DateTimeOriginal>2024-05-03T18:00:00</exif:DateTimeOriginal></rdf:Description>`, string(packet))
+	})
+}
@@ -0,0 +1,131 @@
+package media_image
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// xmpJpegPrefix is the identifier at the top of a JPEG APP1 XMP segment.
+	xmpJpegPrefix = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+	// xmpPngKeyword is the keyword of the PNG iTXt chunk holding XMP.
+	xmpPngKeyword = []byte("XML:com.adobe.xmp\x00")
+)
+
+// XMP properties holding the dates mirrored from EXIF.
+var (
+	xmpDateTimeOriginalProperties  = []string{"exif:DateTimeOriginal", "photoshop:DateCreated"}
+	xmpDateTimeDigitizedProperties = []string{"exif:DateTimeDigitized", "xmp:CreateDate"}
+	xmpDateTimeProperties          = []string{"tiff:DateTime", "xmp:ModifyDate"}
+)
+
+// xmpDateLayouts lists the ISO 8601 layouts used by XMP dates.
+// Date-only values are not listed as they cannot be shifted meaningfully.
+var xmpDateLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+}
+
+// getXmpPropertyPatterns returns the expressions matching a simple XMP property
+// written either as an attribute or as an element. Each expression captures the
+// text before the value, the value and the text after it.
+func getXmpPropertyPatterns(name string) []*regexp.Regexp {
+	quoted := regexp.QuoteMeta(name)
+	return []*regexp.Regexp{
+		regexp.MustCompile(`(\s` + quoted + `\s*=\s*")([^"]*)(")`),
+		regexp.MustCompile(`(\s` + quoted + `\s*=\s*')([^']*)(')`),
+		regexp.MustCompile(`(<` + quoted + `(?:\s[^>]*)?>)([^<]*)(</` + quoted + `>)`),
+	}
+}
+
+// mapXmpProperty rewrites every value of a simple XMP property with fn.
+// Values for which fn returns false are left untouched.
+func mapXmpProperty(packet []byte, name string, fn func(value string) (string, bool)) []byte {
+	for _, pattern := range getXmpPropertyPatterns(name) {
+		packet = pattern.ReplaceAllFunc(packet, func(match []byte) []byte {
+			groups := pattern.FindSubmatch(match)
+			value, ok := fn(strings.TrimSpace(string(groups[2])))
+			if !ok {
+				return match
+			}
+			return append(append(append([]byte{}, groups[1]...), value...), groups[3]...)
+		})
+	}
+	return packet
+}
+
+// parseXmpDate parses an XMP date and returns the layout it was written with.
+func parseXmpDate(value string) (time.Time, string, bool) {
+	for _, layout := range xmpDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, layout, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// xmpLayoutHasZone checks if an XMP date layout carries a time zone designator.
+func xmpLayoutHasZone(layout string) bool {
+	return strings.HasSuffix(layout, "Z07:00")
+}
+
+// getXmpRemovalPatterns returns the expressions matching an XMP property, simple
+// or structured, written either as an attribute or as an element. A name ending
+// with "*" matches every property starting with the prefix. Each expression
+// captures the name of the property.
+func getXmpRemovalPatterns(name string) []*regexp.Regexp {
+	quoted := regexp.QuoteMeta(name)
+	if prefix, ok := strings.CutSuffix(name, "*"); ok {
+		quoted = regexp.QuoteMeta(prefix) + `[\w.-]*`
+	}
+	return []*regexp.Regexp{
+		regexp.MustCompile(`\s(` + quoted + `)\s*=\s*"[^"]*"`),
+		regexp.MustCompile(`\s(` + quoted + `)\s*=\s*'[^']*'`),
+		regexp.MustCompile(`<(` + quoted + `)(?:\s[^>]*)?/>`),
+		regexp.MustCompile(`(?s)<(` + quoted + `)(?:\s[^>]*)?>.*?</` + quoted + `>`),
+	}
+}
+
+// removeXmpProperty removes every occurrence of an XMP property and returns
+// the names of the removed properties.
+func removeXmpProperty(packet []byte, name string) ([]byte, []string) {
+	var removed []string
+	for _, pattern := range getXmpRemovalPatterns(name) {
+		packet = pattern.ReplaceAllFunc(packet, func(match []byte) []byte {
+			removed = append(removed, string(pattern.FindSubmatch(match)[1]))
+			return nil
+		})
+	}
+	return packet, removed
+}
+
+// extractXmpPacket finds the XMP packet of a file by scanning its content for
+// the x:xmpmeta element, which XMP requires to be stored as plain text
+// whatever the file format. It returns nil when the file has no XMP.
+func extractXmpPacket(data []byte) []byte {
+	start := bytes.Index(data, []byte("<x:xmpmeta"))
+	if start < 0 {
+		return nil
+	}
+	end := bytes.Index(data[start:], []byte("</x:xmpmeta>"))
+	if end < 0 {
+		return nil
+	}
+	return data[start : start+end+len("</x:xmpmeta>")]
+}
+
+// xmpPropertyValue returns the value of the first occurrence of a simple XMP
+// property, written either as an attribute or as an element.
+func xmpPropertyValue(packet []byte, name string) (string, bool) {
+	for _, pattern := range getXmpPropertyPatterns(name) {
+		if groups := pattern.FindSubmatch(packet); groups != nil {
+			return strings.TrimSpace(string(groups[2])), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,109 @@
+package media_image
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+
+	jpegstructure "github.com/dsoprea/go-jpeg-image-structure/v2"
+)
+
+// xmpExtensionJpegPrefix is the identifier at the top of the JPEG APP1
+// segments holding the extended XMP, the part of the XMP data too large for
+// the segment of the main packet.
+var xmpExtensionJpegPrefix = []byte("http://ns.adobe.com/xmp/extension/\x00")
+
+// xmpExtensionProperty is the property of the main packet naming the extended
+// XMP by its GUID, the MD5 digest of its packet.
+const xmpExtensionProperty = "xmpNote:HasExtendedXMP"
+
+// xmpExtensionHeaderSize is the size of the header following the identifier:
+// the GUID, the length of the packet and the offset of the chunk.
+const xmpExtensionHeaderSize = 32 + 4 + 4
+
+// maxXmpExtensionChunkSize is the largest chunk of extended XMP a segment holds.
+var maxXmpExtensionChunkSize = maxJpegSegmentSize - len(xmpExtensionJpegPrefix) - xmpExtensionHeaderSize
+
+// xmpExtension is an extended XMP packet of a JPEG file, put together from
+// its segments.
+type xmpExtension struct {
+	guid     string
+	packet   []byte
+	segments []int // Indexes of the segments holding the packet
+}
+
+// jpegXmpExtensions returns the extended XMP packets of the segments of a
+// JPEG file, by order of their first segment.
+func jpegXmpExtensions(segments []*jpegstructure.Segment) ([]*xmpExtension, error) {
+	type chunk struct {
+		offset uint32
+		data   []byte
+	}
+
+	var extensions []*xmpExtension
+	byGuid := make(map[string]*xmpExtension)
+	lengths := make(map[string]uint32)
+	chunks := make(map[string][]chunk)
+	for index, segment := range segments {
+		if segment.MarkerId != jpegstructure.MARKER_APP1 || !bytes.HasPrefix(segment.Data, xmpExtensionJpegPrefix) {
+			continue
+		}
+		header := segment.Data[len(xmpExtensionJpegPrefix):]
+		if len(header) < xmpExtensionHeaderSize {
+			return nil, fmt.Errorf("%w: truncated extended XMP segment", ErrCorrupt)
+		}
+
+		guid := string(header[:32])
+		extension, ok := byGuid[guid]
+		if !ok {
+			extension = &xmpExtension{guid: guid}
+			extensions = append(extensions, extension)
+			byGuid[guid] = extension
+			lengths[guid] = binary.BigEndian.Uint32(header[32:])
+		}
+		extension.segments = append(extension.segments, index)
+		chunks[guid] = append(chunks[guid], chunk{binary.BigEndian.Uint32(header[36:]), header[xmpExtensionHeaderSize:]})
+	}
+
+	// The chunks cover the packet, which is never larger than the segments
+	for _, extension := range extensions {
+		var size int
+		for _, c := range chunks[extension.guid] {
+			size += len(c.data)
+		}
+		if uint32(size) != lengths[extension.guid] {
+			return nil, fmt.Errorf("%w: extended XMP of %d bytes in chunks of %d bytes", ErrCorrupt, lengths[extension.guid], size)
+		}
+
+		extension.packet = make([]byte, size)
+		for _, c := range chunks[extension.guid] {
+			if int64(c.offset)+int64(len(c.data)) > int64(size) {
+				return nil, fmt.Errorf("%w: extended XMP chunk out of range", ErrCorrupt)
+			}
+			copy(extension.packet[c.offset:], c.data)
+		}
+	}
+	return extensions, nil
+}
+
+// encodeXmpExtension splits an extended XMP packet into APP1 segments, and
+// returns them along with the GUID of the packet.
+func encodeXmpExtension(packet []byte) (string, []*jpegstructure.Segment) {
+	guid := fmt.Sprintf("%X", md5.Sum(packet))
+
+	var segments []*jpegstructure.Segment
+	for offset := 0; offset < len(packet); offset += maxXmpExtensionChunkSize {
+		chunk := packet[offset:min(offset+maxXmpExtensionChunkSize, len(packet))]
+
+		data := append(append([]byte{}, xmpExtensionJpegPrefix...), guid...)
+		data = binary.BigEndian.AppendUint32(data, uint32(len(packet)))
+		data = binary.BigEndian.AppendUint32(data, uint32(offset))
+		segments = append(segments, &jpegstructure.Segment{
+			MarkerId:   jpegstructure.MARKER_APP1,
+			MarkerName: "APP1",
+			Data:       append(data, chunk...),
+		})
+	}
+	return guid, segments
+}